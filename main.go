@@ -44,7 +44,7 @@ import (
 func main() {
 	// GetPrompts()
 
-	cnf := tui.GetDefaultConfig()
+	cnf := tui.GetDefaultConfig(nil)
 	ui := tui.NewGPTUI(cnf)
 	ui.Run()
 
@@ -0,0 +1,260 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Anthropic Messages API.
+
+	POST {base_url}/v1/messages
+	x-api-key: ...
+	anthropic-version: 2023-06-01
+
+	{"model": "...", "max_tokens": 1024, "system": "...", "stream": true,
+	 "messages": [{"role": "user", "content": "..."}, {"role": "assistant", "content": "..."}]}
+
+The stream is server-sent events:
+
+	message_start        usage.input_tokens
+	content_block_delta  delta.text
+	message_delta        usage.output_tokens
+	message_stop         end of the answer
+	error                error.type, error.message
+*/
+const (
+	DefaultBaseUrl    string = "https://api.anthropic.com"
+	DefaultApiVersion string = "2023-06-01"
+	DefaultModel      string = "claude-3-5-sonnet-latest"
+	DefaultMaxTokens  int    = 1024
+)
+
+// Models for selection.
+var ModelList = []string{
+	"claude-3-5-sonnet-latest",
+	"claude-3-5-haiku-latest",
+	"claude-3-opus-latest",
+	"claude-3-sonnet-20240229",
+	"claude-3-haiku-20240307",
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type Request struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream"`
+}
+
+type streamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage usage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage usage    `json:"usage"`
+	Error apiError `json:"error"`
+}
+
+type usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+type apiError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (that apiError) Error() string {
+	return fmt.Sprintf("%s: %s", that.Type, that.Message)
+}
+
+// Retryable tells if err is a rate limit or an overloaded server worth another try.
+func Retryable(err error) (bool, time.Duration) {
+	if e, ok := err.(apiError); ok {
+		return e.Type == "rate_limit_error" || e.Type == "overloaded_error" || e.Type == "api_error", 0
+	}
+	return backend.Retryable(err)
+}
+
+type Claude struct {
+	CNF        *config.Config
+	HttpClient *http.Client
+	resp       *http.Response
+	events     *backend.SSEReader
+	tokens     int64 // usage not reported by GetTokens yet
+}
+
+func NewClaude(cnf *config.Config) (c *Claude) {
+	c = &Claude{
+		CNF:        cnf,
+		HttpClient: backend.NewHttpClient(cnf.Anthropic.Proxy),
+	}
+	return
+}
+
+// Endpoint returns the url of the messages api.
+func (that *Claude) Endpoint() string {
+	base := that.CNF.Anthropic.BaseUrl
+	if base == "" {
+		base = DefaultBaseUrl
+	}
+	return strings.TrimSuffix(base, "/") + "/v1/messages"
+}
+
+func (that *Claude) ProxyUrl() string {
+	return that.CNF.Anthropic.Proxy
+}
+
+func (that *Claude) headers() (map[string]string, error) {
+	apiKey, err := that.CNF.Secret(that.CNF.Anthropic.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+	version := that.CNF.Anthropic.ApiVersion
+	if version == "" {
+		version = DefaultApiVersion
+	}
+	return map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": version,
+		"accept":            "text/event-stream",
+	}, nil
+}
+
+// NewRequest converts chat messages, the system prompt goes to the top level
+// and consecutive messages of the same role are merged, as the api requires alternate roles.
+func (that *Claude) NewRequest(msgs []openai.ChatCompletionMessage) *Request {
+	req := &Request{
+		Model:       that.CNF.Anthropic.Model,
+		MaxTokens:   that.CNF.Anthropic.MaxTokens,
+		Temperature: that.CNF.Anthropic.Temperature,
+		Stream:      true,
+	}
+	if req.Model == "" {
+		req.Model = DefaultModel
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = DefaultMaxTokens
+	}
+	system := []string{}
+	for _, m := range msgs {
+		switch m.Role {
+		case openai.ChatMessageRoleSystem:
+			if m.Content != "" {
+				system = append(system, m.Content)
+			}
+		case openai.ChatMessageRoleUser, openai.ChatMessageRoleAssistant:
+			if l := len(req.Messages); l > 0 && req.Messages[l-1].Role == m.Role {
+				req.Messages[l-1].Content += "\n\n" + m.Content
+			} else if l == 0 && m.Role == openai.ChatMessageRoleAssistant {
+				continue // the first message must be from the user.
+			} else {
+				req.Messages = append(req.Messages, Message{Role: m.Role, Content: m.Content})
+			}
+		}
+	}
+	req.System = strings.Join(system, "\n\n")
+	return req
+}
+
+// readError decodes {"type": "error", "error": {"type": "...", "message": "..."}}.
+func readError(resp *http.Response) error {
+	return backend.ReadError(resp, func(body []byte) string {
+		r := &streamEvent{}
+		if json.Unmarshal(body, r) == nil && r.Error.Message != "" {
+			return r.Error.Error()
+		}
+		return ""
+	})
+}
+
+func (that *Claude) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error) {
+	that.Close()
+	headers, err := that.headers()
+	if err != nil {
+		return "", err
+	}
+	resp, err := backend.PostJSON(that.HttpClient, that.Endpoint(), headers, that.NewRequest(msgs))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", readError(resp)
+	}
+	that.resp = resp
+	that.events = backend.NewSSEReader(resp.Body)
+	return "", nil
+}
+
+// RecvMsg returns the text of the next delta, io.EOF after message_stop.
+func (that *Claude) RecvMsg() (m string, err error) {
+	if that.events == nil {
+		return "", fmt.Errorf("no stream found")
+	}
+	for {
+		ev, err := that.events.Next()
+		if err != nil {
+			that.Close()
+			if err == io.EOF {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		r := &streamEvent{}
+		if err = json.Unmarshal([]byte(ev.Data), r); err != nil {
+			that.Close()
+			return "", fmt.Errorf("decode %s event: %w", ev.Event, err)
+		}
+		switch r.Type {
+		case "message_start":
+			that.tokens += r.Message.Usage.InputTokens
+		case "content_block_delta":
+			if r.Delta.Text != "" {
+				return r.Delta.Text, nil
+			}
+		case "message_delta":
+			that.tokens += r.Usage.OutputTokens
+		case "message_stop":
+			that.Close()
+			return "", io.EOF
+		case "error":
+			that.Close()
+			return "", r.Error
+		}
+	}
+}
+
+func (that *Claude) Close() {
+	if that.resp != nil {
+		that.resp.Body.Close()
+	}
+	that.resp = nil
+	that.events = nil
+}
+
+// GetTokens returns the tokens reported by the api since the last call.
+func (that *Claude) GetTokens() (tokens int64) {
+	tokens, that.tokens = that.tokens, 0
+	return
+}
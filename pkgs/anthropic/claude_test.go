@@ -0,0 +1,143 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newTestClaude returns a client of a local stand-in server, sending events for every request.
+func newTestClaude(t *testing.T, events string, got *Request) *Claude {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("x-api-key") != "sk-test" || r.Header.Get("anthropic-version") != DefaultApiVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`)
+			return
+		}
+		if got != nil {
+			json.NewDecoder(r.Body).Decode(got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, events)
+	}))
+	t.Cleanup(srv.Close)
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.Anthropic.BaseUrl = srv.URL
+	cnf.Anthropic.ApiKey = "sk-test"
+	return NewClaude(cnf)
+}
+
+func sse(event, data string) string {
+	return fmt.Sprintf("event: %s\ndata: %s\n\n", event, data)
+}
+
+func recvAll(c *Claude) (answer string, err error) {
+	for {
+		m, err := c.RecvMsg()
+		answer += m
+		if err != nil {
+			return answer, err
+		}
+	}
+}
+
+func TestClaudeStream(t *testing.T) {
+	events := sse("message_start", `{"type": "message_start", "message": {"usage": {"input_tokens": 12, "output_tokens": 1}}}`) +
+		sse("content_block_start", `{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`) +
+		": keep alive\n\n" +
+		sse("ping", `{"type": "ping"}`) +
+		sse("content_block_delta", `{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Hello"}}`) +
+		sse("content_block_delta", `{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": ", world"}}`) +
+		sse("content_block_stop", `{"type": "content_block_stop", "index": 0}`) +
+		sse("message_delta", `{"type": "message_delta", "delta": {"stop_reason": "end_turn"}, "usage": {"output_tokens": 5}}`) +
+		sse("message_stop", `{"type": "message_stop"}`)
+	req := &Request{}
+	c := newTestClaude(t, events, req)
+	msgs := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: openai.ChatMessageRoleSystem, Content: "Answer in English."},
+		{Role: openai.ChatMessageRoleAssistant, Content: "dropped, the first message is from the user"},
+		{Role: openai.ChatMessageRoleUser, Content: "attached file"},
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "hello"},
+		{Role: openai.ChatMessageRoleUser, Content: "say hello"},
+	}
+	if _, err := c.SendMsg(msgs); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := recvAll(c)
+	if err != io.EOF {
+		t.Fatalf("stream ended with %v", err)
+	}
+	if answer != "Hello, world" {
+		t.Errorf("answer %q", answer)
+	}
+	if tokens := c.GetTokens(); tokens != 17 {
+		t.Errorf("tokens %d, want 17", tokens)
+	}
+	if tokens := c.GetTokens(); tokens != 0 {
+		t.Errorf("tokens reported twice: %d", tokens)
+	}
+
+	if req.System != "Be brief.\n\nAnswer in English." || !req.Stream || req.Model != DefaultModel || req.MaxTokens != DefaultMaxTokens {
+		t.Errorf("request %+v", req)
+	}
+	want := []Message{
+		{Role: "user", Content: "attached file\n\nhi"},
+		{Role: "assistant", Content: "hello"},
+		{Role: "user", Content: "say hello"},
+	}
+	if len(req.Messages) != len(want) {
+		t.Fatalf("messages %+v", req.Messages)
+	}
+	for i := range want {
+		if req.Messages[i] != want[i] {
+			t.Errorf("message %d: %+v, want %+v", i, req.Messages[i], want[i])
+		}
+	}
+}
+
+func TestClaudeStreamError(t *testing.T) {
+	events := sse("message_start", `{"type": "message_start", "message": {"usage": {"input_tokens": 3}}}`) +
+		sse("content_block_delta", `{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Hel"}}`) +
+		sse("error", `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`)
+	c := newTestClaude(t, events, nil)
+	if _, err := c.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := recvAll(c)
+	if answer != "Hel" || err == nil || err.Error() != "overloaded_error: Overloaded" {
+		t.Fatalf("answer %q, err %v", answer, err)
+	}
+	if retry, _ := Retryable(err); !retry {
+		t.Error("overloaded_error is not retryable")
+	}
+}
+
+func TestClaudeStreamCut(t *testing.T) {
+	events := sse("content_block_delta", `{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Hel"}}`)
+	c := newTestClaude(t, events, nil)
+	if _, err := c.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recvAll(c); err != io.ErrUnexpectedEOF {
+		t.Errorf("stream without message_stop ended with %v", err)
+	}
+}
+
+func TestClaudeHTTPError(t *testing.T) {
+	c := newTestClaude(t, "", nil)
+	c.CNF.Anthropic.ApiKey = "sk-wrong"
+	_, err := c.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid x-api-key") {
+		t.Errorf("error %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	nproxy "golang.org/x/net/proxy"
+)
+
+/*
+Helpers shared by the http backends.
+*/
+
+// NewHttpClient returns a client using proxy, http, https or socks5, the environment proxy if empty.
+func NewHttpClient(proxy string) *http.Client {
+	client := &http.Client{}
+	if proxy == "" {
+		return client
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return client
+	}
+	switch u.Scheme {
+	case "http", "https":
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(u)}
+	case "socks5":
+		if dialer, err := nproxy.FromURL(u, nproxy.Direct); err == nil {
+			client.Transport = &http.Transport{Dial: dialer.Dial}
+		}
+	}
+	return client
+}
+
+// PostJSON sends body as json, headers are added to the request.
+func PostJSON(client *http.Client, reqUrl string, headers map[string]string, body interface{}) (*http.Response, error) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, reqUrl, strings.NewReader(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return client.Do(req)
+}
+
+// HTTPError is a response with an error status, Message is decoded from the body when possible.
+type HTTPError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // from the Retry-After header, 0 if missing.
+}
+
+func (that *HTTPError) Error() string {
+	return fmt.Sprintf("http %d: %s", that.StatusCode, that.Message)
+}
+
+// ReadError closes resp and returns an HTTPError, decode extracts the message from the body.
+func ReadError(resp *http.Response, decode func(body []byte) string) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	msg := ""
+	if decode != nil {
+		msg = decode(body)
+	}
+	if msg == "" {
+		msg = strings.TrimSpace(string(body))
+	}
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return &HTTPError{StatusCode: resp.StatusCode, Message: msg, RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After"))}
+}
+
+// ParseRetryAfter parses a Retry-After header in seconds or a http date, 0 if invalid.
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil && n > 0 {
+		return time.Duration(n * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil && time.Until(t) > 0 {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// Retryable tells if err is a rate limit or a server error worth another try,
+// and how long the server asks to wait, 0 if unknown.
+func Retryable(err error) (bool, time.Duration) {
+	httpErr := &HTTPError{}
+	if !errors.As(err, &httpErr) {
+		return false, 0
+	}
+	switch httpErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true, httpErr.RetryAfter
+	}
+	return httpErr.StatusCode >= 500, httpErr.RetryAfter
+}
@@ -0,0 +1,20 @@
+package backend
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// NDJSONReader decodes a stream of json objects, one per line.
+type NDJSONReader struct {
+	decoder *json.Decoder
+}
+
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	return &NDJSONReader{decoder: json.NewDecoder(r)}
+}
+
+// Next decodes the next object into v, io.EOF at the end of the stream.
+func (that *NDJSONReader) Next(v interface{}) error {
+	return that.decoder.Decode(v)
+}
@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// SSEEvent is a server-sent event, data lines are joined with "\n".
+type SSEEvent struct {
+	Event string
+	Data  string
+}
+
+type SSEReader struct {
+	scanner *bufio.Scanner
+}
+
+func NewSSEReader(r io.Reader) *SSEReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	return &SSEReader{scanner: scanner}
+}
+
+// Next returns the next event with data, io.EOF at the end of the stream.
+func (that *SSEReader) Next() (ev SSEEvent, err error) {
+	data := []string{}
+	for that.scanner.Scan() {
+		line := strings.TrimSuffix(that.scanner.Text(), "\r")
+		if line == "" {
+			if len(data) > 0 {
+				ev.Data = strings.Join(data, "\n")
+				return ev, nil
+			}
+			ev = SSEEvent{}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err = that.scanner.Err(); err != nil {
+		return ev, err
+	}
+	if len(data) > 0 {
+		ev.Data = strings.Join(data, "\n")
+		return ev, nil
+	}
+	return ev, io.EOF
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	cvsation "github.com/gvcgo/gogpt/pkgs/conversation"
+	"github.com/gvcgo/gogpt/pkgs/tui"
+	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+)
+
+type fileList []string
+
+func (that *fileList) String() string {
+	return strings.Join(*that, ",")
+}
+
+func (that *fileList) Set(v string) error {
+	*that = append(*that, v)
+	return nil
+}
+
+/*
+gogptm ask [--profile NAME] [--bot NAME] [--file PATH]... [--extract-code DIR] [--<setting> VALUE]... question
+*/
+func runAsk(args []string) {
+	fs := flag.NewFlagSet("ask", flag.ExitOnError)
+	profile := fs.String("profile", "", "config profile to use")
+	botType := fs.String("bot", cvsation.BotGPT, fmt.Sprintf("bot to ask, one of %s, the bot of the profile if not set", strings.Join(tui.BotNames(), ", ")))
+	codeDir := fs.String("extract-code", "", "save fenced code blocks of the answer to this directory")
+	files := &fileList{}
+	fs.Var(files, "file", "attach a file, dir or glob as context, can be repeated")
+	config.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gogptm ask [options] <question>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	question := strings.Join(fs.Args(), " ")
+	if question == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cnf := tui.GetDefaultConfig(fs)
+	if err := cnf.UseProfile(*profile); err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	botSet := false
+	fs.Visit(func(f *flag.Flag) { botSet = botSet || f.Name == "bot" })
+	if bot := cnf.ProfileBot(); !botSet && bot != "" {
+		*botType = bot
+	}
+	b := tui.GetBotBackend(*botType)
+	if b == nil {
+		gprint.PrintError("unknown bot %q, available: %s", *botType, strings.Join(tui.BotNames(), ", "))
+		os.Exit(2)
+	}
+	conv := cvsation.NewConversation(cnf)
+	conv.SetBotType(b.Name)
+	if len(*files) > 0 {
+		report, err := conv.Attach(*files...)
+		if err != nil {
+			gprint.PrintError("%+v", err)
+			os.Exit(1)
+		}
+		gprint.PrintInfo(report.String())
+		for _, f := range report.Refused {
+			gprint.PrintWarning("refused, exceeds the model window: %s", f)
+		}
+	}
+	conv.AddQuestion(question)
+
+	bot := b.New(cnf)
+	defer bot.Close()
+
+	m, err := bot.SendMsg(conv.GetMessages())
+	for err == nil {
+		fmt.Print(m)
+		conv.AddAnswer(m, false)
+		m, err = bot.RecvMsg()
+	}
+	fmt.Print(m)
+	conv.AddAnswer(m, false)
+	fmt.Println()
+	if err != io.EOF {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	answer := conv.Current.A
+	conv.AddAnswer("", true)
+
+	if *codeDir != "" {
+		extractCode(answer, *codeDir)
+	}
+}
+
+func extractCode(answer, dir string) {
+	blocks := cvsation.ExtractCodeBlocks(answer)
+	if len(blocks) == 0 {
+		gprint.PrintWarning("no code block found")
+		return
+	}
+	for _, b := range blocks {
+		// never overwrite existing files, save alongside them instead.
+		unchanged := ""
+		name := cvsation.UniqueFilename(b.Filename, func(name string) bool {
+			unchanged = ""
+			fPath, err := cvsation.ResolveCodePath(dir, name)
+			if err != nil {
+				return false
+			}
+			info, err := os.Stat(fPath)
+			if err != nil {
+				return false
+			}
+			if info.IsDir() {
+				return true
+			}
+			if content, err := os.ReadFile(fPath); err == nil && strings.TrimSuffix(string(content), "\n") == strings.TrimSuffix(b.Code, "\n") {
+				unchanged = fPath
+				return false
+			}
+			return true
+		})
+		if unchanged != "" {
+			gprint.PrintInfo("unchanged: %s", unchanged)
+			continue
+		}
+		if fPath, err := cvsation.SaveCodeBlock(dir, name, b.Code); err != nil {
+			gprint.PrintError("save %s failed: %+v", name, err)
+		} else {
+			gprint.PrintSuccess("saved: %s", fPath)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/gvcgo/gogpt/pkgs/tui"
+	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+)
+
+/*
+gogptm config show [--profile NAME] [--<setting> VALUE]...
+
+Prints the effective settings and where they come from: default, file, env or flag.
+Secrets are redacted, secret references are shown as they are.
+*/
+func runConfig(args []string) {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	profile := fs.String("profile", "", "config profile to use")
+	config.AddFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gogptm config show [options]")
+		fs.PrintDefaults()
+	}
+	if len(args) == 0 || args[0] != "show" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(args[1:])
+
+	cnf := config.NewConf(tui.GetDirs(fs))
+	if err := cnf.ApplyFlags(fs); err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	if err := cnf.UseProfile(*profile); err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("profile: %s\nconfig:  %s\ndata:    %s\ncache:   %s\n\n", cnf.Profile(), cnf.GetWorkDir(), cnf.GetDataDir(), cnf.GetCacheDir())
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, v := range cnf.Effective() {
+		fmt.Fprintf(w, "%s\t%q\t%s\n", v.Key, v.Redacted(), v.Source)
+	}
+	w.Flush()
+}
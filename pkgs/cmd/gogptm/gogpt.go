@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"os"
 	"path/filepath"
 
@@ -13,6 +14,22 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "ask" {
+		runAsk(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "secret" {
+		runSecret(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		runConfig(os.Args[2:])
+		return
+	}
+	profile := flag.String("profile", "", "config profile to use, the default_profile in the config file if empty")
+	config.AddFlags(flag.CommandLine)
+	flag.Parse()
+
 	lockFile, _ := single.New("chatgpt")
 	if err := lockFile.Lock(); err != nil {
 		gprint.PrintError("Another gogpt program is running: %s", lockFile.Lockfile())
@@ -22,9 +39,19 @@ func main() {
 		lockFile.Unlock()
 	}()
 
-	cnf := tui.GetDefaultConfig()
+	tui.MigrateLegacyDir(flag.CommandLine)
+	cnf := tui.GetDefaultConfig(flag.CommandLine)
+	if err := cnf.UseProfile(*profile); err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	if err := cnf.ResolveSecrets(); err != nil {
+		gprint.PrintWarning("%+v", err)
+	}
+	// the terminal belongs to the TUI from now on.
+	cnf.LockSecrets()
 	cnf.OpenAI.PromptMsgUrl = config.PromptUrl
-	promptPath := filepath.Join(cnf.GetWorkDir(), gpt.PromptFileName)
+	promptPath := filepath.Join(cnf.GetCacheDir(), gpt.PromptFileName)
 	if ok, _ := gutils.PathIsExist(promptPath); !ok {
 		prompt := gpt.NewGPTPrompt(cnf)
 		prompt.DownloadPrompt()
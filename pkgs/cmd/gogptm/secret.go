@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/gvcgo/gogpt/pkgs/tui"
+	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+	"golang.org/x/term"
+)
+
+/*
+gogptm secret [--workdir DIR] set <name> | rm <name> | list
+
+Manages the encrypted secrets file, refer to a secret with "vault:<name>" in the config.
+The value of "set" is read from stdin, the passphrase from GOGPT_PASSPHRASE or the terminal.
+*/
+func runSecret(args []string) {
+	fs := flag.NewFlagSet("secret", flag.ExitOnError)
+	fs.String(config.WorkDirFlag, "", "dir of all gogpt files")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gogptm secret [--workdir DIR] set <name> | rm <name> | list")
+	}
+	fs.Parse(args)
+	action, name := fs.Arg(0), fs.Arg(1)
+	if action == "" || (action != "list" && name == "") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	vault, err := openVault(tui.GetDirs(fs).Config)
+	if err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	switch action {
+	case "list":
+		for _, n := range vault.Names() {
+			fmt.Println(n)
+		}
+		return
+	case "set":
+		value, err := readSecret(name)
+		if err != nil {
+			gprint.PrintError("%+v", err)
+			os.Exit(1)
+		}
+		vault.Set(name, value)
+	case "rm":
+		vault.Delete(name)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err = vault.Save(); err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
+	gprint.PrintSuccess("%s %s, use it as vault:%s", action, name, name)
+}
+
+// openVault asks for the passphrase twice when the secrets file is created.
+func openVault(workDir string) (*config.Vault, error) {
+	os.MkdirAll(workDir, 0700)
+	passphrase, ok := os.LookupEnv(config.PassphraseEnv)
+	if !ok {
+		var err error
+		if passphrase, err = config.ReadPassphrase(); err != nil {
+			return nil, err
+		}
+		if _, err = os.Stat(filepath.Join(workDir, config.SecretsFileName)); os.IsNotExist(err) {
+			again, err := config.ReadPassphrase()
+			if err != nil {
+				return nil, err
+			}
+			if again != passphrase {
+				return nil, fmt.Errorf("passphrases do not match")
+			}
+		}
+	}
+	return config.OpenVault(workDir, passphrase)
+}
+
+func readSecret(name string) (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "value of %s: ", name)
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	b, err := io.ReadAll(os.Stdin)
+	return strings.TrimRight(string(b), "\r\n"), err
+}
@@ -3,9 +3,15 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 
-	"github.com/gvcgo/goutils/pkgs/gutils"
+	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
 	"github.com/gvcgo/goutils/pkgs/koanfer"
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/structs"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -16,20 +22,24 @@ const (
 
 // ChatGPT
 type OpenAIConf struct {
-	BaseUrl            string         `koanf,json:"base_url"`
-	ApiKey             string         `koanf,json:"api_key"`
-	ApiType            openai.APIType `koanf,json:"api_type"`
-	ApiVersion         string         `koanf,json:"api_version"`
-	OrgID              string         `koanf,json:"org_id"`
-	Engine             string         `koanf,json:"engine"`
-	EmptyMessagesLimit uint           `koanf,json:"empty_msg_limit"`
-	Proxy              string         `koanf,json:"proxy"`
-	Model              string         `koanf,json:"model"`
-	MaxTokens          int            `koanf,json:"max_tokens"`
-	ContextLen         int            `koanf,json:"context_length"`
-	Temperature        float32        `koanf,json:"temperature"`
-	PromptMsgUrl       string         `koanf,json:"prompt_msgs_url"`
-	PromptStr          string         `koanf,json:"prompt"`
+	BaseUrl            string         `koanf:"base_url" json:"base_url"`
+	ApiKey             string         `koanf:"api_key" json:"api_key"`
+	ApiType            openai.APIType `koanf:"api_type" json:"api_type"`
+	ApiVersion         string         `koanf:"api_version" json:"api_version"`
+	OrgID              string         `koanf:"org_id" json:"org_id"`
+	Engine             string         `koanf:"engine" json:"engine"`
+	TenantID           string         `koanf:"tenant_id" json:"tenant_id"`
+	ClientID           string         `koanf:"client_id" json:"client_id"`
+	ClientSecret       string         `koanf:"client_secret" json:"client_secret"`
+	TokenUrl           string         `koanf:"token_url" json:"token_url"`
+	EmptyMessagesLimit uint           `koanf:"empty_msg_limit" json:"empty_msg_limit"`
+	Proxy              string         `koanf:"proxy" json:"proxy"`
+	Model              string         `koanf:"model" json:"model"`
+	MaxTokens          int            `koanf:"max_tokens" json:"max_tokens"`
+	ContextLen         int            `koanf:"context_length" json:"context_length"`
+	Temperature        float32        `koanf:"temperature" json:"temperature"`
+	PromptMsgUrl       string         `koanf:"prompt_msgs_url" json:"prompt_msgs_url"`
+	PromptStr          string         `koanf:"prompt" json:"prompt"`
 }
 
 type SparkAPIVersion string
@@ -48,54 +58,327 @@ const (
 
 // IFlyTek Spark
 type IflySparkConf struct {
-	APIVersion  SparkAPIVersion `koanf,json:"spark_api_version"`
-	APPID       string          `koanf,json:"spark_app_id"`
-	UID         string          `koanf,json:"spark_user_id"`
-	APPKey      string          `koanf,json:"spark_app_key"`
-	APPSecrete  string          `koanf,json:"spark_app_secrete"`
-	MaxTokens   int64           `koanf,json:"spark_max_tokens"`
-	Temperature float64         `koanf,json:"spark_temperature"`
-	TopK        int64           `koanf,json:"spark_topk"`
-	ChatID      string          `koanf,json:"spark_chat_id"`
-	Timeout     int             `koanf,json:"spark_timeout"` // seconds
+	APIVersion  SparkAPIVersion `koanf:"spark_api_version" json:"spark_api_version"`
+	APPID       string          `koanf:"spark_app_id" json:"spark_app_id"`
+	UID         string          `koanf:"spark_user_id" json:"spark_user_id"`
+	APPKey      string          `koanf:"spark_app_key" json:"spark_app_key"`
+	APPSecrete  string          `koanf:"spark_app_secrete" json:"spark_app_secrete"`
+	MaxTokens   int64           `koanf:"spark_max_tokens" json:"spark_max_tokens"`
+	Temperature float64         `koanf:"spark_temperature" json:"spark_temperature"`
+	TopK        int64           `koanf:"spark_topk" json:"spark_topk"`
+	ChatID      string          `koanf:"spark_chat_id" json:"spark_chat_id"`
+	Timeout     int             `koanf:"spark_timeout" json:"spark_timeout"` // seconds
+}
+
+// Anthropic Claude
+type AnthropicConf struct {
+	BaseUrl     string  `koanf:"base_url" json:"base_url"`
+	ApiKey      string  `koanf:"api_key" json:"api_key"`
+	ApiVersion  string  `koanf:"api_version" json:"api_version"` // anthropic-version header
+	Proxy       string  `koanf:"proxy" json:"proxy"`
+	Model       string  `koanf:"model" json:"model"`
+	MaxTokens   int     `koanf:"max_tokens" json:"max_tokens"`
+	Temperature float64 `koanf:"temperature" json:"temperature"`
+}
+
+// Google Gemini
+type GeminiConf struct {
+	BaseUrl     string  `koanf:"base_url" json:"base_url"`
+	ApiKey      string  `koanf:"api_key" json:"api_key"`
+	ApiVersion  string  `koanf:"api_version" json:"api_version"` // path segment, like v1beta
+	Proxy       string  `koanf:"proxy" json:"proxy"`
+	Model       string  `koanf:"model" json:"model"`
+	MaxTokens   int     `koanf:"max_tokens" json:"max_tokens"`
+	Temperature float64 `koanf:"temperature" json:"temperature"`
+}
+
+// Ollama, local models
+type OllamaConf struct {
+	BaseUrl     string                 `koanf:"base_url" json:"base_url"`
+	Model       string                 `koanf:"model" json:"model"`
+	NumCtx      int                    `koanf:"num_ctx" json:"num_ctx"` // context window of the model
+	Temperature float64                `koanf:"temperature" json:"temperature"`
+	KeepAlive   string                 `koanf:"keep_alive" json:"keep_alive"` // how long the model stays loaded, like 5m
+	Options     map[string]interface{} `koanf:"options" json:"options"`       // other model options, passed through
+}
+
+// Alibaba DashScope Qwen
+type QwenConf struct {
+	BaseUrl     string  `koanf:"base_url" json:"base_url"`
+	ApiKey      string  `koanf:"api_key" json:"api_key"`
+	Model       string  `koanf:"model" json:"model"`
+	MaxTokens   int     `koanf:"max_tokens" json:"max_tokens"`
+	Temperature float64 `koanf:"temperature" json:"temperature"`
+}
+
+// Baidu Qianfan ERNIE
+type ErnieConf struct {
+	BaseUrl     string  `koanf:"base_url" json:"base_url"`
+	ApiKey      string  `koanf:"api_key" json:"api_key"`
+	SecretKey   string  `koanf:"secret_key" json:"secret_key"`
+	Model       string  `koanf:"model" json:"model"` // endpoint of the model, like completions_pro
+	MaxTokens   int     `koanf:"max_tokens" json:"max_tokens"`
+	Temperature float64 `koanf:"temperature" json:"temperature"`
+}
+
+// Zhipu GLM
+type GLMConf struct {
+	BaseUrl     string  `koanf:"base_url" json:"base_url"`
+	ApiKey      string  `koanf:"api_key" json:"api_key"` // {id}.{secret}
+	Model       string  `koanf:"model" json:"model"`
+	MaxTokens   int     `koanf:"max_tokens" json:"max_tokens"`
+	Temperature float64 `koanf:"temperature" json:"temperature"`
+}
+
+// A route of the router, a backend with the settings of a profile.
+type RouteConf struct {
+	Bot     string   `koanf:"bot" json:"bot"`           // backend name, like ChatGPT or Claude.
+	Profile string   `koanf:"profile" json:"profile"`   // profile of the backend settings, the active one if empty.
+	ApiKeys []string `koanf:"api_keys" json:"api_keys"` // keys or secret references used in turn, the configured key if empty.
+}
+
+// Router, failover across backends
+type RouterConf struct {
+	Routes     []RouteConf `koanf:"routes" json:"routes"`           // tried in order.
+	Attempts   int         `koanf:"attempts" json:"attempts"`       // rounds over the routes, 3 by default.
+	Backoff    float64     `koanf:"backoff" json:"backoff"`         // seconds before the second round, doubled each round, 1 by default.
+	MaxBackoff float64     `koanf:"max_backoff" json:"max_backoff"` // longest wait in seconds, 30 by default.
+}
+
+const (
+	DefaultSubmitKey      string = "enter"
+	DefaultInputMaxHeight int    = 10
+	DefaultTheme          string = "auto"
+)
+
+// TUI
+type UIConf struct {
+	SubmitKey      string `koanf:"submit_key" json:"submit_key"`             // key to send the message, "alt+enter" and "ctrl+j" insert a newline if it is "enter".
+	InputMaxHeight int    `koanf:"input_max_height" json:"input_max_height"` // max lines of the input area.
+	Theme          string `koanf:"theme" json:"theme"`                       // theme name, "auto" by default.
 }
 
 type Config struct {
-	OpenAI  *OpenAIConf    `koanf,json:"openai"`
-	Spark   *IflySparkConf `koanf,json:"spark"`
-	path    string
-	workDir string
-	koanfer *koanfer.JsonKoanfer
+	Version   int            `koanf:"version" json:"version"` // schema version, see ConfigMigrations.
+	OpenAI    *OpenAIConf    `koanf:"openai" json:"openai"`
+	Spark     *IflySparkConf `koanf:"spark" json:"spark"`
+	Anthropic *AnthropicConf `koanf:"anthropic" json:"anthropic"`
+	Gemini    *GeminiConf    `koanf:"gemini" json:"gemini"`
+	Ollama    *OllamaConf    `koanf:"ollama" json:"ollama"`
+	Qwen      *QwenConf      `koanf:"qwen" json:"qwen"`
+	Ernie     *ErnieConf     `koanf:"ernie" json:"ernie"`
+	GLM       *GLMConf       `koanf:"glm" json:"glm"`
+	Router    *RouterConf    `koanf:"router" json:"router"`
+	UI        *UIConf        `koanf:"ui" json:"ui"`
+	// action name -> keys separated by commas
+	Keybindings    map[string]string   `koanf:"keybindings" json:"keybindings"`
+	Profiles       map[string]*Profile `koanf:"profiles" json:"profiles"`
+	DefaultProfile string              `koanf:"default_profile" json:"default_profile"`
+	path           string
+	dirs           Dirs
+	profile        string               // active profile, empty for the default one.
+	defaults       Profile              // sections of the default profile.
+	loaded         Profile              // active sections when the file was read or written, see unsaved.
+	secrets        *secretCache         // resolved secret references, shared by copies
+	overrides      map[string]*override // settings from env and flags.
 }
 
-func NewConf(workDir string) (cfg *Config) {
-	if ok, _ := gutils.PathIsExist(workDir); !ok {
-		os.MkdirAll(workDir, os.ModePerm)
-	}
-	cfg = &Config{
-		OpenAI:  &OpenAIConf{},
-		Spark:   &IflySparkConf{},
-		workDir: workDir,
+// emptyConfig returns a config without settings, the config file is loaded into it.
+func emptyConfig() *Config {
+	return &Config{
+		OpenAI:      &OpenAIConf{},
+		Spark:       &IflySparkConf{},
+		Anthropic:   &AnthropicConf{},
+		Gemini:      &GeminiConf{},
+		Ollama:      &OllamaConf{},
+		Qwen:        &QwenConf{},
+		Ernie:       &ErnieConf{},
+		GLM:         &GLMConf{},
+		Router:      &RouterConf{},
+		UI:          &UIConf{},
+		Keybindings: map[string]string{},
+		Profiles:    map[string]*Profile{},
+		Version:     ConfigSchemaVersion,
+		secrets:     &secretCache{values: map[string]string{}},
 	}
-	cfg.path = filepath.Join(workDir, ConfigFileName)
-	cfg.koanfer, _ = koanfer.NewKoanfer(cfg.path)
-	if cfg.koanfer != nil {
-		cfg.Reload()
+}
+
+func NewConf(dirs Dirs) (cfg *Config) {
+	dirs.MkdirAll()
+	cfg = emptyConfig()
+	cfg.dirs = dirs
+	cfg.path = filepath.Join(dirs.Config, ConfigFileName)
+	if err := MigrateFile(cfg.path, ConfigMigrations); err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
 	}
-	if cfg.OpenAI.PromptMsgUrl == "" {
-		cfg.OpenAI.PromptMsgUrl = PromptUrl
+	cfg.Reload()
+	cfg.fillDefaults()
+	if err := cfg.ApplyEnv(); err != nil {
+		gprint.PrintWarning("%+v", err)
 	}
 	return
 }
 
+// GetWorkDir returns the dir of the config file, secrets and themes.
 func (that *Config) GetWorkDir() string {
-	return that.workDir
+	return that.dirs.Config
+}
+
+// GetDataDir returns the dir of sessions and input history.
+func (that *Config) GetDataDir() string {
+	return that.dirs.Data
 }
 
-func (that *Config) Reload() {
-	that.koanfer.Load(that)
+// GetCacheDir returns the dir of downloaded prompts and model lists.
+func (that *Config) GetCacheDir() string {
+	return that.dirs.Cache
 }
 
+// fillDefaults sets the defaults of settings missing from the config file.
+func (that *Config) fillDefaults() {
+	if that.OpenAI.PromptMsgUrl == "" {
+		that.OpenAI.PromptMsgUrl = PromptUrl
+	}
+	if that.UI.SubmitKey == "" {
+		that.UI.SubmitKey = DefaultSubmitKey
+	}
+	if that.UI.InputMaxHeight == 0 {
+		that.UI.InputMaxHeight = DefaultInputMaxHeight
+	}
+	if that.UI.Theme == "" {
+		that.UI.Theme = DefaultTheme
+	}
+}
+
+// Reload reads the config file again, the config is unchanged if the file is invalid.
+// The file is read into an empty config, so keys removed from the file are removed here too.
+func (that *Config) Reload() (err error) {
+	k := koanf.New(".")
+	if err = k.Load(file.Provider(that.path), koanfer.NewJsonParser()); err != nil {
+		return
+	}
+	loaded := emptyConfig()
+	if err = k.UnmarshalWithConf("", loaded, koanf.UnmarshalConf{Tag: "koanf"}); err != nil {
+		return
+	}
+	for name, p := range loaded.Profiles {
+		if p == nil {
+			p = &Profile{}
+			loaded.Profiles[name] = p
+		}
+		p.keys = map[string]bool{}
+		for _, key := range k.Cut("profiles." + name).Keys() {
+			if key != "bot" {
+				p.keys[key] = true
+			}
+		}
+	}
+	that.revertOverrides()
+	that.restoreDefault()
+	that.Version = loaded.Version
+	that.setSections(loaded.sections())
+	that.Router, that.UI = loaded.Router, loaded.UI
+	that.Keybindings, that.Profiles, that.DefaultProfile = loaded.Keybindings, loaded.Profiles, loaded.DefaultProfile
+	that.fillDefaults()
+	that.applyProfile()
+	that.applyOverrides()
+	that.loaded = that.sections().copy()
+	return
+}
+
+// Reloaded returns a new config read from the config file, the config itself is unchanged.
+// Settings from env and flags are applied again, unsaved changes like the model set by /model
+// are kept and returned as koanf paths.
+func (that *Config) Reloaded() (c *Config, kept []string, err error) {
+	changes := that.unsaved()
+	c = that.Copy()
+	if err = c.Reload(); err != nil {
+		return nil, nil, err
+	}
+	if err = unmarshalPaths(c, changes); err != nil {
+		return nil, nil, err
+	}
+	for path := range changes {
+		kept = append(kept, path)
+	}
+	sort.Strings(kept)
+	return
+}
+
+// unsaved returns the settings of the active sections changed since the config file was read or written,
+// settings from env and flags are left out.
+func (that *Config) unsaved() map[string]interface{} {
+	changes := map[string]interface{}{}
+	if that.loaded.OpenAI == nil {
+		return changes
+	}
+	overridden := map[string]bool{}
+	for key := range that.overrides {
+		s, _ := settingByKey(key)
+		overridden[s.Path] = true
+	}
+	cur, loaded := that.sections(), that.loaded
+	ck, lk := cur.settings(), loaded.settings()
+	for _, path := range ck.Keys() {
+		if v := ck.Get(path); !overridden[path] && !reflect.DeepEqual(v, lk.Get(path)) {
+			changes[path] = v
+		}
+	}
+	return changes
+}
+
+// Save writes the config file, readable by the owner only.
+// Settings from env and flags are not saved.
 func (that *Config) Save() {
-	that.koanfer.Save(that)
+	that.revertOverrides()
+	that.restoreDefault()
+	// a new koanf each time, keys loaded before would be merged back otherwise.
+	k := koanf.New(".")
+	k.Load(structs.Provider(that, "koanf"), nil)
+	// profiles keep only their own settings, the others follow the default profile.
+	k.Delete("profiles")
+	profiles := map[string]interface{}{}
+	for name, p := range that.Profiles {
+		profiles[name] = p.fileSettings()
+	}
+	k.Load(confmap.Provider(map[string]interface{}{"profiles": profiles}, ""), nil)
+	if content, err := k.Marshal(koanfer.NewJsonParser()); err == nil && PrivateFile(that.path) == nil {
+		os.WriteFile(that.path, content, 0600)
+	}
+	that.applyProfile()
+	that.applyOverrides()
+	that.loaded = that.sections().copy()
+}
+
+// Copy returns a config with copied sections and profiles, saving it writes the same file.
+// Resolved secrets are shared with the copy.
+func (that *Config) Copy() *Config {
+	c := *that
+	// the copy gets its own sections, the default ones stay the active ones without a profile.
+	c.setSections(that.sections().copy())
+	c.defaults = c.sections()
+	if that.Profiles[that.profile] != nil && that.defaults.OpenAI != nil {
+		c.defaults = that.defaults.copy()
+	}
+	ui := *that.UI
+	c.UI = &ui
+	router := *that.Router
+	router.Routes = append([]RouteConf{}, that.Router.Routes...)
+	c.Router = &router
+	c.Keybindings = map[string]string{}
+	for k, v := range that.Keybindings {
+		c.Keybindings[k] = v
+	}
+	c.Profiles = map[string]*Profile{}
+	for name, p := range that.Profiles {
+		pc := p.copy()
+		c.Profiles[name] = &pc
+	}
+	c.overrides = map[string]*override{}
+	for k, o := range that.overrides {
+		oc := *o
+		c.overrides[k] = &oc
+	}
+	return &c
 }
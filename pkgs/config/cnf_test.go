@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReloadRemovesDeletedKeys(t *testing.T) {
+	dir := t.TempDir()
+	cfg := NewConf(NewDirs(dir))
+	cfg.OpenAI.Proxy = "http://127.0.0.1:7890"
+	cfg.Keybindings["save"] = "ctrl+alt+s"
+	cfg.Profiles["work"] = &Profile{Bot: "Spark"}
+	cfg.Save()
+
+	// the file is edited by hand, the proxy, keybinding and profile are removed.
+	content := `{"version": 2, "openai": {"model": "gpt-4"}, "ui": {"theme": "dark"}}`
+	if err := os.WriteFile(cfg.path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OpenAI.Proxy != "" || len(cfg.Keybindings) != 0 || len(cfg.Profiles) != 0 {
+		t.Errorf("removed keys survived the reload: proxy %q, keybindings %v, profiles %v", cfg.OpenAI.Proxy, cfg.Keybindings, cfg.Profiles)
+	}
+	if cfg.OpenAI.Model != "gpt-4" || cfg.UI.Theme != "dark" || cfg.UI.SubmitKey != DefaultSubmitKey {
+		t.Errorf("reloaded settings: %+v, %+v", cfg.OpenAI, cfg.UI)
+	}
+
+	cfg.Save()
+	saved, _ := os.ReadFile(cfg.path)
+	for _, removed := range []string{"7890", "ctrl+alt+s", "work"} {
+		if strings.Contains(string(saved), removed) {
+			t.Errorf("removed key %q is written back", removed)
+		}
+	}
+
+	// an invalid file leaves the config unchanged.
+	os.WriteFile(cfg.path, []byte("{"), 0600)
+	if err := cfg.Reload(); err == nil {
+		t.Error("reloaded an invalid file")
+	}
+	if cfg.OpenAI.Model != "gpt-4" {
+		t.Errorf("config changed by an invalid file: %+v", cfg.OpenAI)
+	}
+}
+
+func TestReloadedKeepsUnsavedChanges(t *testing.T) {
+	t.Setenv("GOGPT_OPENAI_PROXY", "http://127.0.0.1:1080")
+	cfg := NewConf(NewDirs(t.TempDir()))
+	cfg.OpenAI.Model = "gpt-4"
+	cfg.Save()
+	// changes like /model and /temp are not saved.
+	cfg.OpenAI.Model = "gpt-4o"
+	cfg.Anthropic.Temperature = 0.5
+
+	content := `{"version": 2, "openai": {"model": "gpt-4", "context_length": 8}, "anthropic": {"temperature": 0.9}}`
+	if err := os.WriteFile(cfg.path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, kept, err := cfg.Reloaded()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == cfg || cfg.OpenAI.ContextLen == 8 {
+		t.Error("the config is changed in place")
+	}
+	if c.OpenAI.ContextLen != 8 || c.OpenAI.Model != "gpt-4o" || c.Anthropic.Temperature != 0.5 || c.OpenAI.Proxy != "http://127.0.0.1:1080" {
+		t.Errorf("reloaded config: %+v, %+v", c.OpenAI, c.Anthropic)
+	}
+	if strings.Join(kept, ",") != "anthropic.temperature,openai.model" {
+		t.Errorf("kept %v, want the unsaved model and temperature", kept)
+	}
+
+	// saved changes are not unsaved any more.
+	c.Save()
+	if _, kept, _ := c.Reloaded(); len(kept) != 0 {
+		t.Errorf("kept %v after saving", kept)
+	}
+}
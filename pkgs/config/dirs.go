@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+/*
+Dirs of gogpt files.
+
+With --workdir or GOGPT_HOME everything lives in that dir, like the legacy ~/.gogpt.
+Otherwise Linux follows the XDG base dirs:
+
+	$XDG_CONFIG_HOME/gogpt   config, secrets and themes
+	$XDG_DATA_HOME/gogpt     sessions and input history
+	$XDG_CACHE_HOME/gogpt    prompts and model lists
+
+An existing ~/.gogpt is moved to the XDG dirs when the TUI starts without --workdir and GOGPT_HOME.
+Other systems keep using ~/.gogpt.
+*/
+const (
+	WorkDirFlag   string = "workdir"
+	WorkDirEnv    string = "GOGPT_HOME"
+	AppDirName    string = "gogpt"
+	LegacyDirName string = ".gogpt"
+)
+
+type Dirs struct {
+	Config string
+	Data   string
+	Cache  string
+}
+
+func NewDirs(dir string) Dirs {
+	return Dirs{Config: dir, Data: dir, Cache: dir}
+}
+
+// LegacyDir returns ~/.gogpt.
+func LegacyDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, LegacyDirName)
+}
+
+// ResolveDirs returns the dirs for workDir, GOGPT_HOME or the system default, in this order.
+func ResolveDirs(workDir string) Dirs {
+	if workDir == "" {
+		workDir = os.Getenv(WorkDirEnv)
+	}
+	if workDir != "" {
+		if abs, err := filepath.Abs(workDir); err == nil {
+			workDir = abs
+		}
+		return NewDirs(workDir)
+	}
+	return DefaultDirs()
+}
+
+// DefaultDirs returns the dirs used without --workdir and GOGPT_HOME.
+func DefaultDirs() Dirs {
+	if runtime.GOOS != "linux" {
+		return NewDirs(LegacyDir())
+	}
+	homeDir, _ := os.UserHomeDir()
+	return Dirs{
+		Config: xdgDir("XDG_CONFIG_HOME", filepath.Join(homeDir, ".config")),
+		Data:   xdgDir("XDG_DATA_HOME", filepath.Join(homeDir, ".local", "share")),
+		Cache:  xdgDir("XDG_CACHE_HOME", filepath.Join(homeDir, ".cache")),
+	}
+}
+
+// xdgDir ignores relative paths, as the XDG spec requires.
+func xdgDir(env, fallback string) string {
+	base := os.Getenv(env)
+	if !filepath.IsAbs(base) {
+		base = fallback
+	}
+	return filepath.Join(base, AppDirName)
+}
+
+// MkdirAll creates the dirs, readable by the owner only.
+func (that Dirs) MkdirAll() error {
+	for _, d := range []string{that.Config, that.Data, that.Cache} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
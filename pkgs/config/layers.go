@@ -0,0 +1,271 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gvcgo/goutils/pkgs/koanfer"
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/confmap"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/structs"
+)
+
+/*
+Layered config: defaults < config file < environment variables < flags.
+
+The env name of a setting is GOGPT_ and the key in upper case, "." replaced by "_",
+like GOGPT_OPENAI_API_KEY for "openai.api_key". The flag is the key, like --openai.api_key.
+Overrides apply to the active profile and are never written to the config file.
+*/
+const (
+	EnvPrefix     string = "GOGPT_"
+	SourceDefault string = "default"
+	SourceFile    string = "file"
+	SourceEnv     string = "env"
+	SourceFlag    string = "flag"
+)
+
+type Setting struct {
+	Key     string   // name of env and flag.
+	Path    string   // koanf path in Config.
+	Aliases []string // other env names, with lower priority.
+	Secret  bool
+	Help    string
+}
+
+func (that Setting) Env() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(that.Key, ".", "_"))
+}
+
+var Settings = []Setting{
+	{Key: "openai.base_url", Path: "openai.base_url", Help: "ChatGPT base url"},
+	{Key: "openai.api_key", Path: "openai.api_key", Secret: true, Help: "ChatGPT auth token or secret reference"},
+	{Key: "openai.api_type", Path: "openai.api_type", Help: "ChatGPT api type, OPEN_AI, AZURE or AZURE_AD"},
+	{Key: "openai.api_version", Path: "openai.api_version", Help: "ChatGPT api version"},
+	{Key: "openai.org_id", Path: "openai.org_id", Help: "organization ID"},
+	{Key: "openai.engine", Path: "openai.engine", Help: "Azure deployment name"},
+	{Key: "openai.tenant_id", Path: "openai.tenant_id", Aliases: []string{"AZURE_TENANT_ID"}, Help: "Azure AD tenant ID"},
+	{Key: "openai.client_id", Path: "openai.client_id", Aliases: []string{"AZURE_CLIENT_ID"}, Help: "Azure AD client ID"},
+	{Key: "openai.client_secret", Path: "openai.client_secret", Aliases: []string{"AZURE_CLIENT_SECRET"}, Secret: true, Help: "Azure AD client secret or secret reference"},
+	{Key: "openai.token_url", Path: "openai.token_url", Help: "Azure AD token endpoint"},
+	{Key: "openai.empty_msg_limit", Path: "openai.empty_msg_limit", Help: "max empty message limit"},
+	{Key: "openai.proxy", Path: "openai.proxy", Aliases: []string{"CHATGPT_PROXY"}, Help: "ChatGPT proxy"},
+	{Key: "openai.model", Path: "openai.model", Aliases: []string{"GOGPT_MODEL"}, Help: "ChatGPT model"},
+	{Key: "openai.max_tokens", Path: "openai.max_tokens", Help: "ChatGPT max tokens"},
+	{Key: "openai.context_length", Path: "openai.context_length", Help: "conversation context length"},
+	{Key: "openai.temperature", Path: "openai.temperature", Help: "ChatGPT temperature"},
+	{Key: "openai.prompt", Path: "openai.prompt", Help: "system prompt"},
+	{Key: "spark.api_version", Path: "spark.spark_api_version", Help: "spark api version"},
+	{Key: "spark.app_id", Path: "spark.spark_app_id", Help: "spark app id"},
+	{Key: "spark.user_id", Path: "spark.spark_user_id", Help: "spark user id"},
+	{Key: "spark.app_key", Path: "spark.spark_app_key", Secret: true, Help: "spark api key or secret reference"},
+	{Key: "spark.app_secrete", Path: "spark.spark_app_secrete", Secret: true, Help: "spark api secrete or secret reference"},
+	{Key: "spark.max_tokens", Path: "spark.spark_max_tokens", Help: "spark max tokens"},
+	{Key: "spark.temperature", Path: "spark.spark_temperature", Help: "spark temperature"},
+	{Key: "spark.top_k", Path: "spark.spark_topk", Help: "spark top_k"},
+	{Key: "spark.chat_id", Path: "spark.spark_chat_id", Help: "spark chat id"},
+	{Key: "spark.timeout", Path: "spark.spark_timeout", Help: "spark timeout in seconds"},
+	{Key: "anthropic.base_url", Path: "anthropic.base_url", Help: "Claude base url"},
+	{Key: "anthropic.api_key", Path: "anthropic.api_key", Secret: true, Help: "Claude api key or secret reference"},
+	{Key: "anthropic.api_version", Path: "anthropic.api_version", Help: "anthropic-version header"},
+	{Key: "anthropic.proxy", Path: "anthropic.proxy", Help: "Claude proxy"},
+	{Key: "anthropic.model", Path: "anthropic.model", Help: "Claude model"},
+	{Key: "anthropic.max_tokens", Path: "anthropic.max_tokens", Help: "Claude max tokens"},
+	{Key: "anthropic.temperature", Path: "anthropic.temperature", Help: "Claude temperature"},
+	{Key: "gemini.base_url", Path: "gemini.base_url", Help: "Gemini base url"},
+	{Key: "gemini.api_key", Path: "gemini.api_key", Aliases: []string{"GEMINI_API_KEY"}, Secret: true, Help: "Gemini api key or secret reference"},
+	{Key: "gemini.api_version", Path: "gemini.api_version", Help: "Gemini api version"},
+	{Key: "gemini.proxy", Path: "gemini.proxy", Help: "Gemini proxy"},
+	{Key: "gemini.model", Path: "gemini.model", Help: "Gemini model"},
+	{Key: "gemini.max_tokens", Path: "gemini.max_tokens", Help: "Gemini max output tokens"},
+	{Key: "gemini.temperature", Path: "gemini.temperature", Help: "Gemini temperature"},
+	{Key: "ollama.base_url", Path: "ollama.base_url", Help: "Ollama base url"},
+	{Key: "ollama.model", Path: "ollama.model", Help: "Ollama model"},
+	{Key: "ollama.num_ctx", Path: "ollama.num_ctx", Help: "Ollama context window"},
+	{Key: "ollama.temperature", Path: "ollama.temperature", Help: "Ollama temperature"},
+	{Key: "ollama.keep_alive", Path: "ollama.keep_alive", Help: "how long Ollama keeps the model loaded"},
+	{Key: "qwen.base_url", Path: "qwen.base_url", Help: "DashScope base url"},
+	{Key: "qwen.api_key", Path: "qwen.api_key", Aliases: []string{"DASHSCOPE_API_KEY"}, Secret: true, Help: "DashScope api key or secret reference"},
+	{Key: "qwen.model", Path: "qwen.model", Help: "Qwen model"},
+	{Key: "qwen.max_tokens", Path: "qwen.max_tokens", Help: "Qwen max tokens"},
+	{Key: "qwen.temperature", Path: "qwen.temperature", Help: "Qwen temperature"},
+	{Key: "ernie.base_url", Path: "ernie.base_url", Help: "Qianfan base url"},
+	{Key: "ernie.api_key", Path: "ernie.api_key", Aliases: []string{"QIANFAN_AK"}, Secret: true, Help: "Qianfan api key or secret reference"},
+	{Key: "ernie.secret_key", Path: "ernie.secret_key", Aliases: []string{"QIANFAN_SK"}, Secret: true, Help: "Qianfan secret key or secret reference"},
+	{Key: "ernie.model", Path: "ernie.model", Help: "ERNIE model endpoint"},
+	{Key: "ernie.max_tokens", Path: "ernie.max_tokens", Help: "ERNIE max output tokens"},
+	{Key: "ernie.temperature", Path: "ernie.temperature", Help: "ERNIE temperature"},
+	{Key: "glm.base_url", Path: "glm.base_url", Help: "Zhipu base url"},
+	{Key: "glm.api_key", Path: "glm.api_key", Aliases: []string{"ZHIPUAI_API_KEY"}, Secret: true, Help: "Zhipu api key or secret reference"},
+	{Key: "glm.model", Path: "glm.model", Help: "GLM model"},
+	{Key: "glm.max_tokens", Path: "glm.max_tokens", Help: "GLM max tokens"},
+	{Key: "glm.temperature", Path: "glm.temperature", Help: "GLM temperature"},
+	{Key: "router.attempts", Path: "router.attempts", Help: "rounds over the router routes"},
+	{Key: "router.backoff", Path: "router.backoff", Help: "router backoff in seconds, doubled each round"},
+	{Key: "router.max_backoff", Path: "router.max_backoff", Help: "longest router backoff in seconds"},
+	{Key: "ui.submit_key", Path: "ui.submit_key", Help: "key to send a message"},
+	{Key: "ui.input_max_height", Path: "ui.input_max_height", Help: "max lines of the input area"},
+	{Key: "ui.theme", Path: "ui.theme", Help: "theme name"},
+	{Key: "default_profile", Path: "default_profile", Aliases: []string{"GOGPT_PROFILE"}, Help: "profile used without --profile"},
+}
+
+type override struct {
+	Value  string
+	Source string // env or flag, with the name.
+	orig   string // value before the override.
+}
+
+// SettingValue is an effective value and where it comes from.
+type SettingValue struct {
+	Setting
+	Value  string
+	Source string
+}
+
+// Redacted hides values of secrets, references are shown.
+func (that SettingValue) Redacted() string {
+	if !that.Secret || that.Value == "" || IsSecretRef(that.Value) {
+		return that.Value
+	}
+	return "******"
+}
+
+// AddFlags adds --workdir and a flag for every setting to fs.
+func AddFlags(fs *flag.FlagSet) {
+	fs.String(WorkDirFlag, "", fmt.Sprintf("dir of all gogpt files, %s if empty, XDG dirs or ~/%s by default", WorkDirEnv, LegacyDirName))
+	for _, s := range Settings {
+		fs.String(s.Key, "", s.Help)
+	}
+}
+
+// ApplyEnv reads overrides from environment variables.
+func (that *Config) ApplyEnv() error {
+	that.revertOverrides()
+	for _, s := range Settings {
+		names := append([]string{s.Env()}, s.Aliases...)
+		for _, name := range names {
+			if v, ok := os.LookupEnv(name); ok {
+				that.setOverride(s.Key, v, fmt.Sprintf("%s %s", SourceEnv, name))
+				break
+			}
+		}
+	}
+	return that.applyOverrides()
+}
+
+// ApplyFlags reads overrides from flags set in fs, see AddFlags.
+func (that *Config) ApplyFlags(fs *flag.FlagSet) error {
+	that.revertOverrides()
+	fs.Visit(func(f *flag.Flag) {
+		if _, ok := settingByKey(f.Name); ok {
+			that.setOverride(f.Name, f.Value.String(), fmt.Sprintf("%s --%s", SourceFlag, f.Name))
+		}
+	})
+	return that.applyOverrides()
+}
+
+// HasOverrides tells if any setting comes from env or flags.
+func (that *Config) HasOverrides() bool {
+	return len(that.overrides) > 0
+}
+
+func (that *Config) setOverride(key, value, source string) {
+	if that.overrides == nil {
+		that.overrides = map[string]*override{}
+	}
+	that.overrides[key] = &override{Value: value, Source: source}
+}
+
+func settingByKey(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// current loads the config struct, with the active profile, into koanf.
+func (that *Config) current() *koanf.Koanf {
+	k := koanf.New(".")
+	k.Load(structs.Provider(that, "koanf"), nil)
+	return k
+}
+
+// unmarshalPaths sets the values of koanf paths in obj, other fields are unchanged.
+func unmarshalPaths(obj interface{}, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+	k := koanf.New(".")
+	k.Load(confmap.Provider(values, "."), nil)
+	return k.UnmarshalWithConf("", obj, koanf.UnmarshalConf{Tag: "koanf"})
+}
+
+// applyOverrides puts overrides to the active sections, remembering the values before.
+func (that *Config) applyOverrides() error {
+	if len(that.overrides) == 0 {
+		return nil
+	}
+	cur := that.current()
+	values := map[string]interface{}{}
+	for key, o := range that.overrides {
+		s, _ := settingByKey(key)
+		o.orig = cur.String(s.Path)
+		values[s.Path] = o.Value
+	}
+	err := unmarshalPaths(that, values)
+	if err != nil {
+		return fmt.Errorf("invalid override: %w", err)
+	}
+	return nil
+}
+
+// revertOverrides restores overridden values not changed since, like edits in the config form.
+func (that *Config) revertOverrides() {
+	if len(that.overrides) == 0 {
+		return
+	}
+	cur := that.current()
+	values := map[string]interface{}{}
+	for key, o := range that.overrides {
+		s, _ := settingByKey(key)
+		if cur.String(s.Path) == o.Value {
+			values[s.Path] = o.orig
+		}
+	}
+	unmarshalPaths(that, values)
+}
+
+// Effective returns every setting with its value and source.
+func (that *Config) Effective() (values []SettingValue) {
+	cur := that.current()
+	inFile := map[string]struct{}{}
+	fk := koanf.New(".")
+	if err := fk.Load(file.Provider(that.path), koanfer.NewJsonParser()); err == nil {
+		for _, key := range fk.Keys() {
+			inFile[key] = struct{}{}
+		}
+	}
+	p := that.Profiles[that.profile]
+	for _, s := range Settings {
+		v := SettingValue{Setting: s, Value: cur.String(s.Path), Source: SourceDefault}
+		filePath := s.Path
+		if p != nil && p.keys[s.Path] {
+			filePath = fmt.Sprintf("profiles.%s.%s", that.profile, s.Path)
+		}
+		if _, ok := inFile[filePath]; ok {
+			v.Source = SourceFile
+		}
+		if o, ok := that.overrides[s.Key]; ok {
+			v.Source = o.Source
+		}
+		values = append(values, v)
+	}
+	sort.SliceStable(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+	return
+}
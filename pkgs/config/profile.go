@@ -0,0 +1,252 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/maps"
+	"github.com/knadh/koanf/providers/structs"
+)
+
+/*
+Named profiles.
+
+The top level backend sections, like openai and spark, are the "default" profile.
+The active profile is laid over them field by field in memory, settings missing from the profile
+follow the default profile. Edits go to the active profile, settings changed from the default
+profile are saved in it.
+
+	"profiles": {
+	    "azure": {"bot": "ChatGPT", "openai": {...}},
+	    "local": {"openai": {...}}
+	},
+	"default_profile": "azure"
+*/
+const (
+	DefaultProfileName string = "default"
+)
+
+type Profile struct {
+	Bot       string         `koanf:"bot" json:"bot"` // backend used by default, like ChatGPT or Spark.
+	OpenAI    *OpenAIConf    `koanf:"openai" json:"openai"`
+	Spark     *IflySparkConf `koanf:"spark" json:"spark"`
+	Anthropic *AnthropicConf `koanf:"anthropic" json:"anthropic"`
+	Gemini    *GeminiConf    `koanf:"gemini" json:"gemini"`
+	Ollama    *OllamaConf    `koanf:"ollama" json:"ollama"`
+	Qwen      *QwenConf      `koanf:"qwen" json:"qwen"`
+	Ernie     *ErnieConf     `koanf:"ernie" json:"ernie"`
+	GLM       *GLMConf       `koanf:"glm" json:"glm"`
+
+	keys map[string]bool // settings set by the profile, like "openai.model".
+}
+
+// Profile returns the name of the active profile.
+func (that *Config) Profile() string {
+	if that.profile == "" {
+		return DefaultProfileName
+	}
+	return that.profile
+}
+
+// ProfileBot returns the backend of the active profile, empty for no preference.
+func (that *Config) ProfileBot() string {
+	if p := that.Profiles[that.profile]; p != nil {
+		return p.Bot
+	}
+	return ""
+}
+
+// ProfileNames returns "default" and the sorted profile names.
+func (that *Config) ProfileNames() (names []string) {
+	for name := range that.Profiles {
+		if name != DefaultProfileName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfileName}, names...)
+}
+
+// UseProfile activates a profile, an empty name uses DefaultProfile.
+func (that *Config) UseProfile(name string) error {
+	if name == "" {
+		name = that.DefaultProfile
+	}
+	if name != "" && name != DefaultProfileName {
+		if _, ok := that.Profiles[name]; !ok {
+			return fmt.Errorf("unknown profile %q, available: %s", name, strings.Join(that.ProfileNames(), ", "))
+		}
+	}
+	that.revertOverrides()
+	that.restoreDefault()
+	that.profile = name
+	if name == DefaultProfileName {
+		that.profile = ""
+	}
+	that.applyProfile()
+	err := that.applyOverrides()
+	that.loaded = that.sections().copy()
+	return err
+}
+
+// SaveProfile saves the active settings as a profile and activates it.
+func (that *Config) SaveProfile(name string) error {
+	if name == "" || strings.ContainsAny(name, ". ") {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	that.revertOverrides()
+	cur := that.sections().copy()
+	if name == DefaultProfileName {
+		that.restoreDefault()
+		that.setSections(cur)
+		that.profile = ""
+	} else {
+		if that.Profiles == nil {
+			that.Profiles = map[string]*Profile{}
+		}
+		p := &Profile{Bot: that.ProfileBot()}
+		that.restoreDefault()
+		p.keep(cur, that.sections())
+		that.Profiles[name] = p
+		that.profile = name
+	}
+	that.applyProfile()
+	that.Save()
+	return nil
+}
+
+// restoreDefault keeps the active settings in the active profile and puts the default profile back to the top level sections.
+func (that *Config) restoreDefault() {
+	if that.defaults.OpenAI == nil {
+		return
+	}
+	if p := that.Profiles[that.profile]; p != nil {
+		p.keep(that.sections(), that.defaults)
+	}
+	that.setSections(that.defaults)
+	that.defaults = Profile{}
+}
+
+// applyProfile remembers the default profile and replaces it with the active one.
+func (that *Config) applyProfile() {
+	that.defaults = that.sections()
+	if p := that.Profiles[that.profile]; p != nil {
+		that.setSections(p.overlay(that.defaults))
+	}
+}
+
+// sections returns the backend sections of the config.
+func (that *Config) sections() Profile {
+	return Profile{
+		OpenAI: that.OpenAI, Spark: that.Spark, Anthropic: that.Anthropic, Gemini: that.Gemini,
+		Ollama: that.Ollama, Qwen: that.Qwen, Ernie: that.Ernie, GLM: that.GLM,
+	}
+}
+
+func (that *Config) setSections(p Profile) {
+	that.OpenAI, that.Spark, that.Anthropic, that.Gemini = p.OpenAI, p.Spark, p.Anthropic, p.Gemini
+	that.Ollama, that.Qwen, that.Ernie, that.GLM = p.Ollama, p.Qwen, p.Ernie, p.GLM
+}
+
+// settings returns the flat settings of the profile, like "openai.model".
+func (that *Profile) settings() *koanf.Koanf {
+	k := koanf.New(".")
+	k.Load(structs.Provider(that, "koanf"), nil)
+	return k
+}
+
+// overlay returns copies of the base sections with the settings of the profile on top.
+func (that *Profile) overlay(base Profile) Profile {
+	merged := base.copy()
+	k := that.settings()
+	values := map[string]interface{}{}
+	for key := range that.keys {
+		if k.Exists(key) {
+			values[key] = k.Get(key)
+		}
+	}
+	unmarshalPaths(&merged, values)
+	return merged
+}
+
+// keep stores copies of the active sections in the profile.
+// Settings differing from the default profile are set by the profile from now on.
+func (that *Profile) keep(active, defaults Profile) {
+	ak, dk := active.settings(), defaults.settings()
+	if that.keys == nil {
+		that.keys = map[string]bool{}
+	}
+	for _, key := range ak.Keys() {
+		if key != "bot" && !reflect.DeepEqual(ak.Get(key), dk.Get(key)) {
+			that.keys[key] = true
+		}
+	}
+	bot, keys := that.Bot, that.keys
+	*that = active.copy()
+	that.Bot, that.keys = bot, keys
+}
+
+// fileSettings returns the bot and the settings set by the profile, as they are saved in the config file.
+func (that *Profile) fileSettings() map[string]interface{} {
+	k := that.settings()
+	values := map[string]interface{}{"bot": that.Bot}
+	for key := range that.keys {
+		if k.Exists(key) {
+			values[key] = k.Get(key)
+		}
+	}
+	return maps.Unflatten(values, ".")
+}
+
+// copy returns the profile with copied sections.
+func (that Profile) copy() Profile {
+	if that.OpenAI != nil {
+		v := *that.OpenAI
+		that.OpenAI = &v
+	}
+	if that.Spark != nil {
+		v := *that.Spark
+		that.Spark = &v
+	}
+	if that.Anthropic != nil {
+		v := *that.Anthropic
+		that.Anthropic = &v
+	}
+	if that.Gemini != nil {
+		v := *that.Gemini
+		that.Gemini = &v
+	}
+	if that.Ollama != nil {
+		v := *that.Ollama
+		if that.Ollama.Options != nil {
+			v.Options = map[string]interface{}{}
+			for k, o := range that.Ollama.Options {
+				v.Options[k] = o
+			}
+		}
+		that.Ollama = &v
+	}
+	if that.Qwen != nil {
+		v := *that.Qwen
+		that.Qwen = &v
+	}
+	if that.Ernie != nil {
+		v := *that.Ernie
+		that.Ernie = &v
+	}
+	if that.GLM != nil {
+		v := *that.GLM
+		that.GLM = &v
+	}
+	if that.keys != nil {
+		keys := map[string]bool{}
+		for k := range that.keys {
+			keys[k] = true
+		}
+		that.keys = keys
+	}
+	return that
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+const testProfileConf = `{
+    "version": 2,
+    "openai": {"base_url": "https://api.openai.com/v1", "model": "gpt-4", "context_length": 10, "temperature": 0.7, "proxy": "http://127.0.0.1:7890"},
+    "profiles": {
+        "azure": {"bot": "ChatGPT", "openai": {"base_url": "https://example.openai.azure.com", "api_key": "env:AZURE_KEY"}},
+        "local": {"openai": {"proxy": "", "temperature": 0}}
+    }
+}`
+
+func newTestProfileConf(t *testing.T) *Config {
+	t.Helper()
+	cfg := NewConf(NewDirs(t.TempDir()))
+	if err := os.WriteFile(cfg.path, []byte(testProfileConf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func readProfileSection(t *testing.T, cfg *Config, profile, section string) map[string]interface{} {
+	t.Helper()
+	content, err := os.ReadFile(cfg.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := struct {
+		Profiles map[string]map[string]interface{} `json:"profiles"`
+	}{}
+	if err := json.Unmarshal(content, &file); err != nil {
+		t.Fatal(err)
+	}
+	values, _ := file.Profiles[profile][section].(map[string]interface{})
+	return values
+}
+
+func TestProfileOverlaysFields(t *testing.T) {
+	cfg := newTestProfileConf(t)
+	if err := cfg.UseProfile("azure"); err != nil {
+		t.Fatal(err)
+	}
+	// the profile sets the base url and the key, the rest follows the default profile.
+	if cfg.OpenAI.BaseUrl != "https://example.openai.azure.com" || cfg.OpenAI.ApiKey != "env:AZURE_KEY" {
+		t.Errorf("settings of the profile: %+v", cfg.OpenAI)
+	}
+	if cfg.OpenAI.Model != "gpt-4" || cfg.OpenAI.ContextLen != 10 || cfg.OpenAI.Temperature != 0.7 {
+		t.Errorf("settings of the default profile are lost: %+v", cfg.OpenAI)
+	}
+
+	// zero values set by a profile are kept.
+	if err := cfg.UseProfile("local"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OpenAI.Proxy != "" || cfg.OpenAI.Temperature != 0 || cfg.OpenAI.Model != "gpt-4" {
+		t.Errorf("zero values of the profile: %+v", cfg.OpenAI)
+	}
+
+	if err := cfg.UseProfile(DefaultProfileName); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OpenAI.BaseUrl != "https://api.openai.com/v1" || cfg.OpenAI.ApiKey != "" || cfg.OpenAI.Proxy != "http://127.0.0.1:7890" {
+		t.Errorf("default profile changed by other profiles: %+v", cfg.OpenAI)
+	}
+}
+
+func TestProfileSavesOwnSettings(t *testing.T) {
+	cfg := newTestProfileConf(t)
+	cfg.UseProfile("azure")
+	cfg.OpenAI.Model = "gpt-4o"
+	cfg.Save()
+
+	section := readProfileSection(t, cfg, "azure", "openai")
+	if len(section) != 3 || section["model"] != "gpt-4o" || section["api_key"] != "env:AZURE_KEY" {
+		t.Errorf("saved profile section %v, want base_url, api_key and model only", section)
+	}
+	if s := readProfileSection(t, cfg, "azure", "spark"); s != nil {
+		t.Errorf("unset section is saved: %v", s)
+	}
+	if s := readProfileSection(t, cfg, "local", "openai"); len(s) != 2 {
+		t.Errorf("inactive profile section %v", s)
+	}
+
+	// a changed default reaches the profile, unless the profile sets it.
+	cfg.UseProfile(DefaultProfileName)
+	cfg.OpenAI.ContextLen = 20
+	cfg.OpenAI.Model = "gpt-3.5-turbo"
+	cfg.Save()
+	cfg.Reload()
+	cfg.UseProfile("azure")
+	if cfg.OpenAI.ContextLen != 20 || cfg.OpenAI.Model != "gpt-4o" {
+		t.Errorf("profile over the changed default: %+v", cfg.OpenAI)
+	}
+}
+
+func TestSaveProfileKeepsDifferences(t *testing.T) {
+	cfg := newTestProfileConf(t)
+	cfg.UseProfile("azure")
+	cfg.OpenAI.Model = "gpt-4-turbo-preview"
+	if err := cfg.SaveProfile("turbo"); err != nil {
+		t.Fatal(err)
+	}
+	section := readProfileSection(t, cfg, "turbo", "openai")
+	if len(section) != 3 || section["model"] != "gpt-4-turbo-preview" || section["base_url"] != "https://example.openai.azure.com" {
+		t.Errorf("saved profile section %v, want base_url, api_key and model", section)
+	}
+	if cfg.Profile() != "turbo" || cfg.OpenAI.Model != "gpt-4-turbo-preview" || cfg.OpenAI.ContextLen != 10 {
+		t.Errorf("active profile %s: %+v", cfg.Profile(), cfg.OpenAI)
+	}
+}
+
+func TestCopyKeepsProfile(t *testing.T) {
+	cfg := newTestProfileConf(t)
+	cfg.UseProfile("azure")
+	c := cfg.Copy()
+	c.OpenAI.Model = "gpt-4o"
+	if cfg.OpenAI.Model != "gpt-4" {
+		t.Errorf("copy shares the active section: %+v", cfg.OpenAI)
+	}
+	if c.OpenAI.BaseUrl != "https://example.openai.azure.com" || c.OpenAI.ContextLen != 10 {
+		t.Errorf("settings of the copy: %+v", c.OpenAI)
+	}
+	c.UseProfile(DefaultProfileName)
+	if c.OpenAI.Model != "gpt-4" || cfg.Profiles["azure"].keys["openai.model"] {
+		t.Errorf("copy changed the default profile or the original profile: %+v", c.OpenAI)
+	}
+}
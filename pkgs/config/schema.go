@@ -0,0 +1,196 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+On-disk schema versions.
+
+Files without a "version" key are version 1, written with the Go field names as keys,
+because the struct tags used to be malformed. Version 2 uses the snake case names of the tags.
+A migration upgrades a file by one version, older files go through the whole chain and
+the original is kept as <file>.v<version>.bak.
+*/
+const (
+	SchemaVersionKey      string = "version"
+	ConfigSchemaVersion   int    = 2
+	implicitSchemaVersion int    = 1
+)
+
+// Migration upgrades a decoded json file from one version to the next.
+type Migration func(data map[string]interface{}) error
+
+// ConfigMigrations upgrade gogpt_conf.json, the item i upgrades version i+1.
+var ConfigMigrations = []Migration{
+	migrateConfigV1,
+}
+
+// FileVersion returns the schema version of decoded json.
+func FileVersion(data map[string]interface{}) (int, error) {
+	v, ok := data[SchemaVersionKey]
+	if !ok {
+		return implicitSchemaVersion, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f < 1 || f != float64(int(f)) {
+		return 0, fmt.Errorf("invalid schema version: %v", v)
+	}
+	return int(f), nil
+}
+
+// Migrate upgrades data to the last version of migrations, it tells if data is changed.
+func Migrate(data map[string]interface{}, migrations []Migration) (changed bool, err error) {
+	version, err := FileVersion(data)
+	if err != nil {
+		return false, err
+	}
+	latest := len(migrations) + 1
+	if version > latest {
+		return false, fmt.Errorf("schema version %d is newer than %d, upgrade gogpt", version, latest)
+	}
+	for ; version < latest; version++ {
+		if err = migrations[version-1](data); err != nil {
+			return false, fmt.Errorf("migrate from version %d: %w", version, err)
+		}
+		changed = true
+	}
+	data[SchemaVersionKey] = latest
+	return
+}
+
+// MigrateFile upgrades a json file in place, missing files are fine.
+func MigrateFile(fPath string, migrations []Migration) error {
+	content, err := os.ReadFile(fPath)
+	if os.IsNotExist(err) || (err == nil && len(strings.TrimSpace(string(content))) == 0) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	data := map[string]interface{}{}
+	if err = json.Unmarshal(content, &data); err != nil {
+		return fmt.Errorf("parse %s: %w", fPath, err)
+	}
+	version, err := FileVersion(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fPath, err)
+	}
+	changed, err := Migrate(data, migrations)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fPath, err)
+	}
+	if !changed {
+		return nil
+	}
+	info, err := os.Stat(fPath)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(fmt.Sprintf("%s.v%d.bak", fPath, version), content, info.Mode().Perm()); err != nil {
+		return err
+	}
+	content, err = json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fPath, content, info.Mode().Perm())
+}
+
+// RenameKeys renames keys of data, old names match case insensitively like the decoder did.
+func RenameKeys(data map[string]interface{}, names map[string]string) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		for old, name := range names {
+			if strings.EqualFold(key, old) && key != name {
+				data[name] = data[key]
+				delete(data, key)
+				break
+			}
+		}
+	}
+}
+
+// Object returns data[key] as an object, nil if it is not one.
+func Object(data map[string]interface{}, key string) map[string]interface{} {
+	m, _ := data[key].(map[string]interface{})
+	return m
+}
+
+var (
+	configV1Names = map[string]string{
+		"OpenAI":         "openai",
+		"Spark":          "spark",
+		"UI":             "ui",
+		"Keybindings":    "keybindings",
+		"Profiles":       "profiles",
+		"DefaultProfile": "default_profile",
+	}
+	openAIV1Names = map[string]string{
+		"BaseUrl":            "base_url",
+		"ApiKey":             "api_key",
+		"ApiType":            "api_type",
+		"ApiVersion":         "api_version",
+		"OrgID":              "org_id",
+		"Engine":             "engine",
+		"EmptyMessagesLimit": "empty_msg_limit",
+		"Proxy":              "proxy",
+		"Model":              "model",
+		"MaxTokens":          "max_tokens",
+		"ContextLen":         "context_length",
+		"Temperature":        "temperature",
+		"PromptMsgUrl":       "prompt_msgs_url",
+		"PromptStr":          "prompt",
+	}
+	sparkV1Names = map[string]string{
+		"APIVersion":  "spark_api_version",
+		"APPID":       "spark_app_id",
+		"UID":         "spark_user_id",
+		"APPKey":      "spark_app_key",
+		"APPSecrete":  "spark_app_secrete",
+		"MaxTokens":   "spark_max_tokens",
+		"Temperature": "spark_temperature",
+		"TopK":        "spark_topk",
+		"ChatID":      "spark_chat_id",
+		"Timeout":     "spark_timeout",
+	}
+	uiV1Names = map[string]string{
+		"SubmitKey":      "submit_key",
+		"InputMaxHeight": "input_max_height",
+		"Theme":          "theme",
+	}
+	profileV1Names = map[string]string{
+		"Bot":    "bot",
+		"OpenAI": "openai",
+		"Spark":  "spark",
+	}
+)
+
+// migrateConfigV1 renames Go field names to the tag names.
+func migrateConfigV1(data map[string]interface{}) error {
+	RenameKeys(data, configV1Names)
+	renameSections := func(m map[string]interface{}) {
+		if openAI := Object(m, "openai"); openAI != nil {
+			RenameKeys(openAI, openAIV1Names)
+		}
+		if spark := Object(m, "spark"); spark != nil {
+			RenameKeys(spark, sparkV1Names)
+		}
+	}
+	renameSections(data)
+	if ui := Object(data, "ui"); ui != nil {
+		RenameKeys(ui, uiV1Names)
+	}
+	for _, p := range Object(data, "profiles") {
+		if profile, ok := p.(map[string]interface{}); ok {
+			RenameKeys(profile, profileV1Names)
+			renameSections(profile)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// copyTestFile copies a file of testdata to a temp dir, it returns the copy and its content.
+func copyTestFile(t *testing.T, name, dest string) (fPath string, content []byte) {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fPath = filepath.Join(t.TempDir(), dest)
+	if err = os.WriteFile(fPath, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func assertJSONFile(t *testing.T, fPath, golden string) {
+	t.Helper()
+	decode := func(p string) (v interface{}) {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(content, &v); err != nil {
+			t.Fatalf("parse %s: %v", p, err)
+		}
+		return
+	}
+	if got, want := decode(fPath), decode(filepath.Join("testdata", golden)); !reflect.DeepEqual(got, want) {
+		content, _ := json.MarshalIndent(got, "", "    ")
+		t.Errorf("%s differs from %s:\n%s", fPath, golden, content)
+	}
+}
+
+func TestMigrateConfigV1(t *testing.T) {
+	fPath, original := copyTestFile(t, "gogpt_conf.v1.json", ConfigFileName)
+	if err := MigrateFile(fPath, ConfigMigrations); err != nil {
+		t.Fatal(err)
+	}
+	assertJSONFile(t, fPath, "gogpt_conf.v2.golden.json")
+	bak, err := os.ReadFile(fPath + ".v1.bak")
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if string(bak) != string(original) {
+		t.Error("backup differs from the original file")
+	}
+}
+
+func TestMigrateConfigCurrent(t *testing.T) {
+	fPath, original := copyTestFile(t, "gogpt_conf.v2.golden.json", ConfigFileName)
+	if err := MigrateFile(fPath, ConfigMigrations); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(fPath); string(content) != string(original) {
+		t.Error("a file at the current version is changed")
+	}
+	if baks, _ := filepath.Glob(fPath + ".*.bak"); len(baks) > 0 {
+		t.Errorf("backup of a file at the current version: %v", baks)
+	}
+}
+
+func TestMigrateConfigLoads(t *testing.T) {
+	fPath, _ := copyTestFile(t, "gogpt_conf.v1.json", ConfigFileName)
+	cfg := NewConf(NewDirs(filepath.Dir(fPath)))
+	if cfg.OpenAI.ApiKey != "sk-default" || cfg.Spark.TopK != 4 {
+		t.Errorf("default sections: %+v, %+v", cfg.OpenAI, cfg.Spark)
+	}
+	if err := cfg.UseProfile(""); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OpenAI.ApiKey != "sk-work" || cfg.OpenAI.Model != "gpt-4" {
+		t.Errorf("openai of the default profile: %+v", cfg.OpenAI)
+	}
+	if cfg.Spark.APPID != "work-app-id" || cfg.Spark.TopK != 2 {
+		t.Errorf("spark of the default profile: %+v", cfg.Spark)
+	}
+	if cfg.UI.SubmitKey != "ctrl+s" || cfg.Keybindings["save"] != "ctrl+alt+s" {
+		t.Errorf("ui: %+v, keybindings: %v", cfg.UI, cfg.Keybindings)
+	}
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+	data := map[string]interface{}{SchemaVersionKey: float64(ConfigSchemaVersion + 1)}
+	if _, err := Migrate(data, ConfigMigrations); err == nil {
+		t.Error("migrated a file newer than gogpt")
+	}
+	data = map[string]interface{}{SchemaVersionKey: "2"}
+	if _, err := Migrate(data, ConfigMigrations); err == nil {
+		t.Error("migrated a file with an invalid version")
+	}
+}
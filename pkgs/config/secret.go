@@ -0,0 +1,206 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+/*
+Secret references in the config, resolved when clients are created:
+
+	env:OPENAI_API_KEY      environment variable
+	cmd:pass show openai    first line of the command output
+	vault:openai            entry of the encrypted secrets file, see "gogptm secret"
+
+Other values are used as they are.
+*/
+const (
+	SecretEnvPrefix   string = "env:"
+	SecretCmdPrefix   string = "cmd:"
+	SecretVaultPrefix string = "vault:"
+	PassphraseEnv     string = "GOGPT_PASSPHRASE"
+)
+
+// ReadPassphrase asks for the passphrase of the secrets file, when GOGPT_PASSPHRASE is not set.
+var ReadPassphrase = func() (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("%s is not set", PassphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, "passphrase of the secrets file: ")
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+// IsSecretRef tells if value refers to a secret instead of holding it.
+func IsSecretRef(value string) bool {
+	for _, p := range []string{SecretEnvPrefix, SecretCmdPrefix, SecretVaultPrefix} {
+		if strings.HasPrefix(value, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckSecretRef checks the syntax of a secret reference, plain values are valid.
+func CheckSecretRef(value string) error {
+	if !IsSecretRef(value) {
+		return nil
+	}
+	if _, rest, _ := strings.Cut(value, ":"); strings.TrimSpace(rest) == "" {
+		return fmt.Errorf("empty secret reference: %s", value)
+	}
+	return nil
+}
+
+// secretCache holds resolved secrets and the opened vault, shared by copies of the config.
+// Secrets are resolved one at a time, so a command or a passphrase prompt runs once.
+type secretCache struct {
+	lock   sync.Mutex
+	values map[string]string
+	vault  *Vault
+	locked bool // commands and the passphrase prompt need the terminal, see LockSecrets.
+}
+
+// LockSecrets stops running secret commands and asking for the passphrase, once the TUI owns the terminal.
+// Resolved secrets and environment variables are still used.
+func (that *Config) LockSecrets() {
+	cache := that.secretCache()
+	cache.lock.Lock()
+	cache.locked = true
+	cache.lock.Unlock()
+}
+
+func (that *Config) secretCache() *secretCache {
+	if that.secrets == nil {
+		that.secrets = &secretCache{values: map[string]string{}}
+	}
+	return that.secrets
+}
+
+// Secret resolves a secret reference, resolved values are cached for the process.
+func (that *Config) Secret(value string) (string, error) {
+	if !IsSecretRef(value) {
+		return value, nil
+	}
+	cache := that.secretCache()
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if v, ok := cache.values[value]; ok {
+		return v, nil
+	}
+	if err := CheckSecretRef(value); err != nil {
+		return "", err
+	}
+	if cache.locked && strings.HasPrefix(value, SecretCmdPrefix) {
+		return "", fmt.Errorf("secret %s was not resolved when gogptm started, fix it and restart gogptm", value)
+	}
+	var (
+		v   string
+		err error
+	)
+	switch {
+	case strings.HasPrefix(value, SecretEnvPrefix):
+		name := strings.TrimPrefix(value, SecretEnvPrefix)
+		var ok bool
+		if v, ok = os.LookupEnv(name); !ok {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+	case strings.HasPrefix(value, SecretCmdPrefix):
+		v, err = runSecretCmd(strings.TrimPrefix(value, SecretCmdPrefix))
+	default:
+		name := strings.TrimPrefix(value, SecretVaultPrefix)
+		var vault *Vault
+		if vault, err = that.openVault(cache); err == nil {
+			var ok bool
+			if v, ok = vault.Get(name); !ok {
+				err = fmt.Errorf("secret %q is not in %s", name, SecretsFileName)
+			}
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	cache.values[value] = v
+	return v, nil
+}
+
+func runSecretCmd(command string) (string, error) {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.Command("cmd", "/C", command)
+	} else {
+		c = exec.Command("sh", "-c", command)
+	}
+	c.Stdin = os.Stdin
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("secret command %q failed: %w", command, err)
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	return strings.TrimSpace(line), nil
+}
+
+// Vault opens the secrets file once, with GOGPT_PASSPHRASE or ReadPassphrase.
+func (that *Config) Vault() (*Vault, error) {
+	cache := that.secretCache()
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	return that.openVault(cache)
+}
+
+// openVault opens the secrets file, the cache is locked by the caller.
+func (that *Config) openVault(cache *secretCache) (*Vault, error) {
+	if cache.vault != nil {
+		return cache.vault, nil
+	}
+	passphrase, ok := os.LookupEnv(PassphraseEnv)
+	if !ok && cache.locked {
+		return nil, fmt.Errorf("secrets file is locked, set %s or restart gogptm", PassphraseEnv)
+	}
+	if !ok {
+		var err error
+		if passphrase, err = ReadPassphrase(); err != nil {
+			return nil, err
+		}
+	}
+	vault, err := OpenVault(that.dirs.Config, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	cache.vault = vault
+	return vault, nil
+}
+
+// ResolveSecrets resolves secrets of the active, the default and all other profiles ahead,
+// so commands and passphrase prompts run before the TUI starts.
+func (that *Config) ResolveSecrets() error {
+	errList := []error{}
+	seen := map[string]bool{}
+	profiles := []Profile{that.sections(), that.defaults}
+	for _, p := range that.Profiles {
+		profiles = append(profiles, *p)
+	}
+	for _, p := range profiles {
+		k := p.settings()
+		for _, s := range Settings {
+			value := k.String(s.Path)
+			if !s.Secret || value == "" || seen[value] {
+				continue
+			}
+			seen[value] = true
+			if _, err := that.Secret(value); err != nil {
+				errList = append(errList, err)
+			}
+		}
+	}
+	return errors.Join(errList...)
+}
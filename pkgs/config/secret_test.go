@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSecretSharedByCopies(t *testing.T) {
+	cfg := NewConf(NewDirs(t.TempDir()))
+	for i := 0; i < 4; i++ {
+		t.Setenv(fmt.Sprintf("GOGPT_TEST_KEY_%d", i), fmt.Sprintf("key-%d", i))
+	}
+
+	// copies of the config resolve secrets in parallel, like the targets of the compare mode.
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		c := cfg.Copy()
+		ref := fmt.Sprintf("%sGOGPT_TEST_KEY_%d", SecretEnvPrefix, i%4)
+		want := fmt.Sprintf("key-%d", i%4)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.Secret(ref)
+			if err == nil && v != want {
+				err = fmt.Errorf("%s resolved to %q, want %q", ref, v, want)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	// values resolved by a copy are cached for the original.
+	t.Setenv("GOGPT_TEST_KEY_0", "changed")
+	if v, _ := cfg.Secret(SecretEnvPrefix + "GOGPT_TEST_KEY_0"); v != "key-0" {
+		t.Errorf("cached secret = %q, want key-0", v)
+	}
+}
+
+func TestResolveSecretsBeforeLock(t *testing.T) {
+	cfg := newTestProfileConf(t)
+	t.Setenv("AZURE_KEY", "azure-key")
+	t.Setenv(PassphraseEnv, "")
+	os.Unsetenv(PassphraseEnv)
+	cfg.Profiles["local"].GLM = &GLMConf{ApiKey: "cmd:echo glm-key"}
+
+	// secrets of profiles other than the active one are resolved too.
+	if err := cfg.ResolveSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.LockSecrets()
+	t.Setenv("AZURE_KEY", "changed")
+	if v, err := cfg.Secret("env:AZURE_KEY"); err != nil || v != "azure-key" {
+		t.Errorf("env:AZURE_KEY = %q, %v, want the resolved azure-key", v, err)
+	}
+	if v, err := cfg.Copy().Secret("cmd:echo glm-key"); err != nil || v != "glm-key" {
+		t.Errorf("cmd:echo glm-key = %q, %v, want the resolved glm-key", v, err)
+	}
+
+	// commands and the passphrase prompt are not used any more.
+	if _, err := cfg.Secret("cmd:echo other"); err == nil {
+		t.Error("an unresolved command ran after LockSecrets")
+	}
+	if _, err := cfg.Secret("vault:openai"); err == nil || !strings.Contains(err.Error(), PassphraseEnv) {
+		t.Errorf("vault:openai error = %v, want a hint to set %s", err, PassphraseEnv)
+	}
+	t.Setenv("GOGPT_TEST_KEY", "plain")
+	if v, err := cfg.Secret("env:GOGPT_TEST_KEY"); err != nil || v != "plain" {
+		t.Errorf("env:GOGPT_TEST_KEY = %q, %v, want plain", v, err)
+	}
+}
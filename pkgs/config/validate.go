@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+/*
+Validation of values entered in the configuration form.
+An empty value is always valid, it unsets the field or falls back to the default.
+*/
+type Range struct {
+	Min float64
+	Max float64
+}
+
+func (that Range) String() string {
+	return fmt.Sprintf("[%v, %v]", that.Min, that.Max)
+}
+
+var (
+	OpenAITemperatureRange = Range{Min: 0, Max: 2}
+	SparkTemperatureRange  = Range{Min: 0, Max: 1}
+	SparkTopKRange         = Range{Min: 1, Max: 6}
+	SparkMaxTokensRange    = Range{Min: 1, Max: 8192}
+	ClaudeTemperatureRange = Range{Min: 0, Max: 1}
+	ClaudeMaxTokensRange   = Range{Min: 1, Max: 8192}
+	GeminiTemperatureRange = Range{Min: 0, Max: 2}
+	GeminiMaxTokensRange   = Range{Min: 1, Max: 8192}
+	OllamaTemperatureRange = Range{Min: 0, Max: 2}
+	OllamaNumCtxRange      = Range{Min: 1, Max: 1 << 20}
+	QwenTemperatureRange   = Range{Min: 0, Max: 1.99}
+	QwenMaxTokensRange     = Range{Min: 1, Max: 8192}
+	ErnieTemperatureRange  = Range{Min: 0, Max: 1}
+	ErnieMaxTokensRange    = Range{Min: 2, Max: 4096}
+	GLMTemperatureRange    = Range{Min: 0, Max: 1}
+	GLMMaxTokensRange      = Range{Min: 1, Max: 8192}
+	RouterAttemptsRange    = Range{Min: 1, Max: 10}
+	RouterBackoffRange     = Range{Min: 0, Max: 600}
+	MaxTokensRange         = Range{Min: 1, Max: 128000}
+	ContextLenRange        = Range{Min: 1, Max: 100}
+	InputMaxHeightRange    = Range{Min: 1, Max: 50}
+	TimeoutRange           = Range{Min: 1, Max: 3600}
+	UintRange              = Range{Min: 0, Max: 1 << 16}
+)
+
+// CheckURL checks an absolute url with a host, schemes limits the allowed schemes.
+func CheckURL(value string, schemes ...string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid url: %s", value)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("url needs a scheme and a host, like https://example.com")
+	}
+	if len(schemes) == 0 {
+		return nil
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported scheme %q, use one of %s", u.Scheme, strings.Join(schemes, ", "))
+}
+
+// CheckProxy checks a http, https or socks5 proxy url.
+func CheckProxy(value string) error {
+	if err := CheckURL(value, "http", "https", "socks5"); err != nil {
+		return err
+	}
+	if value = strings.TrimSpace(value); value != "" {
+		u, _ := url.Parse(value)
+		if p := u.Port(); p != "" {
+			if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+				return fmt.Errorf("invalid proxy port: %s", p)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckInt checks an integer in range r.
+func CheckInt(value string, r Range) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("not an integer: %s", value)
+	}
+	if float64(n) < r.Min || float64(n) > r.Max {
+		return fmt.Errorf("out of range %s: %d", r, n)
+	}
+	return nil
+}
+
+// CheckFloat checks a number in range r.
+func CheckFloat(value string, r Range) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("not a number: %s", value)
+	}
+	if f < r.Min || f > r.Max {
+		return fmt.Errorf("out of range %s: %v", r, f)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package config
+
+import "testing"
+
+func TestCheckFloat(t *testing.T) {
+	for value, ok := range map[string]bool{
+		"":     true,
+		"0.2":  true,
+		" 2 ":  true,
+		"2.1":  false,
+		"-1":   false,
+		"abc":  false,
+		"NaN":  false,
+		"nan":  false,
+		"Inf":  false,
+		"-Inf": false,
+	} {
+		if err := CheckFloat(value, OpenAITemperatureRange); (err == nil) != ok {
+			t.Errorf("CheckFloat(%q): %v", value, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+Encrypted secrets file in the work dir.
+
+Secrets are a json object encrypted with AES-256-GCM, the key is derived from a passphrase by scrypt.
+*/
+const (
+	SecretsFileName string = "gogpt_secrets.enc"
+	vaultVersion    int    = 1
+)
+
+var ErrWrongPassphrase = errors.New("wrong passphrase or broken secrets file")
+
+type vaultFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+type Vault struct {
+	path       string
+	passphrase string
+	items      map[string]string
+}
+
+// OpenVault decrypts the secrets file in workDir, a missing file is an empty vault.
+func OpenVault(workDir, passphrase string) (v *Vault, err error) {
+	v = &Vault{
+		path:       filepath.Join(workDir, SecretsFileName),
+		passphrase: passphrase,
+		items:      map[string]string{},
+	}
+	content, err := os.ReadFile(v.path)
+	if os.IsNotExist(err) {
+		return v, nil
+	} else if err != nil {
+		return nil, err
+	}
+	f := &vaultFile{}
+	if err = json.Unmarshal(content, f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", v.path, err)
+	}
+	if f.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported secrets file version: %d", f.Version)
+	}
+	gcm, err := newGCM(passphrase, f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if err = json.Unmarshal(plain, &v.items); err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return v, nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (that *Vault) Get(name string) (value string, ok bool) {
+	value, ok = that.items[name]
+	return
+}
+
+func (that *Vault) Set(name, value string) {
+	that.items[name] = value
+}
+
+func (that *Vault) Delete(name string) {
+	delete(that.items, name)
+}
+
+func (that *Vault) Names() (names []string) {
+	for name := range that.items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Save encrypts the secrets with a new salt and nonce.
+func (that *Vault) Save() error {
+	plain, err := json.Marshal(that.items)
+	if err != nil {
+		return err
+	}
+	f := &vaultFile{Version: vaultVersion, Salt: make([]byte, 16)}
+	if _, err = rand.Read(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(that.passphrase, f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Data = gcm.Seal(nil, f.Nonce, plain, nil)
+	content, err := json.MarshalIndent(f, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err = PrivateFile(that.path); err != nil {
+		return err
+	}
+	return os.WriteFile(that.path, content, 0600)
+}
+
+// PrivateFile creates fPath or restricts an existing one to the owner, before writing secrets.
+func PrivateFile(fPath string) error {
+	if _, err := os.Stat(fPath); err == nil {
+		return os.Chmod(fPath, 0600)
+	}
+	f, err := os.OpenFile(fPath, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,494 @@
+package conversation
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/gvcgo/goutils/pkgs/gutils"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Attach local files and directories as conversation context.
+*/
+const (
+	MaxAttachFileSize int = 1 << 20 // larger files are skipped
+	AttachSummaryLine int = 20      // lines kept in a summary
+)
+
+type Attachment struct {
+	Path       string `koanf:"path" json:"path"`
+	Content    string `koanf:"content" json:"content"`
+	Tokens     int    `koanf:"tokens" json:"tokens"`
+	Summarized bool   `koanf:"summarized" json:"summarized"`
+}
+
+// Block returns the tagged context block of the attachment.
+func (that Attachment) Block() string {
+	if that.Summarized {
+		return fmt.Sprintf("<file path=%q summarized=\"true\">\n%s\n</file>", that.Path, that.Content)
+	}
+	return fmt.Sprintf("<file path=%q>\n%s\n</file>", that.Path, that.Content)
+}
+
+type AttachReport struct {
+	Added      []string
+	Summarized []string
+	Refused    []string
+	Skipped    []string
+	Tokens     int
+}
+
+func (that AttachReport) String() string {
+	r := fmt.Sprintf("attached %d files", len(that.Added)+len(that.Summarized))
+	if len(that.Summarized) > 0 {
+		r += fmt.Sprintf(", %d summarized", len(that.Summarized))
+	}
+	if len(that.Refused) > 0 {
+		r += fmt.Sprintf(", %d refused", len(that.Refused))
+	}
+	if len(that.Skipped) > 0 {
+		r += fmt.Sprintf(", %d skipped", len(that.Skipped))
+	}
+	return r + fmt.Sprintf(", +%d tokens", that.Tokens)
+}
+
+// ContextWindow returns the max tokens the model accepts, including the answer.
+func ContextWindow(botType string, cnf *config.Config) int {
+	if botType == BotSpark {
+		if cnf.Spark.APIVersion == config.SparkAPIV1 || cnf.Spark.APIVersion == "" {
+			return 4096
+		}
+		return 8192
+	}
+	switch botType {
+	case BotClaude:
+		return 200000
+	case BotGemini:
+		if strings.Contains(cnf.Gemini.Model, "gemini-1.0") {
+			return 30720
+		}
+		return 1048576
+	case BotOllama:
+		if cnf.Ollama.NumCtx > 0 {
+			return cnf.Ollama.NumCtx
+		}
+		return 2048 // default num_ctx of ollama
+	case BotQwen:
+		if strings.Contains(cnf.Qwen.Model, "plus") || strings.Contains(cnf.Qwen.Model, "longcontext") {
+			return 32768
+		}
+		return 8192
+	case BotErnie:
+		if strings.Contains(cnf.Ernie.Model, "128k") {
+			return 128000
+		}
+		return 8192
+	case BotGLM:
+		return 128000
+	case BotRouter:
+		// the smallest window of the routes.
+		window := 0
+		for _, r := range cnf.Router.Routes {
+			if bot := canonicalBot(r.Bot); bot == BotRouter {
+				continue
+			} else if w := ContextWindow(bot, cnf); window == 0 || w < window {
+				window = w
+			}
+		}
+		if window > 0 {
+			return window
+		}
+	}
+	model := cnf.OpenAI.Model
+	switch {
+	case strings.HasPrefix(model, openai.GPT4TurboPreview), strings.HasPrefix(model, openai.GPT4VisionPreview):
+		return 128000
+	case strings.Contains(model, "32k"):
+		return 32768
+	case strings.HasPrefix(model, openai.GPT4):
+		return 8192
+	case strings.Contains(model, "16k"), model == openai.GPT3Dot5Turbo1106:
+		return 16385
+	default:
+		return 4096
+	}
+}
+
+// canonicalBot returns the bot name as the constants, names in routes are case insensitive.
+func canonicalBot(name string) string {
+	for _, bot := range []string{BotGPT, BotSpark, BotClaude, BotGemini, BotOllama, BotQwen, BotErnie, BotGLM, BotRouter} {
+		if strings.EqualFold(bot, name) {
+			return bot
+		}
+	}
+	return name
+}
+
+// ReservedTokens returns the max tokens of the answer, 1024 if not set.
+func ReservedTokens(botType string, cnf *config.Config) (reserved int) {
+	reserved = cnf.OpenAI.MaxTokens
+	switch botType {
+	case BotSpark:
+		reserved = int(cnf.Spark.MaxTokens)
+	case BotClaude:
+		reserved = cnf.Anthropic.MaxTokens
+	case BotGemini:
+		reserved = cnf.Gemini.MaxTokens
+	case BotQwen:
+		reserved = cnf.Qwen.MaxTokens
+	case BotErnie:
+		reserved = cnf.Ernie.MaxTokens
+	case BotGLM:
+		reserved = cnf.GLM.MaxTokens
+	case BotRouter:
+		// the largest answer of the routes.
+		reserved = 0
+		for _, r := range cnf.Router.Routes {
+			if bot := canonicalBot(r.Bot); bot == BotRouter {
+				continue
+			} else if t := ReservedTokens(bot, cnf); t > reserved {
+				reserved = t
+			}
+		}
+	}
+	if reserved == 0 {
+		reserved = 1024
+	}
+	return
+}
+
+// Attach reads files, directories or globs and adds them to the conversation context.
+func (that *Conversation) Attach(patterns ...string) (report AttachReport, err error) {
+	files := []string{}
+	for _, p := range patterns {
+		var found []string
+		if found, err = CollectFiles(p); err != nil {
+			return
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return report, fmt.Errorf("no file found: %s", strings.Join(patterns, " "))
+	}
+
+	model := that.tokenModel()
+	budget := ContextWindow(that.BotType, that.CNF) - ReservedTokens(that.BotType, that.CNF) - NumTokensFromMessages(that.GetMessages(), model)
+
+	for _, fPath := range files {
+		content, rErr := readTextFile(fPath)
+		if rErr != nil {
+			report.Skipped = append(report.Skipped, fPath)
+			continue
+		}
+		a := Attachment{Path: fPath, Content: content}
+		a.Tokens = attachmentTokens(a, model)
+		if a.Tokens > budget {
+			a = summarizeAttachment(a)
+			a.Tokens = attachmentTokens(a, model)
+			if a.Tokens > budget {
+				report.Refused = append(report.Refused, fPath)
+				continue
+			}
+			report.Summarized = append(report.Summarized, fPath)
+		} else {
+			report.Added = append(report.Added, fPath)
+		}
+		budget -= a.Tokens
+		report.Tokens += a.Tokens
+		that.Attachments = append(that.Attachments, a)
+	}
+	return
+}
+
+// AttachmentsMessage returns the tagged context blocks as one message.
+func (that *Conversation) AttachmentsMessage() (msg openai.ChatCompletionMessage, ok bool) {
+	if len(that.Attachments) == 0 {
+		return
+	}
+	blocks := []string{"The following files are attached as context:"}
+	for _, a := range that.Attachments {
+		blocks = append(blocks, a.Block())
+	}
+	return openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: strings.Join(blocks, "\n\n"),
+	}, true
+}
+
+func (that *Conversation) Detach() {
+	that.Attachments = []Attachment{}
+}
+
+func (that *Conversation) tokenModel() string {
+	if that.BotType == BotGPT && that.CNF.OpenAI.Model != "" {
+		return that.CNF.OpenAI.Model
+	}
+	// estimate with cl100k for other bots.
+	return openai.GPT3Dot5Turbo
+}
+
+func attachmentTokens(a Attachment, model string) int {
+	block := a.Block()
+	if n := NumTokensFromMessages([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: block},
+	}, model); n > 0 {
+		return n
+	}
+	// no encoding available, about 4 bytes per token.
+	return len(block)/4 + 1
+}
+
+func summarizeAttachment(a Attachment) Attachment {
+	lines := strings.Split(a.Content, "\n")
+	head := lines
+	if len(head) > AttachSummaryLine {
+		head = head[:AttachSummaryLine]
+	}
+	a.Content = fmt.Sprintf(
+		"%d lines, %d bytes, only the first %d lines are shown:\n%s",
+		len(lines), len(a.Content), len(head), strings.Join(head, "\n"),
+	)
+	a.Summarized = true
+	return a
+}
+
+func readTextFile(fPath string) (string, error) {
+	info, err := os.Stat(fPath)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > int64(MaxAttachFileSize) {
+		return "", fmt.Errorf("file too large: %s", fPath)
+	}
+	content, err := os.ReadFile(fPath)
+	if err != nil {
+		return "", err
+	}
+	head := content
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return "", fmt.Errorf("binary file: %s", fPath)
+	}
+	return string(content), nil
+}
+
+// CollectFiles expands a file, a directory or a glob to file paths, files ignored by git are left out.
+func CollectFiles(pattern string) (files []string, err error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no such file: %s", pattern)
+	}
+	for _, m := range matches {
+		info, sErr := os.Stat(m)
+		if sErr != nil {
+			continue
+		}
+		ignore := NewGitIgnore(m)
+		if !info.IsDir() {
+			if !ignore.Match(m, false) {
+				files = append(files, m)
+			}
+			continue
+		}
+		filepath.WalkDir(m, func(p string, d fs.DirEntry, wErr error) error {
+			if wErr != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" || (p != m && ignore.Match(p, true)) {
+					return filepath.SkipDir
+				}
+				ignore.LoadDir(p)
+				return nil
+			}
+			if !ignore.Match(p, false) {
+				files = append(files, p)
+			}
+			return nil
+		})
+	}
+	return
+}
+
+/*
+A small .gitignore matcher.
+*/
+type ignoreRule struct {
+	base     string // dir of the .gitignore file
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+type GitIgnore struct {
+	rules  []ignoreRule
+	loaded map[string]bool
+	root   string // first dir loaded, the git root if any
+}
+
+// NewGitIgnore loads .gitignore files from the git root down to the dir of path.
+func NewGitIgnore(path string) (gi *GitIgnore) {
+	gi = &GitIgnore{loaded: map[string]bool{}}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	if info, err := os.Stat(abs); err == nil && !info.IsDir() {
+		abs = filepath.Dir(abs)
+	}
+	dirs := []string{}
+	for d := abs; ; d = filepath.Dir(d) {
+		dirs = append([]string{d}, dirs...)
+		if ok, _ := gutils.PathIsExist(filepath.Join(d, ".git")); ok {
+			break
+		}
+		if filepath.Dir(d) == d {
+			// not in a git repo
+			dirs = []string{abs}
+			break
+		}
+	}
+	for _, d := range dirs {
+		gi.LoadDir(d)
+	}
+	return
+}
+
+func (that *GitIgnore) LoadDir(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil || that.loaded[abs] {
+		return
+	}
+	that.loaded[abs] = true
+	if that.root == "" {
+		that.root = abs
+	}
+	f, err := os.Open(filepath.Join(abs, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: abs}
+		if strings.HasPrefix(line, `\`) {
+			// escaped "#" or "!"
+			line = line[1:]
+		} else if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		rule.pattern = line
+		that.rules = append(that.rules, rule)
+	}
+}
+
+// Match reports whether path is ignored, the last matched rule wins.
+// Like git, a file in an ignored dir can not be included again.
+func (that *GitIgnore) Match(path string, isDir bool) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if rel, err := filepath.Rel(that.root, abs); that.root != "" && err == nil && !isOutside(rel) && rel != "." {
+		dir := that.root
+		parts := strings.Split(rel, string(filepath.Separator))
+		for _, part := range parts[:len(parts)-1] {
+			dir = filepath.Join(dir, part)
+			if that.match(dir, true) {
+				return true
+			}
+		}
+	}
+	return that.match(abs, isDir)
+}
+
+func (that *GitIgnore) match(abs string, isDir bool) (ignored bool) {
+	for _, r := range that.rules {
+		rel, err := filepath.Rel(r.base, abs)
+		if err != nil || isOutside(rel) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if r.match(rel, isDir) {
+			ignored = !r.negate
+		}
+	}
+	return
+}
+
+// isOutside reports whether a relative path leaves its base dir.
+func isOutside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (that ignoreRule) match(rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	if that.anchored {
+		return matchPattern(strings.Split(that.pattern, "/"), parts, isDir, that.dirOnly)
+	}
+	// unanchored patterns match any path element, and everything below it.
+	for i, part := range parts {
+		if ok, _ := filepath.Match(that.pattern, part); ok {
+			last := i == len(parts)-1
+			if !that.dirOnly || !last || isDir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern, parts []string, isDir, dirOnly bool) bool {
+	if len(pattern) == 0 {
+		// a matched dir ignores everything below it.
+		return len(parts) > 0 || isDir || !dirOnly
+	}
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			// a trailing "/**" matches everything inside, but not the dir itself.
+			return len(parts) > 0
+		}
+		for i := 0; i <= len(parts); i++ {
+			if matchPattern(pattern[1:], parts[i:], isDir, dirOnly) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	if len(pattern) == 1 && len(parts) == 1 {
+		return !dirOnly || isDir
+	}
+	return matchPattern(pattern[1:], parts[1:], isDir, dirOnly)
+}
@@ -0,0 +1,142 @@
+package conversation
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		fPath := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fPath), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const testGitIgnore = `# comment
+*.log
+!keep.log
+/build
+out/
+docs/*.md
+**/cache
+assets/**
+a/**/z.txt
+\#hash
+\!bang
+logs/
+!logs/keep.txt
+*.sample
+`
+
+func TestGitIgnoreMatch(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), os.ModePerm)
+	writeTestFiles(t, root, map[string]string{
+		".gitignore":     testGitIgnore,
+		"sub/.gitignore": "local.txt\n/anchored.txt\n",
+	})
+	gi := NewGitIgnore(root)
+	gi.LoadDir(filepath.Join(root, "sub"))
+
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"sub/x/app.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build/a.go", false, true},
+		{"sub/build", true, false},
+		{"out", true, true},
+		{"out", false, false},
+		{"sub/out/a.go", false, true},
+		{"docs/a.md", false, true},
+		{"docs/x/a.md", false, false},
+		{"cache", true, true},
+		{"sub/deep/cache/f.go", false, true},
+		{"assets", true, false},
+		{"assets/img/a.png", false, true},
+		{"a/z.txt", false, true},
+		{"a/b/c/z.txt", false, true},
+		{"b/z.txt", false, false},
+		{"#hash", false, true},
+		{"!bang", false, true},
+		{"logs/keep.txt", false, true},
+		{"..env.sample", false, true},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"sub/anchored.txt", false, true},
+		{"sub/y/anchored.txt", false, false},
+		{"main.go", false, false},
+	}
+	for _, c := range cases {
+		if got := gi.Match(filepath.Join(root, filepath.FromSlash(c.path)), c.isDir); got != c.want {
+			t.Errorf("Match(%q, dir %v) = %v, want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), os.ModePerm)
+	writeTestFiles(t, root, map[string]string{
+		".gitignore":          "*.log\nbuild/\n",
+		"main.go":             "package main",
+		"debug.log":           "log",
+		"build/out.go":        "package build",
+		"pkg/.gitignore":      "gen.go\n",
+		"pkg/pkg.go":          "package pkg",
+		"pkg/gen.go":          "package pkg",
+		"..env.sample":        "KEY=",
+		".git/config.example": "[core]",
+	})
+	files, err := CollectFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, f := range files {
+		rel, _ := filepath.Rel(root, f)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	want := []string{"..env.sample", ".gitignore", "main.go", "pkg/.gitignore", "pkg/pkg.go"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("collected %v, want %v", got, want)
+	}
+}
+
+func TestAttachmentsSaved(t *testing.T) {
+	conv := newTestConversation(t, "a")
+	fPath := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(fPath, []byte("attached notes"), 0644)
+	if _, err := conv.Attach(fPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := conv.Save(); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(fPath, []byte("changed later"), 0644)
+
+	loaded := NewConversation(conv.CNF)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Attachments) != 1 || loaded.Attachments[0].Path != fPath || loaded.Attachments[0].Content != "attached notes" {
+		t.Fatalf("attachments after load: %+v", loaded.Attachments)
+	}
+	if msg, ok := loaded.AttachmentsMessage(); !ok || !strings.Contains(msg.Content, "attached notes") {
+		t.Errorf("attachments message after load: %q", msg.Content)
+	}
+}
@@ -0,0 +1,271 @@
+package conversation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+Extract fenced code blocks from answers.
+*/
+var LangExtMap map[string]string = map[string]string{
+	"go":         ".go",
+	"golang":     ".go",
+	"python":     ".py",
+	"py":         ".py",
+	"javascript": ".js",
+	"js":         ".js",
+	"typescript": ".ts",
+	"ts":         ".ts",
+	"jsx":        ".jsx",
+	"tsx":        ".tsx",
+	"java":       ".java",
+	"kotlin":     ".kt",
+	"kt":         ".kt",
+	"swift":      ".swift",
+	"c":          ".c",
+	"cpp":        ".cpp",
+	"c++":        ".cpp",
+	"csharp":     ".cs",
+	"cs":         ".cs",
+	"c#":         ".cs",
+	"rust":       ".rs",
+	"rs":         ".rs",
+	"ruby":       ".rb",
+	"rb":         ".rb",
+	"php":        ".php",
+	"lua":        ".lua",
+	"bash":       ".sh",
+	"sh":         ".sh",
+	"shell":      ".sh",
+	"zsh":        ".sh",
+	"powershell": ".ps1",
+	"ps1":        ".ps1",
+	"sql":        ".sql",
+	"html":       ".html",
+	"css":        ".css",
+	"json":       ".json",
+	"yaml":       ".yaml",
+	"yml":        ".yaml",
+	"toml":       ".toml",
+	"xml":        ".xml",
+	"markdown":   ".md",
+	"md":         ".md",
+	"text":       ".txt",
+	"txt":        ".txt",
+}
+
+// languages whose files are usually named without an extension.
+var LangFileNameMap map[string]string = map[string]string{
+	"dockerfile": "Dockerfile",
+	"makefile":   "Makefile",
+	"make":       "Makefile",
+}
+
+var filePathRegexp = regexp.MustCompile("(?:[\\w.-]+/)*[\\w-]+\\.[A-Za-z0-9]{1,6}")
+
+var numberedRegexp = regexp.MustCompile(`^(.+)_\d+$`)
+
+type CodeBlock struct {
+	Lang     string
+	Code     string
+	Filename string // suggested file name
+}
+
+func (that CodeBlock) Lines() int {
+	return strings.Count(that.Code, "\n") + 1
+}
+
+// ExtractCodeBlocks finds fenced code blocks in a markdown answer.
+func ExtractCodeBlocks(answer string) (blocks []CodeBlock) {
+	var (
+		inBlock bool
+		fence   string
+		prose   []string
+		code    []string
+		block   CodeBlock
+	)
+	used := map[string]bool{}
+	for _, line := range strings.Split(strings.ReplaceAll(answer, "\r\n", "\n"), "\n") {
+		trimed := strings.TrimSpace(line)
+		if !inBlock {
+			if strings.HasPrefix(trimed, "```") || strings.HasPrefix(trimed, "~~~") {
+				inBlock = true
+				fence = trimed[:3]
+				block = CodeBlock{}
+				if fields := strings.Fields(strings.TrimLeft(trimed, fence[:1])); len(fields) > 0 {
+					block.Lang = strings.ToLower(fields[0])
+				}
+				code = []string{}
+				continue
+			}
+			prose = append(prose, line)
+			continue
+		}
+		if strings.HasPrefix(trimed, fence) && strings.Trim(trimed, fence[:1]) == "" {
+			inBlock = false
+			block.Code = strings.Join(code, "\n")
+			block.Filename = suggestFilename(block, strings.Join(prose, "\n"), len(blocks)+1)
+			block.Filename = UniqueFilename(block.Filename, func(name string) bool { return used[name] })
+			used[block.Filename] = true
+			blocks = append(blocks, block)
+			prose = []string{}
+			continue
+		}
+		code = append(code, line)
+	}
+	return
+}
+
+// UniqueFilename returns name, or name numbered like main_2.go if it is taken,
+// a numbered name is numbered again from its base name.
+func UniqueFilename(name string, taken func(name string) bool) string {
+	if !taken(name) {
+		return name
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if m := numberedRegexp.FindStringSubmatch(base); m != nil {
+		base = m[1]
+	}
+	for n := 2; ; n++ {
+		if newName := fmt.Sprintf("%s_%d%s", base, n, ext); !taken(newName) {
+			return newName
+		}
+	}
+}
+
+func suggestFilename(block CodeBlock, prose string, index int) string {
+	ext := LangExtMap[block.Lang]
+	if name, ok := LangFileNameMap[block.Lang]; ok {
+		return name
+	}
+	// a path given in the first line of code, like "// main.go" or "# app/run.py".
+	firstLine := strings.SplitN(block.Code, "\n", 2)[0]
+	if strings.HasPrefix(strings.TrimSpace(firstLine), "//") || strings.HasPrefix(strings.TrimSpace(firstLine), "#") {
+		if p := matchFilePath(firstLine, ext); p != "" {
+			return p
+		}
+	}
+	// the last path mentioned in the text right before the code block.
+	if p := matchFilePath(prose, ext); p != "" {
+		return p
+	}
+	if ext == "" {
+		ext = ".txt"
+	}
+	return fmt.Sprintf("snippet_%d%s", index, ext)
+}
+
+func matchFilePath(text, ext string) (p string) {
+	for _, m := range filePathRegexp.FindAllString(text, -1) {
+		mExt := strings.ToLower(filepath.Ext(m))
+		if ext != "" && mExt != ext {
+			continue
+		}
+		if ext == "" && !isKnownExt(mExt) {
+			continue
+		}
+		p = m
+	}
+	return
+}
+
+func isKnownExt(ext string) bool {
+	for _, e := range LangExtMap {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveCodePath joins name to dir, and refuses names that point outside of dir.
+func ResolveCodePath(dir, name string) (fPath string, err error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("file name is empty")
+	}
+	if filepath.IsAbs(name) {
+		return "", fmt.Errorf("absolute path is not allowed: %s", name)
+	}
+	fPath = filepath.Join(dir, filepath.Clean(name))
+	rel, err := filepath.Rel(dir, fPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path is outside of %s: %s", dir, name)
+	}
+	return
+}
+
+// SaveCodeBlock writes code to name under dir.
+func SaveCodeBlock(dir, name, code string) (fPath string, err error) {
+	if fPath, err = ResolveCodePath(dir, name); err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(fPath), os.ModePerm); err != nil {
+		return
+	}
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	err = os.WriteFile(fPath, []byte(code), 0644)
+	return
+}
+
+const maxDiffCells int = 4000000
+
+// DiffLines returns a line based diff between old and new, lines are prefixed with "- ", "+ " or "  ".
+func DiffLines(old, new string) (r []string) {
+	a := strings.Split(strings.TrimSuffix(old, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(new, "\n"), "\n")
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			r = append(r, "- "+l)
+		}
+		for _, l := range b {
+			r = append(r, "+ "+l)
+		}
+		return
+	}
+	// longest common subsequence
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			r = append(r, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			r = append(r, "- "+a[i])
+			i++
+		default:
+			r = append(r, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		r = append(r, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		r = append(r, "+ "+b[j])
+	}
+	return
+}
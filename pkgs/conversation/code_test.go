@@ -0,0 +1,36 @@
+package conversation
+
+import "testing"
+
+func TestExtractCodeBlocksDedupe(t *testing.T) {
+	answer := "```go\n// main.go\npackage a\n```\n" +
+		"```go\n// main.go\npackage b\n```\n" +
+		"```go\n// main_2.go\npackage c\n```\n" +
+		"```go\n// main.go\npackage d\n```\n"
+	blocks := ExtractCodeBlocks(answer)
+	want := []string{"main.go", "main_2.go", "main_3.go", "main_4.go"}
+	if len(blocks) != len(want) {
+		t.Fatalf("got %d blocks, want %d", len(blocks), len(want))
+	}
+	for i, b := range blocks {
+		if b.Filename != want[i] {
+			t.Errorf("block %d: got %q, want %q", i, b.Filename, want[i])
+		}
+	}
+}
+
+func TestUniqueFilename(t *testing.T) {
+	taken := map[string]bool{"main.go": true, "main_2.go": true, "Makefile": true, "a_b.py": true}
+	cases := map[string]string{
+		"util.go":   "util.go",
+		"main.go":   "main_3.go",
+		"main_2.go": "main_3.go",
+		"Makefile":  "Makefile_2",
+		"a_b.py":    "a_b_2.py",
+	}
+	for name, want := range cases {
+		if got := UniqueFilename(name, func(n string) bool { return taken[n] }); got != want {
+			t.Errorf("UniqueFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package conversation
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gvcgo/goutils/pkgs/koanfer"
 	"github.com/gvcgo/gogpt/pkgs/config"
@@ -13,31 +18,86 @@ Manage Chatgpt conversation
 */
 const (
 	ConversationFileName string = "gpt_conversation.json"
+	ConversationDirName  string = "conversations" // named sessions
+	ExportDirName        string = "exports"       // exported conversations
 	BotGPT               string = "ChatGPT"
 	BotSpark             string = "Spark"
+	BotClaude            string = "Claude"
+	BotGemini            string = "Gemini"
+	BotOllama            string = "Ollama"
+	BotQwen              string = "Qwen"
+	BotErnie             string = "ERNIE"
+	BotGLM               string = "GLM"
+	BotRouter            string = "Router"
 )
 
 type QuesAnsw struct {
-	Q string `koanf,json:"question"` // question
-	A string `koanf,json:"answer"`   // answer
+	Q        string       `koanf:"question" json:"question"` // question
+	A        string       `koanf:"answer" json:"answer"`     // answer
+	Branches [][]QuesAnsw `koanf:"branches" json:"branches"` // other continuations starting from this turn
+	Branch   int          `koanf:"branch" json:"branch"`     // index of this continuation among all continuations
+	Bot      string       `koanf:"bot" json:"bot"`           // backend answering, like Claude or Claude@work#2 for the router
+	Model    string       `koanf:"model" json:"model"`       // model answering, empty if unknown
+	replaced int          // continuation replaced by a new branch, restored if the new one fails
+}
+
+// Provenance returns the backend and the model of the answer, like "Claude, claude-3-opus".
+func (that QuesAnsw) Provenance() string {
+	if that.Model == "" {
+		return that.Bot
+	}
+	if that.Bot == "" {
+		return that.Model
+	}
+	return that.Bot + ", " + that.Model
+}
+
+// BranchCount returns the number of continuations starting from this turn.
+func (that QuesAnsw) BranchCount() int {
+	return len(that.Branches) + 1
+}
+
+// allBranches returns every continuation starting from the first turn of path, in a stable order.
+func allBranches(path []QuesAnsw) (all [][]QuesAnsw) {
+	if len(path) == 0 {
+		return
+	}
+	head := path[0]
+	cur := append([]QuesAnsw{}, path...)
+	cur[0].Branches = nil
+	cur[0].Branch = 0
+	idx := head.Branch
+	if idx < 0 || idx > len(head.Branches) {
+		idx = len(head.Branches)
+	}
+	all = append(all, head.Branches[:idx]...)
+	all = append(all, cur)
+	all = append(all, head.Branches[idx:]...)
+	return
 }
 
 type ConversationSaver struct {
-	QAList  []QuesAnsw `koanf,json:"qa_list"`
-	Prompt  string     `koanf,json:"prompt"`
-	BotType string     `koanf,json:"bot_type"`
+	Version int        `koanf:"version" json:"version"` // schema version, see ConversationMigrations.
+	QAList  []QuesAnsw `koanf:"qa_list" json:"qa_list"`
+	Prompt  string     `koanf:"prompt" json:"prompt"`
+	BotType string     `koanf:"bot_type" json:"bot_type"`
+	Profile string     `koanf:"profile" json:"profile"` // config profile used by the session
+	// files attached as context, kept as they were sent.
+	Attachments []Attachment `koanf:"attachments" json:"attachments"`
 }
 
 type Conversation struct {
 	Context []QuesAnsw
 	History []QuesAnsw
 	Current *QuesAnsw
-	Saver   *ConversationSaver `koanf,json:"conversation"`
+	Saver   *ConversationSaver `koanf:"conversation" json:"conversation"`
 	Tokens  int
 	CNF     *config.Config
 	Cursor  int
 	path    string
 	BotType string
+	// local files attached as context
+	Attachments []Attachment
 }
 
 func NewConversation(cnf *config.Config) (conv *Conversation) {
@@ -48,14 +108,14 @@ func NewConversation(cnf *config.Config) (conv *Conversation) {
 		Saver: &ConversationSaver{
 			QAList: []QuesAnsw{},
 		},
-		path: filepath.Join(cnf.GetWorkDir(), ConversationFileName),
+		path: filepath.Join(cnf.GetDataDir(), ConversationFileName),
 	}
 	return
 }
 
+// SetBotType switches the backend, the conversation continues on it.
 func (that *Conversation) SetBotType(botType string) {
 	that.BotType = botType
-	that.ClearAll()
 }
 
 func (that *Conversation) ClearAll() {
@@ -67,6 +127,7 @@ func (that *Conversation) ClearAll() {
 	}
 	that.Tokens = 0
 	that.Cursor = 0
+	that.Detach()
 }
 
 func (that *Conversation) AddQuestion(ques string) {
@@ -97,6 +158,15 @@ func (that *Conversation) AddAnswer(answ string, completed bool) {
 	}
 }
 
+// TagAnswer records the backend and the model answering the current question.
+func (that *Conversation) TagAnswer(bot, model string) {
+	if that.Current == nil {
+		return
+	}
+	that.Current.Bot = bot
+	that.Current.Model = model
+}
+
 func (that *Conversation) GetMessages() []openai.ChatCompletionMessage {
 	messages := make([]openai.ChatCompletionMessage, 0, 2*len(that.Context)+2)
 	messages = append(
@@ -105,6 +175,9 @@ func (that *Conversation) GetMessages() []openai.ChatCompletionMessage {
 			Content: that.CNF.OpenAI.PromptStr,
 		},
 	)
+	if msg, ok := that.AttachmentsMessage(); ok {
+		messages = append(messages, msg)
+	}
 	for _, c := range that.Context {
 		messages = append(
 			messages, openai.ChatCompletionMessage{
@@ -147,6 +220,7 @@ func (that *Conversation) ClearContext() {
 	that.History = append(that.History, that.Context...)
 	that.Context = []QuesAnsw{}
 	that.Tokens = 0
+	that.Detach()
 	that.ResetCursor()
 }
 
@@ -172,14 +246,10 @@ func (that *Conversation) GetQAByCursor() QuesAnsw {
 	if that.Cursor < len(that.History) {
 		return that.History[that.Cursor]
 	}
-
-	if that.Current == nil {
+	if that.Cursor < len(that.History)+len(that.Context) {
 		return that.Context[that.Cursor-len(that.History)]
-	} else if that.Cursor > 0 {
-		return that.Context[that.Cursor-len(that.History)-1]
-	} else {
-		return *that.Current
 	}
+	return *that.Current
 }
 
 func (that *Conversation) GetPrevQA() QuesAnsw {
@@ -192,32 +262,98 @@ func (that *Conversation) GetNextQA() QuesAnsw {
 	return that.GetQAByCursor()
 }
 
-func (that *Conversation) Save() {
-	that.Saver.QAList = append(that.Saver.QAList, that.History...)
-	that.Saver.QAList = append(that.Saver.QAList, that.Context...)
+// SessionPath returns the file of a named session, the default one for an empty name.
+func (that *Conversation) SessionPath(name string) string {
+	if name == "" {
+		return filepath.Join(that.CNF.GetDataDir(), ConversationFileName)
+	}
+	return filepath.Join(that.CNF.GetDataDir(), ConversationDirName, name+".json")
+}
+
+// SessionFile returns the file the conversation is saved to and loaded from.
+func (that *Conversation) SessionFile() string {
+	return that.path
+}
+
+// ListSessions returns the names of saved sessions.
+func (that *Conversation) ListSessions() (names []string) {
+	entries, _ := os.ReadDir(filepath.Join(that.CNF.GetDataDir(), ConversationDirName))
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	return
+}
+
+// CheckSessionName refuses names pointing outside of the sessions dir.
+func CheckSessionName(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid session name: %s", name)
+	}
+	return nil
+}
+
+func (that *Conversation) SaveAs(name string) error {
+	if err := CheckSessionName(name); err != nil {
+		return err
+	}
+	that.path = that.SessionPath(name)
+	return that.Save()
+}
+
+// LoadFrom loads a named session, the conversation is unchanged if it fails.
+func (that *Conversation) LoadFrom(name string) error {
+	if err := CheckSessionName(name); err != nil {
+		return err
+	}
+	old := that.path
+	that.path = that.SessionPath(name)
+	if err := that.Load(); err != nil {
+		that.path = old
+		return err
+	}
+	return nil
+}
+
+func (that *Conversation) Save() error {
+	that.Saver.QAList = that.Path()
 	that.Saver.Prompt = that.CNF.OpenAI.PromptStr
 	that.Saver.BotType = that.BotType
-	if k, err := koanfer.NewKoanfer(that.path); err == nil {
-		k.Save(that.Saver)
+	that.Saver.Profile = that.CNF.Profile()
+	that.Saver.Attachments = that.Attachments
+	that.Saver.Version = ConversationSchemaVersion
+	k, err := koanfer.NewKoanfer(that.path)
+	if err != nil {
+		return err
+	}
+	// questions and attached files are kept private like the config.
+	if err = config.PrivateFile(that.path); err != nil {
+		return err
 	}
+	return k.Save(that.Saver)
 }
 
-func (that *Conversation) Load() {
-	if k, err := koanfer.NewKoanfer(that.path); err == nil {
-		err = k.Load(that.Saver)
-		if err != nil {
-			return
-		}
-		that.CNF.OpenAI.PromptStr = that.Saver.Prompt
-		total := len(that.Saver.QAList)
-		if total > that.CNF.OpenAI.ContextLen {
-			that.Context = that.Saver.QAList[total-that.CNF.OpenAI.ContextLen:]
-			that.History = that.Saver.QAList[:total-that.CNF.OpenAI.ContextLen]
-		} else {
-			that.Context = that.Saver.QAList
-		}
-		that.BotType = that.Saver.BotType
+func (that *Conversation) Load() error {
+	if err := config.MigrateFile(that.path, ConversationMigrations); err != nil {
+		return err
+	}
+	k, err := koanfer.NewKoanfer(that.path)
+	if err != nil {
+		return err
 	}
+	saver := &ConversationSaver{QAList: []QuesAnsw{}}
+	if err = k.Load(saver); err != nil {
+		return fmt.Errorf("load %s: %w", that.path, err)
+	}
+	that.Saver = saver
+	that.CNF.OpenAI.PromptStr = that.Saver.Prompt
+	that.Current = nil
+	that.setPath(that.Saver.QAList, 0)
+	that.BotType = that.Saver.BotType
+	that.Attachments = that.Saver.Attachments
+	that.ResetCursor()
+	return nil
 }
 
 func (that *Conversation) ClearCurrentAnswer() {
@@ -225,3 +361,145 @@ func (that *Conversation) ClearCurrentAnswer() {
 		that.Current.A = ""
 	}
 }
+
+// Path returns all completed turns on the current branch.
+func (that *Conversation) Path() []QuesAnsw {
+	path := make([]QuesAnsw, 0, len(that.History)+len(that.Context))
+	path = append(path, that.History...)
+	return append(path, that.Context...)
+}
+
+// setPath replaces the completed turns, the first histLen turns stay out of context.
+func (that *Conversation) setPath(path []QuesAnsw, histLen int) {
+	if histLen > len(path) {
+		histLen = len(path)
+	}
+	if ctxStart := len(path) - that.CNF.OpenAI.ContextLen; ctxStart > histLen {
+		histLen = ctxStart
+	}
+	that.History = append([]QuesAnsw{}, path[:histLen]...)
+	that.Context = append([]QuesAnsw{}, path[histLen:]...)
+}
+
+// histLenFor keeps cleared turns out of context, unless the change starts among them.
+func (that *Conversation) histLenFor(idx int) int {
+	if idx <= len(that.History) {
+		return 0
+	}
+	return len(that.History)
+}
+
+// Regenerate asks the last question again, the old answer is kept as a branch.
+func (that *Conversation) Regenerate() bool {
+	path := that.Path()
+	if that.Current != nil || len(path) == 0 {
+		return false
+	}
+	return that.Branch(len(path)-1, path[len(path)-1].Q)
+}
+
+// Branch starts a new continuation at turn idx with question ques.
+// The old continuation from idx is kept as a branch of the new turn.
+func (that *Conversation) Branch(idx int, ques string) bool {
+	path := that.Path()
+	if that.Current != nil || idx < 0 || idx >= len(path) {
+		return false
+	}
+	replaced := path[idx].Branch
+	if replaced < 0 || replaced > len(path[idx].Branches) {
+		replaced = len(path[idx].Branches)
+	}
+	all := allBranches(path[idx:])
+	that.setPath(path[:idx], that.histLenFor(idx))
+	that.Current = &QuesAnsw{
+		Q:        ques,
+		Branches: all,
+		Branch:   len(all),
+		replaced: replaced,
+	}
+	that.Tokens = 0
+	that.ResetCursor()
+	return true
+}
+
+// DropCurrent gives up the current question, when its answer failed.
+// A failed regenerate or edit goes back to the continuation it replaced.
+func (that *Conversation) DropCurrent() {
+	cur := that.Current
+	if cur == nil {
+		return
+	}
+	that.Current = nil
+	if k := cur.replaced; len(cur.Branches) > 0 && k < len(cur.Branches) {
+		restored := append([]QuesAnsw{}, cur.Branches[k]...)
+		restored[0].Branches = append(append([][]QuesAnsw{}, cur.Branches[:k]...), cur.Branches[k+1:]...)
+		restored[0].Branch = k
+		that.setPath(append(that.Path(), restored...), len(that.History))
+	}
+	that.ResetCursor()
+}
+
+// SwitchBranch moves to another continuation at turn idx, step is usually 1 or -1.
+func (that *Conversation) SwitchBranch(idx int, step int) bool {
+	path := that.Path()
+	if that.Current != nil || idx < 0 || idx >= len(path) || len(path[idx].Branches) == 0 {
+		return false
+	}
+	all := allBranches(path[idx:])
+	k := ((path[idx].Branch+step)%len(all) + len(all)) % len(all)
+	chosen := append([]QuesAnsw{}, all[k]...)
+	chosen[0].Branches = append(append([][]QuesAnsw{}, all[:k]...), all[k+1:]...)
+	chosen[0].Branch = k
+	that.setPath(append(path[:idx], chosen...), that.histLenFor(idx))
+	that.Cursor = idx
+	return true
+}
+
+// Markdown exports the completed turns on the current branch.
+func (that *Conversation) Markdown() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("# Conversation with %s\n\n", that.BotType))
+	b.WriteString(fmt.Sprintf("Profile: %s\n\n", that.CNF.Profile()))
+	if that.CNF.OpenAI.PromptStr != "" {
+		b.WriteString(fmt.Sprintf("> %s\n\n", that.CNF.OpenAI.PromptStr))
+	}
+	for i, qa := range that.Path() {
+		bot := "Bot"
+		if p := qa.Provenance(); p != "" {
+			bot += " (" + p + ")"
+		}
+		b.WriteString(fmt.Sprintf("## Q&A %d\n\n**You:**\n\n%s\n\n**%s:**\n\n%s\n\n", i+1, qa.Q, bot, qa.A))
+	}
+	return b.String()
+}
+
+// Export writes the completed turns on the current branch as md or json to the exports dir in the data dir,
+// readable by the owner only. A timestamped name is used if name is empty.
+func (that *Conversation) Export(format, name string) (fPath string, err error) {
+	var content []byte
+	switch format {
+	case "md":
+		content = []byte(that.Markdown())
+	case "json":
+		content, err = json.MarshalIndent(that.Path(), "", "    ")
+	default:
+		err = fmt.Errorf("unsupported format: %s", format)
+	}
+	if err != nil {
+		return
+	}
+	if name == "" {
+		name = fmt.Sprintf("gogpt_%s.%s", time.Now().Format("20060102_150405"), format)
+	}
+	if fPath, err = ResolveCodePath(filepath.Join(that.CNF.GetDataDir(), ExportDirName), name); err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(fPath), os.ModePerm); err != nil {
+		return
+	}
+	if err = config.PrivateFile(fPath); err != nil {
+		return
+	}
+	err = os.WriteFile(fPath, content, 0600)
+	return
+}
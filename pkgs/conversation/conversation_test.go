@@ -0,0 +1,121 @@
+package conversation
+
+import (
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+func newTestConversation(t *testing.T, turns ...string) *Conversation {
+	t.Helper()
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.OpenAI.ContextLen = 10
+	conv := NewConversation(cnf)
+	for _, q := range turns {
+		conv.AddQuestion(q)
+		conv.AddAnswer("answer to "+q, true)
+	}
+	return conv
+}
+
+func TestBranchAndSwitch(t *testing.T) {
+	conv := newTestConversation(t, "a", "b", "c")
+	if !conv.Branch(1, "b2") {
+		t.Fatal("branch refused")
+	}
+	assertPath(t, conv, "a=answer to a")
+	if conv.Branch(0, "x") {
+		t.Fatal("branch while answering")
+	}
+	conv.AddAnswer("answer to b2", true)
+	assertPath(t, conv, "a=answer to a", "b2=answer to b2")
+	if n := conv.Path()[1].BranchCount(); n != 2 {
+		t.Fatalf("branch count %d, want 2", n)
+	}
+
+	if !conv.SwitchBranch(1, 1) {
+		t.Fatal("switch refused")
+	}
+	assertPath(t, conv, "a=answer to a", "b=answer to b", "c=answer to c")
+	if conv.Cursor != 1 {
+		t.Errorf("cursor %d, want 1", conv.Cursor)
+	}
+	if !conv.SwitchBranch(1, -1) {
+		t.Fatal("switch refused")
+	}
+	assertPath(t, conv, "a=answer to a", "b2=answer to b2")
+	if conv.SwitchBranch(0, 1) {
+		t.Error("switch on a turn without branches")
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	conv := newTestConversation(t, "a", "b")
+	if !conv.Regenerate() {
+		t.Fatal("regenerate refused")
+	}
+	if conv.Current == nil || conv.Current.Q != "b" {
+		t.Fatalf("current %+v, want question b", conv.Current)
+	}
+	conv.AddAnswer("another answer to b", true)
+	assertPath(t, conv, "a=answer to a", "b=another answer to b")
+	if !conv.SwitchBranch(1, 1) {
+		t.Fatal("switch refused")
+	}
+	assertPath(t, conv, "a=answer to a", "b=answer to b")
+}
+
+func TestDropCurrentRestoresBranch(t *testing.T) {
+	conv := newTestConversation(t, "a", "b", "c")
+	conv.Branch(1, "b2")
+	conv.AddAnswer("answer to b2", true)
+	conv.SwitchBranch(1, 1) // back to b, c
+
+	// a failed regenerate goes back to the continuation it replaced.
+	if !conv.Regenerate() {
+		t.Fatal("regenerate refused")
+	}
+	conv.DropCurrent()
+	if conv.Current != nil {
+		t.Fatal("current is kept")
+	}
+	assertPath(t, conv, "a=answer to a", "b=answer to b", "c=answer to c")
+	if n := conv.Path()[1].BranchCount(); n != 2 {
+		t.Fatalf("branch count %d, want 2", n)
+	}
+
+	// branching works again after the failure.
+	if !conv.Branch(0, "a2") {
+		t.Fatal("branch refused after a failed answer")
+	}
+	conv.DropCurrent()
+	assertPath(t, conv, "a=answer to a", "b=answer to b", "c=answer to c")
+
+	// a failed new question is simply dropped.
+	conv.AddQuestion("d")
+	conv.DropCurrent()
+	assertPath(t, conv, "a=answer to a", "b=answer to b", "c=answer to c")
+}
+
+func TestSaveAndLoadSessions(t *testing.T) {
+	conv := newTestConversation(t, "a")
+	for _, name := range []string{"../../x", "a/b", `a\b`, ".."} {
+		if err := conv.SaveAs(name); err == nil {
+			t.Errorf("saved as %q", name)
+		}
+	}
+	if err := conv.SaveAs("work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conv.LoadFrom("typo"); err == nil {
+		t.Fatal("loaded a missing session")
+	}
+	if conv.SessionFile() != conv.SessionPath("work") {
+		t.Errorf("session file changed by a failed load: %s", conv.SessionFile())
+	}
+	conv.ClearAll()
+	if err := conv.LoadFrom("work"); err != nil {
+		t.Fatal(err)
+	}
+	assertPath(t, conv, "a=answer to a")
+}
@@ -0,0 +1,122 @@
+package conversation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+/*
+Shell-like history of submitted questions, and the unsent draft.
+*/
+const (
+	InputHistoryFileName string = "gpt_input_history.json" // history of the default session
+	InputHistoryExt      string = ".history"               // history of a named session, next to its file
+	MaxInputHistory      int    = 500
+)
+
+// InputHistoryPath returns the history file of the session saved at sessionPath.
+func InputHistoryPath(sessionPath string) string {
+	if filepath.Base(sessionPath) == ConversationFileName {
+		return filepath.Join(filepath.Dir(sessionPath), InputHistoryFileName)
+	}
+	return strings.TrimSuffix(sessionPath, filepath.Ext(sessionPath)) + InputHistoryExt
+}
+
+type InputHistory struct {
+	List    []string `json:"history"`
+	Draft   string   `json:"draft"`
+	cursor  int
+	pending string // text in the input area before walking through history
+	path    string
+}
+
+// NewInputHistory loads the history of the session saved at sessionPath.
+func NewInputHistory(sessionPath string) (ih *InputHistory) {
+	ih = &InputHistory{
+		List: []string{},
+		path: InputHistoryPath(sessionPath),
+	}
+	if content, err := os.ReadFile(ih.path); err == nil {
+		json.Unmarshal(content, ih)
+	}
+	ih.cursor = len(ih.List)
+	return
+}
+
+// MoveTo keeps the history with the session, when it is saved at sessionPath.
+func (that *InputHistory) MoveTo(sessionPath string) {
+	if p := InputHistoryPath(sessionPath); p != that.path {
+		that.path = p
+		that.Save()
+	}
+}
+
+// Add records a submitted question.
+func (that *InputHistory) Add(ques string) {
+	if strings.TrimSpace(ques) == "" {
+		return
+	}
+	if len(that.List) == 0 || that.List[len(that.List)-1] != ques {
+		that.List = append(that.List, ques)
+	}
+	if len(that.List) > MaxInputHistory {
+		that.List = that.List[len(that.List)-MaxInputHistory:]
+	}
+	that.cursor = len(that.List)
+	that.pending = ""
+	that.Save()
+}
+
+// Prev returns the previous question, current is kept to come back to.
+func (that *InputHistory) Prev(current string) (ques string, ok bool) {
+	if that.cursor <= 0 {
+		return
+	}
+	if that.cursor == len(that.List) {
+		that.pending = current
+	}
+	that.cursor--
+	return that.List[that.cursor], true
+}
+
+// Next returns the next question, or the pending text after the newest one.
+func (that *InputHistory) Next() (ques string, ok bool) {
+	if that.cursor >= len(that.List) {
+		return
+	}
+	that.cursor++
+	if that.cursor == len(that.List) {
+		return that.pending, true
+	}
+	return that.List[that.cursor], true
+}
+
+// PopDraft returns the saved draft and removes it.
+func (that *InputHistory) PopDraft() (draft string) {
+	draft = that.Draft
+	if draft != "" {
+		that.Draft = ""
+		that.Save()
+	}
+	return
+}
+
+func (that *InputHistory) SaveDraft(draft string) {
+	that.Draft = draft
+	that.Save()
+}
+
+func (that *InputHistory) Save() {
+	content, err := json.MarshalIndent(that, "", "    ")
+	if err != nil {
+		return
+	}
+	// raw prompts are kept private like the config.
+	if config.PrivateFile(that.path) == nil {
+		os.WriteFile(that.path, content, 0600)
+	}
+}
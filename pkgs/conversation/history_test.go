@@ -0,0 +1,38 @@
+package conversation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInputHistoryPerSession(t *testing.T) {
+	dir := t.TempDir()
+	def := filepath.Join(dir, ConversationFileName)
+	named := filepath.Join(dir, ConversationDirName, "work.json")
+	if p := InputHistoryPath(def); p != filepath.Join(dir, InputHistoryFileName) {
+		t.Errorf("default session history: %s", p)
+	}
+	if p := InputHistoryPath(named); p != filepath.Join(dir, ConversationDirName, "work"+InputHistoryExt) {
+		t.Errorf("named session history: %s", p)
+	}
+
+	ih := NewInputHistory(def)
+	ih.Add("hello")
+	info, err := os.Stat(InputHistoryPath(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("history file mode: %v", info.Mode().Perm())
+	}
+
+	os.MkdirAll(filepath.Dir(named), os.ModePerm)
+	ih.MoveTo(named)
+	if got := NewInputHistory(named).List; len(got) != 1 || got[0] != "hello" {
+		t.Errorf("moved history: %v", got)
+	}
+	if got := NewInputHistory(filepath.Join(dir, "other.json")).List; len(got) != 0 {
+		t.Errorf("other session history: %v", got)
+	}
+}
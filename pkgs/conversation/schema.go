@@ -0,0 +1,54 @@
+package conversation
+
+import (
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+/*
+Schema versions of saved sessions, see config.Migrate.
+*/
+const (
+	ConversationSchemaVersion int = 2
+)
+
+// ConversationMigrations upgrade gpt_conversation.json and named sessions, the item i upgrades version i+1.
+var ConversationMigrations = []config.Migration{
+	migrateConversationV1,
+}
+
+var (
+	saverV1Names = map[string]string{
+		"QAList":  "qa_list",
+		"Prompt":  "prompt",
+		"BotType": "bot_type",
+		"Profile": "profile",
+	}
+	quesAnswV1Names = map[string]string{
+		"Q":        "question",
+		"A":        "answer",
+		"Branches": "branches",
+		"Branch":   "branch",
+	}
+)
+
+// migrateConversationV1 renames Go field names to the tag names, in branches too.
+func migrateConversationV1(data map[string]interface{}) error {
+	config.RenameKeys(data, saverV1Names)
+	renameQAList(data["qa_list"])
+	return nil
+}
+
+func renameQAList(list interface{}) {
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		qa, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		config.RenameKeys(qa, quesAnswV1Names)
+		branches, _ := qa["branches"].([]interface{})
+		for _, branch := range branches {
+			renameQAList(branch)
+		}
+	}
+}
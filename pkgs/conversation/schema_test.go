@@ -0,0 +1,115 @@
+package conversation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+func copyTestFile(t *testing.T, name, dest string) (fPath string, content []byte) {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fPath = filepath.Join(t.TempDir(), dest)
+	if err = os.WriteFile(fPath, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func assertJSONFile(t *testing.T, fPath, golden string) {
+	t.Helper()
+	decode := func(p string) (v interface{}) {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(content, &v); err != nil {
+			t.Fatalf("parse %s: %v", p, err)
+		}
+		return
+	}
+	if got, want := decode(fPath), decode(filepath.Join("testdata", golden)); !reflect.DeepEqual(got, want) {
+		content, _ := json.MarshalIndent(got, "", "    ")
+		t.Errorf("%s differs from %s:\n%s", fPath, golden, content)
+	}
+}
+
+func TestMigrateConversationV1(t *testing.T) {
+	fPath, original := copyTestFile(t, "gpt_conversation.v1.json", ConversationFileName)
+	if err := config.MigrateFile(fPath, ConversationMigrations); err != nil {
+		t.Fatal(err)
+	}
+	assertJSONFile(t, fPath, "gpt_conversation.v2.golden.json")
+	bak, err := os.ReadFile(fPath + ".v1.bak")
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if string(bak) != string(original) {
+		t.Error("backup differs from the original file")
+	}
+}
+
+func TestMigrateConversationCurrent(t *testing.T) {
+	fPath, original := copyTestFile(t, "gpt_conversation.v2.golden.json", ConversationFileName)
+	if err := config.MigrateFile(fPath, ConversationMigrations); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(fPath); string(content) != string(original) {
+		t.Error("a file at the current version is changed")
+	}
+	if baks, _ := filepath.Glob(fPath + ".*.bak"); len(baks) > 0 {
+		t.Errorf("backup of a file at the current version: %v", baks)
+	}
+}
+
+func TestLoadConversationV1(t *testing.T) {
+	fPath, _ := copyTestFile(t, "gpt_conversation.v1.json", ConversationFileName)
+	cnf := config.NewConf(config.NewDirs(filepath.Dir(fPath)))
+	cnf.OpenAI.ContextLen = 10
+	conv := NewConversation(cnf)
+	if err := conv.Load(); err != nil {
+		t.Fatal(err)
+	}
+	assertPath(t, conv, "What is Go?=A programming language.", "Show a quicksort.=func quicksort() {}")
+	if conv.BotType != BotGPT || conv.Saver.Profile != "work" {
+		t.Errorf("bot %s, profile %s", conv.BotType, conv.Saver.Profile)
+	}
+	if !conv.SwitchBranch(1, -1) {
+		t.Fatal("branches are lost")
+	}
+	assertPath(t, conv,
+		"What is Go?=A programming language.",
+		"Show a quicksort in Python.=def quicksort(): pass",
+		"Make it in place.=def quicksort(a, lo, hi): pass",
+	)
+	if n := conv.Path()[2].BranchCount(); n != 2 {
+		t.Errorf("branch count of a nested turn %d, want 2", n)
+	}
+}
+
+func questions(conv *Conversation) (qs []string) {
+	for _, qa := range conv.Path() {
+		qs = append(qs, qa.Q+"="+qa.A)
+	}
+	return
+}
+
+func assertPath(t *testing.T, conv *Conversation, want ...string) {
+	t.Helper()
+	got := questions(conv)
+	if len(got) != len(want) {
+		t.Fatalf("path %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("path %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package dashscope
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Connection test.
+*/
+
+// Ping sends a one-token question without streaming.
+func (that *Qwen) Ping(ctx context.Context) error {
+	headers, err := that.headers()
+	if err != nil {
+		return err
+	}
+	delete(headers, "X-DashScope-SSE")
+	delete(headers, "Accept")
+	req := that.NewRequest([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+	})
+	req.Parameters.MaxTokens, req.Parameters.IncrementalOutput = 1, false
+	body, _ := json.Marshal(req)
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, that.Endpoint(), strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	resp, err := that.HttpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,102 @@
+package dashscope
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+)
+
+/*
+Error codes of DashScope.
+
+InvalidParameter                  参数不合法
+DataInspectionFailed              输入或者输出包含疑似敏感内容
+BadRequest.EmptyInput             输入为空
+BadRequest.EmptyParameters        参数为空
+BadRequest.EmptyModel             模型为空
+InvalidURL                        url错误
+Arrearage                         账号欠费
+UnsupportedOperation              模型不支持该操作
+InvalidApiKey                     api key错误
+AccessDenied                      无权访问此api
+AccessDenied.Unpurchased          未开通服务
+Model.AccessDenied                无权访问此模型
+ModelNotFound                     模型不存在
+Throttling                        调用频率超限
+Throttling.RateQuota              调用频率超限
+Throttling.AllocationQuota        token用量超限
+InternalError                     内部错误
+InternalError.Algo                算法错误
+InternalError.Timeout             内部超时
+*/
+
+type QwenAPIError struct {
+	Code string
+	Info string
+}
+
+func (that QwenAPIError) Error() string {
+	return fmt.Sprintf("code: %s, info: %s", that.Code, that.Info)
+}
+
+var (
+	ErrInvalidParameter     = QwenAPIError{"InvalidParameter", "invalid parameter"}
+	ErrDataInspectionFailed = QwenAPIError{"DataInspectionFailed", "sensitive content in the question or answer"}
+	ErrEmptyInput           = QwenAPIError{"BadRequest.EmptyInput", "empty input"}
+	ErrEmptyParameters      = QwenAPIError{"BadRequest.EmptyParameters", "empty parameters"}
+	ErrEmptyModel           = QwenAPIError{"BadRequest.EmptyModel", "empty model"}
+	ErrInvalidURL           = QwenAPIError{"InvalidURL", "invalid url"}
+	ErrArrearage            = QwenAPIError{"Arrearage", "account in arrears"}
+	ErrUnsupportedOperation = QwenAPIError{"UnsupportedOperation", "operation not supported by the model"}
+	ErrInvalidApiKey        = QwenAPIError{"InvalidApiKey", "invalid api key"}
+	ErrAccessDenied         = QwenAPIError{"AccessDenied", "access denied"}
+	ErrUnpurchased          = QwenAPIError{"AccessDenied.Unpurchased", "service not activated"}
+	ErrModelAccessDenied    = QwenAPIError{"Model.AccessDenied", "no access to the model"}
+	ErrModelNotFound        = QwenAPIError{"ModelNotFound", "model not found"}
+	ErrThrottling           = QwenAPIError{"Throttling", "reach rate limit"}
+	ErrRateQuota            = QwenAPIError{"Throttling.RateQuota", "reach rate limit"}
+	ErrAllocationQuota      = QwenAPIError{"Throttling.AllocationQuota", "reach token quota"}
+	ErrInternalError        = QwenAPIError{"InternalError", "internal error"}
+	ErrInternalAlgo         = QwenAPIError{"InternalError.Algo", "algorithm error"}
+	ErrInternalTimeout      = QwenAPIError{"InternalError.Timeout", "internal timeout"}
+)
+
+var QwenErrorMap map[string]error = map[string]error{
+	"InvalidParameter":           ErrInvalidParameter,
+	"DataInspectionFailed":       ErrDataInspectionFailed,
+	"BadRequest.EmptyInput":      ErrEmptyInput,
+	"BadRequest.EmptyParameters": ErrEmptyParameters,
+	"BadRequest.EmptyModel":      ErrEmptyModel,
+	"InvalidURL":                 ErrInvalidURL,
+	"Arrearage":                  ErrArrearage,
+	"UnsupportedOperation":       ErrUnsupportedOperation,
+	"InvalidApiKey":              ErrInvalidApiKey,
+	"AccessDenied":               ErrAccessDenied,
+	"AccessDenied.Unpurchased":   ErrUnpurchased,
+	"Model.AccessDenied":         ErrModelAccessDenied,
+	"ModelNotFound":              ErrModelNotFound,
+	"Throttling":                 ErrThrottling,
+	"Throttling.RateQuota":       ErrRateQuota,
+	"Throttling.AllocationQuota": ErrAllocationQuota,
+	"InternalError":              ErrInternalError,
+	"InternalError.Algo":         ErrInternalAlgo,
+	"InternalError.Timeout":      ErrInternalTimeout,
+}
+
+// NewQwenError returns the error of code, with the message from the server for unknown codes.
+func NewQwenError(code, info string) error {
+	if err, ok := QwenErrorMap[code]; ok {
+		return err
+	}
+	return QwenAPIError{Code: code, Info: info}
+}
+
+// Retryable tells if err is a rate limit or a server error worth another try.
+func Retryable(err error) (bool, time.Duration) {
+	switch err {
+	case ErrThrottling, ErrRateQuota, ErrInternalError, ErrInternalTimeout:
+		return true, 0
+	}
+	return backend.Retryable(err)
+}
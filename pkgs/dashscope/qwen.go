@@ -0,0 +1,236 @@
+package dashscope
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Alibaba DashScope text generation API of Qwen.
+
+	POST {base_url}/api/v1/services/aigc/text-generation/generation
+	Authorization: Bearer {api_key}
+	X-DashScope-SSE: enable
+
+	{"model": "qwen-turbo",
+	 "input": {"messages": [{"role": "system", "content": "..."}, {"role": "user", "content": "..."}]},
+	 "parameters": {"result_format": "message", "incremental_output": true}}
+
+The stream is server-sent events, usage counts the whole answer so far:
+
+	event:result
+	data:{"output": {"choices": [{"message": {"content": "..."}, "finish_reason": "null"}]}, "usage": {"total_tokens": 20}}
+
+	event:error
+	data:{"code": "InvalidApiKey", "message": "..."}
+*/
+const (
+	DefaultBaseUrl string = "https://dashscope.aliyuncs.com"
+	DefaultModel   string = "qwen-turbo"
+)
+
+// Models for selection.
+var ModelList = []string{
+	"qwen-turbo",
+	"qwen-plus",
+	"qwen-max",
+	"qwen-max-longcontext",
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type Parameters struct {
+	ResultFormat      string  `json:"result_format"`
+	IncrementalOutput bool    `json:"incremental_output"`
+	MaxTokens         int     `json:"max_tokens,omitempty"`
+	Temperature       float64 `json:"temperature,omitempty"`
+}
+
+type Request struct {
+	Model string `json:"model"`
+	Input struct {
+		Messages []Message `json:"messages"`
+	} `json:"input"`
+	Parameters Parameters `json:"parameters"`
+}
+
+type Response struct {
+	Output struct {
+		Choices []struct {
+			Message      Message `json:"message"`
+			FinishReason string  `json:"finish_reason"`
+		} `json:"choices"`
+	} `json:"output"`
+	Usage struct {
+		InputTokens  int64 `json:"input_tokens"`
+		OutputTokens int64 `json:"output_tokens"`
+		TotalTokens  int64 `json:"total_tokens"`
+	} `json:"usage"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+type Qwen struct {
+	CNF        *config.Config
+	HttpClient *http.Client
+	resp       *http.Response
+	events     *backend.SSEReader
+	usage      int64 // total tokens of the answer being received
+	tokens     int64 // usage not reported by GetTokens yet
+}
+
+func NewQwen(cnf *config.Config) (q *Qwen) {
+	q = &Qwen{
+		CNF:        cnf,
+		HttpClient: &http.Client{},
+	}
+	return
+}
+
+// Endpoint returns the url of the text generation api.
+func (that *Qwen) Endpoint() string {
+	base := that.CNF.Qwen.BaseUrl
+	if base == "" {
+		base = DefaultBaseUrl
+	}
+	return strings.TrimSuffix(base, "/") + "/api/v1/services/aigc/text-generation/generation"
+}
+
+func (that *Qwen) headers() (map[string]string, error) {
+	apiKey, err := that.CNF.Secret(that.CNF.Qwen.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"Authorization":   "Bearer " + apiKey,
+		"X-DashScope-SSE": "enable",
+		"Accept":          "text/event-stream",
+	}, nil
+}
+
+// NewRequest converts chat messages, an empty system prompt is dropped.
+func (that *Qwen) NewRequest(msgs []openai.ChatCompletionMessage) *Request {
+	req := &Request{
+		Model: that.CNF.Qwen.Model,
+		Parameters: Parameters{
+			ResultFormat:      "message",
+			IncrementalOutput: true,
+			MaxTokens:         that.CNF.Qwen.MaxTokens,
+			Temperature:       that.CNF.Qwen.Temperature,
+		},
+	}
+	if req.Model == "" {
+		req.Model = DefaultModel
+	}
+	for _, m := range msgs {
+		if m.Role == openai.ChatMessageRoleSystem && m.Content == "" {
+			continue
+		}
+		req.Input.Messages = append(req.Input.Messages, Message{Role: m.Role, Content: m.Content})
+	}
+	return req
+}
+
+// readError decodes {"code": "...", "message": "..."}.
+func readError(resp *http.Response) error {
+	var apiErr error
+	err := backend.ReadError(resp, func(body []byte) string {
+		r := &Response{}
+		if json.Unmarshal(body, r) == nil && r.Code != "" {
+			apiErr = NewQwenError(r.Code, r.Message)
+		}
+		return ""
+	})
+	if apiErr != nil {
+		return apiErr
+	}
+	return err
+}
+
+func (that *Qwen) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error) {
+	that.Close()
+	headers, err := that.headers()
+	if err != nil {
+		return "", err
+	}
+	resp, err := backend.PostJSON(that.HttpClient, that.Endpoint(), headers, that.NewRequest(msgs))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", readError(resp)
+	}
+	that.resp = resp
+	that.events = backend.NewSSEReader(resp.Body)
+	that.usage = 0
+	return "", nil
+}
+
+// RecvMsg returns the text of the next event, io.EOF with the last one.
+func (that *Qwen) RecvMsg() (m string, err error) {
+	if that.events == nil {
+		return "", fmt.Errorf("no stream found")
+	}
+	for {
+		ev, err := that.events.Next()
+		if err != nil {
+			that.Close()
+			if err == io.EOF {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		r := &Response{}
+		if err = json.Unmarshal([]byte(ev.Data), r); err != nil {
+			that.Close()
+			return "", fmt.Errorf("decode %s event: %w", ev.Event, err)
+		}
+		if ev.Event == "error" || r.Code != "" {
+			that.Close()
+			return "", NewQwenError(r.Code, r.Message)
+		}
+		if r.Usage.TotalTokens > 0 {
+			that.usage = r.Usage.TotalTokens
+		}
+		if len(r.Output.Choices) == 0 {
+			continue
+		}
+		c := r.Output.Choices[0]
+		switch c.FinishReason {
+		case "", "null":
+			if c.Message.Content != "" {
+				return c.Message.Content, nil
+			}
+		default:
+			// stop or length
+			that.tokens += that.usage
+			that.Close()
+			return c.Message.Content, io.EOF
+		}
+	}
+}
+
+func (that *Qwen) Close() {
+	if that.resp != nil {
+		that.resp.Body.Close()
+	}
+	that.resp = nil
+	that.events = nil
+}
+
+// GetTokens returns the tokens reported by the api since the last call.
+func (that *Qwen) GetTokens() (tokens int64) {
+	tokens, that.tokens = that.tokens, 0
+	return
+}
@@ -0,0 +1,134 @@
+package dashscope
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newTestQwen returns a client of a local stand-in server, sending events for every request.
+func newTestQwen(t *testing.T, events string, got *Request) *Qwen {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"code": "InvalidApiKey", "message": "Invalid API-key provided.", "request_id": "1"}`)
+			return
+		}
+		if r.URL.Path != "/api/v1/services/aigc/text-generation/generation" || r.Header.Get("X-DashScope-SSE") != "enable" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"code": "InvalidURL", "message": "url error"}`)
+			return
+		}
+		if got != nil {
+			json.NewDecoder(r.Body).Decode(got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, events)
+	}))
+	t.Cleanup(srv.Close)
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.Qwen.BaseUrl = srv.URL + "/"
+	cnf.Qwen.ApiKey = "sk-test"
+	return NewQwen(cnf)
+}
+
+func sse(event, data string) string {
+	return fmt.Sprintf("event:%s\ndata:%s\n\n", event, data)
+}
+
+func recvAll(q *Qwen) (answer string, err error) {
+	for {
+		m, err := q.RecvMsg()
+		answer += m
+		if err != nil {
+			return answer, err
+		}
+	}
+}
+
+func TestQwenStream(t *testing.T) {
+	events := sse("result", `{"output": {"choices": [{"message": {"role": "assistant", "content": "Hello"}, "finish_reason": "null"}]}, "usage": {"total_tokens": 10}}`) +
+		sse("result", `{"output": {"choices": [{"message": {"role": "assistant", "content": ""}, "finish_reason": "null"}]}, "usage": {"total_tokens": 10}}`) +
+		sse("result", `{"output": {"choices": [{"message": {"role": "assistant", "content": ", world"}, "finish_reason": "stop"}]}, "usage": {"total_tokens": 12}}`)
+	req := &Request{}
+	q := newTestQwen(t, events, req)
+	msgs := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: ""},
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "hello"},
+		{Role: openai.ChatMessageRoleUser, Content: "say hello"},
+	}
+	if _, err := q.SendMsg(msgs); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := recvAll(q)
+	if err != io.EOF {
+		t.Fatalf("stream ended with %v", err)
+	}
+	if answer != "Hello, world" {
+		t.Errorf("answer %q", answer)
+	}
+	if tokens := q.GetTokens(); tokens != 12 {
+		t.Errorf("tokens %d, want the total of the last event 12", tokens)
+	}
+
+	if req.Model != DefaultModel || !req.Parameters.IncrementalOutput || req.Parameters.ResultFormat != "message" {
+		t.Errorf("request %+v", req)
+	}
+	want := []Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}, {Role: "user", Content: "say hello"}}
+	if len(req.Input.Messages) != len(want) {
+		t.Fatalf("messages %+v, the empty system prompt is dropped", req.Input.Messages)
+	}
+	for i := range want {
+		if req.Input.Messages[i] != want[i] {
+			t.Errorf("message %d: %+v, want %+v", i, req.Input.Messages[i], want[i])
+		}
+	}
+}
+
+func TestQwenStreamError(t *testing.T) {
+	events := sse("result", `{"output": {"choices": [{"message": {"content": "Hel"}, "finish_reason": "null"}]}}`) +
+		sse("error", `{"code": "Throttling.RateQuota", "message": "Requests rate limit exceeded"}`)
+	q := newTestQwen(t, events, nil)
+	if _, err := q.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := recvAll(q)
+	if answer != "Hel" || err != ErrRateQuota {
+		t.Fatalf("answer %q, err %v", answer, err)
+	}
+	if retry, _ := Retryable(err); !retry {
+		t.Error("Throttling.RateQuota is not retryable")
+	}
+}
+
+func TestQwenStreamCut(t *testing.T) {
+	events := sse("result", `{"output": {"choices": [{"message": {"content": "Hel"}, "finish_reason": "null"}]}}`)
+	q := newTestQwen(t, events, nil)
+	if _, err := q.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recvAll(q); err != io.ErrUnexpectedEOF {
+		t.Errorf("stream without finish_reason ended with %v", err)
+	}
+}
+
+func TestQwenHTTPError(t *testing.T) {
+	q := newTestQwen(t, "", nil)
+	q.CNF.Qwen.ApiKey = "sk-wrong"
+	if _, err := q.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != ErrInvalidApiKey {
+		t.Errorf("error %v", err)
+	}
+	q.CNF.Qwen.ApiKey = "sk-test"
+	q.CNF.Qwen.BaseUrl += "other/"
+	if _, err := q.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != ErrInvalidURL {
+		t.Errorf("error %v", err)
+	}
+}
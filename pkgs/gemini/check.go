@@ -0,0 +1,34 @@
+package gemini
+
+import (
+	"context"
+	"net/http"
+)
+
+/*
+Connection test.
+*/
+
+// Ping gets the model, which checks the api key and the model name without generating.
+func (that *Gemini) Ping(ctx context.Context) error {
+	headers, err := that.headers()
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, that.modelUrl(), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	resp, err := that.HttpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,292 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Google Gemini streamGenerateContent API.
+
+	POST {base_url}/{api_version}/models/{model}:streamGenerateContent?alt=sse
+	x-goog-api-key: ...
+
+	{"systemInstruction": {"parts": [{"text": "..."}]},
+	 "contents": [{"role": "user", "parts": [{"text": "..."}]}, {"role": "model", "parts": [{"text": "..."}]}],
+	 "generationConfig": {"temperature": 1, "maxOutputTokens": 1024}}
+
+Each server-sent event is a GenerateContentResponse, the last one has a finishReason.
+usageMetadata counts the whole answer so far.
+*/
+const (
+	DefaultBaseUrl    string = "https://generativelanguage.googleapis.com"
+	DefaultApiVersion string = "v1beta"
+	DefaultModel      string = "gemini-1.5-flash-latest"
+	RoleUser          string = "user"
+	RoleModel         string = "model"
+)
+
+// Models for selection.
+var ModelList = []string{
+	"gemini-1.5-flash-latest",
+	"gemini-1.5-pro-latest",
+	"gemini-2.0-flash",
+	"gemini-1.0-pro",
+}
+
+type Part struct {
+	Text string `json:"text"`
+}
+
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+type GenerationConfig struct {
+	Temperature     float64 `json:"temperature,omitempty"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+type Request struct {
+	SystemInstruction *Content         `json:"systemInstruction,omitempty"`
+	Contents          []Content        `json:"contents"`
+	GenerationConfig  GenerationConfig `json:"generationConfig"`
+}
+
+type Candidate struct {
+	Content       Content        `json:"content"`
+	FinishReason  string         `json:"finishReason"`
+	SafetyRatings []SafetyRating `json:"safetyRatings"`
+}
+
+type Response struct {
+	Candidates     []Candidate `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason   string         `json:"blockReason"`
+		SafetyRatings []SafetyRating `json:"safetyRatings"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int64 `json:"promptTokenCount"`
+		CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+		TotalTokenCount      int64 `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	Error *apiError `json:"error"`
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func (that *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", that.Status, that.Message)
+}
+
+type Gemini struct {
+	CNF        *config.Config
+	HttpClient *http.Client
+	resp       *http.Response
+	events     *backend.SSEReader
+	usage      int64 // total tokens of the answer being received
+	tokens     int64 // usage not reported by GetTokens yet
+	done       error // io.EOF or the finish error, returned after the last text
+}
+
+func NewGemini(cnf *config.Config) (g *Gemini) {
+	g = &Gemini{
+		CNF:        cnf,
+		HttpClient: backend.NewHttpClient(cnf.Gemini.Proxy),
+	}
+	return
+}
+
+// modelUrl returns the url of the model, like {base_url}/v1beta/models/gemini-pro.
+func (that *Gemini) modelUrl() string {
+	base := that.CNF.Gemini.BaseUrl
+	if base == "" {
+		base = DefaultBaseUrl
+	}
+	version := that.CNF.Gemini.ApiVersion
+	if version == "" {
+		version = DefaultApiVersion
+	}
+	model := strings.TrimPrefix(that.CNF.Gemini.Model, "models/")
+	if model == "" {
+		model = DefaultModel
+	}
+	return fmt.Sprintf("%s/%s/models/%s", strings.TrimSuffix(base, "/"), version, model)
+}
+
+// Endpoint returns the url of the streaming api.
+func (that *Gemini) Endpoint() string {
+	return that.modelUrl() + ":streamGenerateContent?alt=sse"
+}
+
+func (that *Gemini) ProxyUrl() string {
+	return that.CNF.Gemini.Proxy
+}
+
+func (that *Gemini) headers() (map[string]string, error) {
+	apiKey, err := that.CNF.Secret(that.CNF.Gemini.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"x-goog-api-key": apiKey}, nil
+}
+
+// NewRequest converts chat messages, the system prompt goes to systemInstruction,
+// assistant becomes model and consecutive messages of the same role are merged.
+func (that *Gemini) NewRequest(msgs []openai.ChatCompletionMessage) *Request {
+	req := &Request{
+		GenerationConfig: GenerationConfig{
+			Temperature:     that.CNF.Gemini.Temperature,
+			MaxOutputTokens: that.CNF.Gemini.MaxTokens,
+		},
+	}
+	system := []Part{}
+	for _, m := range msgs {
+		role := RoleUser
+		switch m.Role {
+		case openai.ChatMessageRoleSystem:
+			if m.Content != "" {
+				system = append(system, Part{Text: m.Content})
+			}
+			continue
+		case openai.ChatMessageRoleAssistant:
+			role = RoleModel
+		case openai.ChatMessageRoleUser:
+		default:
+			continue
+		}
+		if l := len(req.Contents); l > 0 && req.Contents[l-1].Role == role {
+			req.Contents[l-1].Parts = append(req.Contents[l-1].Parts, Part{Text: m.Content})
+		} else if l == 0 && role == RoleModel {
+			continue // the first message must be from the user.
+		} else {
+			req.Contents = append(req.Contents, Content{Role: role, Parts: []Part{{Text: m.Content}}})
+		}
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &Content{Parts: system}
+	}
+	return req
+}
+
+// readError decodes {"error": {"code": 400, "message": "...", "status": "..."}}, also wrapped in an array.
+func readError(resp *http.Response) error {
+	return backend.ReadError(resp, func(body []byte) string {
+		r := &Response{}
+		if json.Unmarshal(body, r) == nil && r.Error != nil {
+			return r.Error.Error()
+		}
+		rl := []Response{}
+		if json.Unmarshal(body, &rl) == nil && len(rl) > 0 && rl[0].Error != nil {
+			return rl[0].Error.Error()
+		}
+		return ""
+	})
+}
+
+func (that *Gemini) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error) {
+	that.Close()
+	headers, err := that.headers()
+	if err != nil {
+		return "", err
+	}
+	resp, err := backend.PostJSON(that.HttpClient, that.Endpoint(), headers, that.NewRequest(msgs))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", readError(resp)
+	}
+	that.resp = resp
+	that.events = backend.NewSSEReader(resp.Body)
+	that.usage = 0
+	return "", nil
+}
+
+// RecvMsg returns the text of the next chunk, io.EOF after the last one.
+// Answers stopped by safety filters return a FinishError.
+func (that *Gemini) RecvMsg() (m string, err error) {
+	if that.done != nil {
+		return "", that.finish()
+	}
+	if that.events == nil {
+		return "", fmt.Errorf("no stream found")
+	}
+	for {
+		ev, err := that.events.Next()
+		if err != nil {
+			that.Close()
+			if err == io.EOF {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		r := &Response{}
+		if err = json.Unmarshal([]byte(ev.Data), r); err != nil {
+			that.Close()
+			return "", fmt.Errorf("decode chunk: %w", err)
+		}
+		if r.Error != nil {
+			that.Close()
+			return "", r.Error
+		}
+		if r.UsageMetadata.TotalTokenCount > 0 {
+			that.usage = r.UsageMetadata.TotalTokenCount
+		}
+		if reason := r.PromptFeedback.BlockReason; reason != "" {
+			that.done = NewBlockError(reason, r.PromptFeedback.SafetyRatings)
+			return "", that.finish()
+		}
+		if len(r.Candidates) == 0 {
+			continue
+		}
+		c := r.Candidates[0]
+		for _, p := range c.Content.Parts {
+			m += p.Text
+		}
+		if c.FinishReason != "" {
+			that.done = NewFinishError(c.FinishReason, c.SafetyRatings)
+		}
+		if m != "" {
+			return m, nil
+		}
+		if that.done != nil {
+			return "", that.finish()
+		}
+	}
+}
+
+// finish counts the usage of the answer and returns how it ended.
+func (that *Gemini) finish() (err error) {
+	err, that.done = that.done, nil
+	that.tokens += that.usage
+	that.usage = 0
+	that.Close()
+	return
+}
+
+func (that *Gemini) Close() {
+	if that.resp != nil {
+		that.resp.Body.Close()
+	}
+	that.resp = nil
+	that.events = nil
+}
+
+// GetTokens returns the tokens reported by the api since the last call.
+func (that *Gemini) GetTokens() (tokens int64) {
+	tokens, that.tokens = that.tokens, 0
+	return
+}
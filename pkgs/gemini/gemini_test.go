@@ -0,0 +1,183 @@
+package gemini
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newTestGemini returns a client of a local stand-in server, sending events for every request.
+func newTestGemini(t *testing.T, events string, got *Request) *Gemini {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `[{"error": {"code": 400, "message": "API key not valid.", "status": "INVALID_ARGUMENT"}}]`)
+			return
+		}
+		if r.URL.Path != "/v1beta/models/gemini-test:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": 404, "message": "model not found", "status": "NOT_FOUND"}}`)
+			return
+		}
+		if got != nil {
+			json.NewDecoder(r.Body).Decode(got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, events)
+	}))
+	t.Cleanup(srv.Close)
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.Gemini.BaseUrl = srv.URL + "/"
+	cnf.Gemini.ApiKey = "test-key"
+	cnf.Gemini.Model = "models/gemini-test"
+	cnf.Gemini.Temperature = 0.5
+	return NewGemini(cnf)
+}
+
+func sse(data string) string {
+	return fmt.Sprintf("data: %s\n\n", data)
+}
+
+func recvAll(g *Gemini) (answer string, err error) {
+	for {
+		m, err := g.RecvMsg()
+		answer += m
+		if err != nil {
+			return answer, err
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func send(t *testing.T, g *Gemini) {
+	t.Helper()
+	if _, err := g.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeminiStream(t *testing.T) {
+	events := sse(`{"candidates": [{"content": {"role": "model", "parts": [{"text": "Hello"}]}}], "usageMetadata": {"promptTokenCount": 9, "totalTokenCount": 10}}`) +
+		sse(`{"candidates": [{"content": {"role": "model", "parts": [{"text": ", "}, {"text": "world"}]}}], "usageMetadata": {"promptTokenCount": 9, "totalTokenCount": 12}}`) +
+		sse(`{"candidates": [{"content": {"role": "model", "parts": [{"text": ""}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 9, "totalTokenCount": 13}}`)
+	req := &Request{}
+	g := newTestGemini(t, events, req)
+	msgs := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: openai.ChatMessageRoleAssistant, Content: "dropped, the first message is from the user"},
+		{Role: openai.ChatMessageRoleUser, Content: "attached file"},
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "hello"},
+		{Role: openai.ChatMessageRoleUser, Content: "say hello"},
+	}
+	if _, err := g.SendMsg(msgs); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := recvAll(g)
+	if err != io.EOF {
+		t.Fatalf("stream ended with %v", err)
+	}
+	if answer != "Hello, world" {
+		t.Errorf("answer %q", answer)
+	}
+	if tokens := g.GetTokens(); tokens != 13 {
+		t.Errorf("tokens %d, want the total of the last chunk 13", tokens)
+	}
+	if tokens := g.GetTokens(); tokens != 0 {
+		t.Errorf("tokens reported twice: %d", tokens)
+	}
+
+	if req.SystemInstruction == nil || len(req.SystemInstruction.Parts) != 1 || req.SystemInstruction.Parts[0].Text != "Be brief." {
+		t.Errorf("system instruction %+v", req.SystemInstruction)
+	}
+	if req.GenerationConfig.Temperature != 0.5 {
+		t.Errorf("generation config %+v", req.GenerationConfig)
+	}
+	want := []Content{
+		{Role: RoleUser, Parts: []Part{{Text: "attached file"}, {Text: "hi"}}},
+		{Role: RoleModel, Parts: []Part{{Text: "hello"}}},
+		{Role: RoleUser, Parts: []Part{{Text: "say hello"}}},
+	}
+	if got, _ := json.Marshal(req.Contents); string(got) != mustMarshal(t, want) {
+		t.Errorf("contents %s, want %s", got, mustMarshal(t, want))
+	}
+}
+
+func TestGeminiSafetyStop(t *testing.T) {
+	events := sse(`{"candidates": [{"content": {"parts": [{"text": "Once upon"}]}}]}`) +
+		sse(`{"candidates": [{"content": {"parts": [{"text": " a time"}]}, "finishReason": "SAFETY", "safetyRatings": [`+
+			`{"category": "HARM_CATEGORY_HARASSMENT", "probability": "NEGLIGIBLE"},`+
+			`{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true}]}], "usageMetadata": {"totalTokenCount": 7}}`)
+	g := newTestGemini(t, events, nil)
+	send(t, g)
+	answer, err := recvAll(g)
+	if answer != "Once upon a time" {
+		t.Errorf("text before the stop %q", answer)
+	}
+	fe := &FinishError{}
+	if !errors.As(err, &fe) || fe.Prompt || fe.Reason != "SAFETY" {
+		t.Fatalf("error %#v", err)
+	}
+	if err.Error() != "answer blocked by safety filters (SAFETY): dangerous content high" {
+		t.Errorf("error %q", err)
+	}
+	if tokens := g.GetTokens(); tokens != 7 {
+		t.Errorf("tokens %d, want 7", tokens)
+	}
+}
+
+func TestGeminiBlockedQuestion(t *testing.T) {
+	events := sse(`{"promptFeedback": {"blockReason": "SAFETY", "safetyRatings": [{"category": "HARM_CATEGORY_HATE_SPEECH", "probability": "MEDIUM"}]}, "usageMetadata": {"promptTokenCount": 4, "totalTokenCount": 4}}`)
+	g := newTestGemini(t, events, nil)
+	send(t, g)
+	answer, err := recvAll(g)
+	if answer != "" || err == nil || err.Error() != "question blocked by safety filters (SAFETY): hate speech medium" {
+		t.Fatalf("answer %q, err %v", answer, err)
+	}
+	if fe := (&FinishError{}); !errors.As(err, &fe) || !fe.Prompt {
+		t.Errorf("error %#v is not a blocked question", err)
+	}
+}
+
+func TestGeminiStreamErrors(t *testing.T) {
+	for name, c := range map[string]struct {
+		events string
+		want   string
+	}{
+		"cut":     {sse(`{"candidates": [{"content": {"parts": [{"text": "Hel"}]}}]}`), io.ErrUnexpectedEOF.Error()},
+		"error":   {sse(`{"error": {"code": 503, "message": "The model is overloaded.", "status": "UNAVAILABLE"}}`), "UNAVAILABLE: The model is overloaded."},
+		"invalid": {sse(`{"candidates": [`), "decode chunk"},
+	} {
+		g := newTestGemini(t, c.events, nil)
+		send(t, g)
+		if _, err := recvAll(g); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: stream ended with %v, want %s", name, err, c.want)
+		}
+	}
+}
+
+func TestGeminiHTTPError(t *testing.T) {
+	g := newTestGemini(t, "", nil)
+	g.CNF.Gemini.ApiKey = "wrong-key"
+	_, err := g.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}})
+	if err == nil || !strings.Contains(err.Error(), "INVALID_ARGUMENT: API key not valid.") {
+		t.Errorf("error %v", err)
+	}
+}
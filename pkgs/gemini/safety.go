@@ -0,0 +1,84 @@
+package gemini
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+Finish reasons of candidates and block reasons of prompts.
+
+STOP                  natural stop point
+MAX_TOKENS            maxOutputTokens reached
+SAFETY                flagged by safety filters, see safetyRatings
+RECITATION            flagged for reciting training data
+LANGUAGE              unsupported language
+BLOCKLIST             contains forbidden terms
+PROHIBITED_CONTENT    potentially prohibited content
+SPII                  potentially sensitive personally identifiable information
+OTHER                 unknown reason
+*/
+var FinishReasonMap = map[string]string{
+	"SAFETY":             "blocked by safety filters",
+	"RECITATION":         "blocked for reciting training data",
+	"LANGUAGE":           "language not supported",
+	"BLOCKLIST":          "contains forbidden terms",
+	"PROHIBITED_CONTENT": "potentially prohibited content",
+	"SPII":               "potentially sensitive personal information",
+	"OTHER":              "stopped for an unknown reason",
+}
+
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+// flagged returns ratings which blocked the content, or with a medium or high probability.
+func flagged(ratings []SafetyRating) (r []string) {
+	for _, s := range ratings {
+		if s.Blocked || s.Probability == "MEDIUM" || s.Probability == "HIGH" {
+			category := strings.ToLower(strings.TrimPrefix(s.Category, "HARM_CATEGORY_"))
+			r = append(r, fmt.Sprintf("%s %s", strings.ReplaceAll(category, "_", " "), strings.ToLower(s.Probability)))
+		}
+	}
+	return
+}
+
+// FinishError is an answer or a question stopped by Gemini.
+type FinishError struct {
+	Prompt  bool // the question is blocked
+	Reason  string
+	Flagged []string
+}
+
+func (that *FinishError) Error() string {
+	what := "answer"
+	if that.Prompt {
+		what = "question"
+	}
+	info, ok := FinishReasonMap[that.Reason]
+	if !ok {
+		info = "stopped"
+	}
+	s := fmt.Sprintf("%s %s (%s)", what, info, that.Reason)
+	if len(that.Flagged) > 0 {
+		s += ": " + strings.Join(that.Flagged, ", ")
+	}
+	return s
+}
+
+// NewFinishError returns io.EOF for a normal end, a FinishError otherwise.
+func NewFinishError(reason string, ratings []SafetyRating) error {
+	switch reason {
+	case "STOP", "MAX_TOKENS", "FINISH_REASON_UNSPECIFIED":
+		return io.EOF
+	}
+	return &FinishError{Reason: reason, Flagged: flagged(ratings)}
+}
+
+// NewBlockError returns the error of a blocked question.
+func NewBlockError(reason string, ratings []SafetyRating) error {
+	return &FinishError{Prompt: true, Reason: reason, Flagged: flagged(ratings)}
+}
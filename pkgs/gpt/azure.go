@@ -0,0 +1,117 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Azure AD client credentials.
+
+A bearer token is requested with the tenant, the client id and the client secret:
+
+	POST https://login.microsoftonline.com/{tenant}/oauth2/v2.0/token
+	grant_type=client_credentials&client_id=...&client_secret=...&scope=https://cognitiveservices.azure.com/.default
+
+	{"token_type": "Bearer", "expires_in": 3599, "access_token": "..."}
+
+Tokens are cached and requested again a few minutes before they expire.
+*/
+const (
+	AzureADTokenUrl string = "https://login.microsoftonline.com/{tenant}/oauth2/v2.0/token"
+	AzureADScope    string = "https://cognitiveservices.azure.com/.default"
+	azureADMargin          = 5 * time.Minute // refresh tokens expiring soon
+)
+
+type AzureADCredential struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	TokenUrl     string // {tenant} is replaced with the tenant id
+	HttpClient   *http.Client
+	lock         sync.Mutex
+	token        string
+	expires      time.Time
+}
+
+// Endpoint returns the token url of the tenant.
+func (that *AzureADCredential) Endpoint() string {
+	u := that.TokenUrl
+	if u == "" {
+		u = AzureADTokenUrl
+	}
+	return strings.ReplaceAll(u, "{tenant}", url.PathEscape(that.TenantID))
+}
+
+// Token returns the cached token, a new one is requested when it expires soon.
+func (that *AzureADCredential) Token(ctx context.Context) (string, error) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if that.token != "" && time.Now().Add(azureADMargin).Before(that.expires) {
+		return that.token, nil
+	}
+	if that.TenantID == "" || that.ClientID == "" || that.ClientSecret == "" {
+		return "", fmt.Errorf("azure ad needs a tenant id, a client id and a client secret")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", that.ClientID)
+	form.Set("client_secret", that.ClientSecret)
+	form.Set("scope", AzureADScope)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, that.Endpoint(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := that.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	r := struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("decode azure ad token, http %d: %w", resp.StatusCode, err)
+	}
+	if r.AccessToken == "" {
+		return "", fmt.Errorf("azure ad token refused, http %d: %s, %s", resp.StatusCode, r.Error, r.ErrorDescription)
+	}
+	that.token = r.AccessToken
+	that.expires = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	return that.token, nil
+}
+
+// azureADTransport authorizes every request with a token of the credential.
+type azureADTransport struct {
+	Credential *AzureADCredential
+	Base       http.RoundTripper
+}
+
+func (that *azureADTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := that.Credential.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Del("api-key")
+	req.Header.Set("Authorization", "Bearer "+token)
+	base := that.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
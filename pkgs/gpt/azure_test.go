@@ -0,0 +1,128 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// tokenServer is a local stand-in of the Azure AD token endpoint, expires lists expires_in of the tokens in turn.
+func tokenServer(t *testing.T, expires ...int) (srv *httptest.Server, requests *int) {
+	t.Helper()
+	requests = new(int)
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tenant-1/oauth2/v2.0/token" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "client" ||
+			r.Form.Get("client_secret") != "secret" || r.Form.Get("scope") != AzureADScope {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_client", "error_description": "bad credentials"}`)
+			return
+		}
+		*requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token_type":   "Bearer",
+			"expires_in":   expires[(*requests-1)%len(expires)],
+			"access_token": fmt.Sprintf("token-%d", *requests),
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return
+}
+
+func newTestCredential(srv *httptest.Server) *AzureADCredential {
+	return &AzureADCredential{
+		TenantID:     "tenant-1",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		TokenUrl:     srv.URL + "/{tenant}/oauth2/v2.0/token",
+	}
+}
+
+func TestAzureADEndpoint(t *testing.T) {
+	c := &AzureADCredential{TenantID: "contoso.onmicrosoft.com"}
+	if u := c.Endpoint(); u != "https://login.microsoftonline.com/contoso.onmicrosoft.com/oauth2/v2.0/token" {
+		t.Errorf("default endpoint %s", u)
+	}
+	c = &AzureADCredential{TenantID: "a/b", TokenUrl: "http://localhost/{tenant}/token"}
+	if u := c.Endpoint(); u != "http://localhost/a%2Fb/token" {
+		t.Errorf("escaped tenant %s", u)
+	}
+}
+
+func TestAzureADTokenCache(t *testing.T) {
+	srv, requests := tokenServer(t, 3600)
+	c := newTestCredential(srv)
+	for i := 0; i < 3; i++ {
+		token, err := c.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token-1" {
+			t.Errorf("token %s, want the cached token-1", token)
+		}
+	}
+	if *requests != 1 {
+		t.Errorf("%d token requests, want 1", *requests)
+	}
+}
+
+func TestAzureADTokenRefresh(t *testing.T) {
+	// the first token expires within the margin, the second one does not.
+	srv, requests := tokenServer(t, 60, 3600)
+	c := newTestCredential(srv)
+	for _, want := range []string{"token-1", "token-2", "token-2"} {
+		token, err := c.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != want {
+			t.Errorf("token %s, want %s", token, want)
+		}
+	}
+	if *requests != 2 {
+		t.Errorf("%d token requests, want 2", *requests)
+	}
+}
+
+func TestAzureADTokenRefused(t *testing.T) {
+	srv, _ := tokenServer(t, 3600)
+	c := newTestCredential(srv)
+	c.ClientSecret = "wrong"
+	if _, err := c.Token(context.Background()); err == nil {
+		t.Error("token with a wrong secret")
+	}
+	c.ClientSecret = ""
+	if _, err := c.Token(context.Background()); err == nil {
+		t.Error("token without a secret")
+	}
+}
+
+func TestAzureADTransport(t *testing.T) {
+	tokens, _ := tokenServer(t, 3600)
+	var auth, apiKey string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, apiKey = r.Header.Get("Authorization"), r.Header.Get("api-key")
+	}))
+	defer api.Close()
+	client := &http.Client{Transport: &azureADTransport{Credential: newTestCredential(tokens)}}
+	req, _ := http.NewRequest(http.MethodPost, api.URL, nil)
+	req.Header.Set("api-key", "unused")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if auth != "Bearer token-1" || apiKey != "" {
+		t.Errorf("Authorization %q, api-key %q", auth, apiKey)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("the original request is modified")
+	}
+}
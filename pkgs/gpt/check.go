@@ -0,0 +1,62 @@
+package gpt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Connection test.
+*/
+
+// Endpoint returns the base url requests are sent to.
+func (that *GPT) Endpoint() string {
+	return that.baseURL
+}
+
+// ProxyUrl returns the proxy in use, from the config or CHATGPT_PROXY.
+func (that *GPT) ProxyUrl() string {
+	if that.CNF.OpenAI.Proxy != "" {
+		return that.CNF.OpenAI.Proxy
+	}
+	return os.Getenv(ProxyEnv)
+}
+
+// Ping lists models with the configured key and proxy.
+func (that *GPT) Ping(ctx context.Context) error {
+	if that.err != nil {
+		return that.err
+	}
+	_, err := that.OpenAIClient.ListModels(ctx)
+	return DecodeError(err)
+}
+
+// DecodeError shows the http status and the message of api errors, as a *backend.HTTPError.
+func DecodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	apiErr := &openai.APIError{}
+	if errors.As(err, &apiErr) {
+		return &backend.HTTPError{StatusCode: apiErr.HTTPStatusCode, Message: apiErr.Message}
+	}
+	reqErr := &openai.RequestError{}
+	if errors.As(err, &reqErr) {
+		return &backend.HTTPError{StatusCode: reqErr.HTTPStatusCode, Message: fmt.Sprint(reqErr.Err)}
+	}
+	return err
+}
+
+// decodeError is DecodeError with the Retry-After of the last response.
+func (that *GPT) decodeError(err error) error {
+	err = DecodeError(err)
+	if httpErr, ok := err.(*backend.HTTPError); ok {
+		httpErr.RetryAfter = that.retryAfter
+	}
+	return err
+}
@@ -3,20 +3,20 @@ package gpt
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
+	"time"
 
-	retry "github.com/avast/retry-go"
+	"github.com/gvcgo/gogpt/pkgs/backend"
 	"github.com/gvcgo/gogpt/pkgs/config"
 	"github.com/sashabaranov/go-openai"
 	nproxy "golang.org/x/net/proxy"
 )
 
 const (
-	ProxyEnv string = "CHATGPT_PROXY"
+	ProxyEnv       string = "CHATGPT_PROXY"
+	DefaultBaseUrl string = "https://api.openai.com/v1"
 )
 
 type GPT struct {
@@ -24,6 +24,9 @@ type GPT struct {
 	Stream       *openai.ChatCompletionStream
 	CNF          *config.Config
 	HttpClient   *http.Client
+	baseURL      string
+	err          error         // unresolved secret
+	retryAfter   time.Duration // Retry-After of the last response
 }
 
 func NewGPT(cnf *config.Config) (g *GPT) {
@@ -36,37 +39,67 @@ func NewGPT(cnf *config.Config) (g *GPT) {
 
 func (that *GPT) initiate() {
 	var openaiConf openai.ClientConfig
+	apiKey, err := that.CNF.Secret(that.CNF.OpenAI.ApiKey)
+	that.err = err
 	if that.CNF.OpenAI.ApiType == openai.APITypeOpenAI || that.CNF.OpenAI.ApiType == "" {
-		openaiConf = openai.DefaultConfig(that.CNF.OpenAI.ApiKey)
-		openaiConf.BaseURL = "https://api.openai.com/v1"
-		if that.CNF.OpenAI.BaseUrl != "" {
-			openaiConf.BaseURL = that.CNF.OpenAI.BaseUrl
-		}
+		openaiConf = openai.DefaultConfig(apiKey)
+		openaiConf.BaseURL = BaseUrl(that.CNF)
 		if that.CNF.OpenAI.EmptyMessagesLimit != 0 {
 			openaiConf.EmptyMessagesLimit = that.CNF.OpenAI.EmptyMessagesLimit
 		}
 	} else {
-		openaiConf = openai.DefaultAzureConfig(that.CNF.OpenAI.ApiKey, that.CNF.OpenAI.BaseUrl)
+		openaiConf = openai.DefaultAzureConfig(apiKey, that.CNF.OpenAI.BaseUrl)
 		if that.CNF.OpenAI.OrgID != "" {
 			openaiConf.OrgID = that.CNF.OpenAI.OrgID
 		}
 		if that.CNF.OpenAI.ApiVersion != "" {
 			openaiConf.APIVersion = that.CNF.OpenAI.ApiVersion
 		}
+		// The engine is the deployment name, models are mapped by default.
+		if deployment := that.CNF.OpenAI.Engine; deployment != "" {
+			openaiConf.AzureModelMapperFunc = func(string) string { return deployment }
+		}
 	}
 	if that.CNF.OpenAI.EmptyMessagesLimit != 0 {
 		openaiConf.EmptyMessagesLimit = that.CNF.OpenAI.EmptyMessagesLimit
 	}
+	that.baseURL = openaiConf.BaseURL
 	openaiConf.HTTPClient = that.getHttpClient()
+	if that.CNF.OpenAI.ApiType == openai.APITypeAzureAD {
+		openaiConf.APIType = openai.APITypeAzureAD
+		openaiConf.HTTPClient = that.azureADClient()
+	}
 	that.OpenAIClient = openai.NewClientWithConfig(openaiConf)
 }
 
+// BaseUrl returns the base url of the config, without creating a client.
+func BaseUrl(cnf *config.Config) string {
+	if cnf.OpenAI.BaseUrl == "" && (cnf.OpenAI.ApiType == openai.APITypeOpenAI || cnf.OpenAI.ApiType == "") {
+		return DefaultBaseUrl
+	}
+	return cnf.OpenAI.BaseUrl
+}
+
+// azureADClient authorizes requests with Azure AD tokens instead of the api key.
+func (that *GPT) azureADClient() *http.Client {
+	clientSecret, err := that.CNF.Secret(that.CNF.OpenAI.ClientSecret)
+	that.err = err // the api key is not used.
+	cred := &AzureADCredential{
+		TenantID:     that.CNF.OpenAI.TenantID,
+		ClientID:     that.CNF.OpenAI.ClientID,
+		ClientSecret: clientSecret,
+		TokenUrl:     that.CNF.OpenAI.TokenUrl,
+		HttpClient:   that.HttpClient,
+	}
+	return &http.Client{Transport: &azureADTransport{Credential: cred, Base: that.HttpClient.Transport}}
+}
+
 func (that *GPT) getHttpClient() *http.Client {
 	scheme, host, port := that.parseProxy()
 	that.HttpClient = &http.Client{}
 	switch scheme {
 	case "http", "https":
-		pUrl, err := url.Parse(that.CNF.OpenAI.Proxy)
+		pUrl, err := url.Parse(that.ProxyUrl())
 		if err != nil {
 			return that.HttpClient
 		}
@@ -79,14 +112,30 @@ func (that *GPT) getHttpClient() *http.Client {
 		}
 	default:
 	}
+	that.HttpClient.Transport = &retryAfterTransport{GPT: that, Base: that.HttpClient.Transport}
 	return that.HttpClient
 }
 
-func (that *GPT) parseProxy() (scheme, host string, port int) {
-	p := that.CNF.OpenAI.Proxy
-	if p == "" {
-		p = os.Getenv(ProxyEnv)
+// retryAfterTransport remembers the Retry-After header of responses.
+type retryAfterTransport struct {
+	GPT  *GPT
+	Base http.RoundTripper
+}
+
+func (that *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := that.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err == nil {
+		that.GPT.retryAfter = backend.ParseRetryAfter(resp.Header.Get("Retry-After"))
 	}
+	return resp, err
+}
+
+func (that *GPT) parseProxy() (scheme, host string, port int) {
+	p := that.ProxyUrl()
 	if p == "" {
 		return
 	}
@@ -107,57 +156,43 @@ func (that *GPT) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err err
 		gptModel = openai.GPT3Dot5Turbo0613
 	}
 	that.Stream = nil
-	err = retry.Do(
-		func() error {
-			req := openai.ChatCompletionRequest{
-				Model:       that.CNF.OpenAI.Model,
-				Messages:    msgs,
-				MaxTokens:   1024,
-				Temperature: that.CNF.OpenAI.Temperature,
-				N:           1,
-			}
-			that.Stream, err = that.OpenAIClient.CreateChatCompletionStream(context.Background(), req)
-			if err != nil {
-				that.Stream = nil
-				return err
-			}
-			resp, err := that.Stream.Recv()
-			if err != nil {
-				return err
-			}
-			m = resp.Choices[0].Delta.Content
-			return nil
-		},
-		retry.Attempts(3),
-		retry.LastErrorOnly(true),
-	)
+	if that.err != nil {
+		return "", that.err
+	}
+	req := openai.ChatCompletionRequest{
+		Model:       gptModel,
+		Messages:    msgs,
+		MaxTokens:   1024,
+		Temperature: that.CNF.OpenAI.Temperature,
+		N:           1,
+	}
+	that.Stream, err = that.OpenAIClient.CreateChatCompletionStream(context.Background(), req)
 	if err != nil {
-		return "", err
+		that.Stream = nil
+		return "", that.decodeError(err)
 	}
-	return
+	return that.recv()
 }
 
 func (that *GPT) RecvMsg() (m string, err error) {
 	if that.Stream == nil {
 		return "", fmt.Errorf("no stream found")
 	}
-	resp, recvErr := that.Stream.Recv()
-	if recvErr != nil || recvErr == io.EOF {
-		return "", recvErr
-	} else if recvErr == nil {
-		m = resp.Choices[0].Delta.Content
-		return m, nil
-	} else {
-		err = retry.Do(func() error {
-			resp, recvErr := that.Stream.Recv()
-			if recvErr != nil {
-				return recvErr
-			}
-			m = resp.Choices[0].Delta.Content
-			return nil
-		})
+	return that.recv()
+}
+
+// recv returns the content of the next chunk with choices,
+// Azure also sends chunks without choices, like prompt filter results.
+func (that *GPT) recv() (string, error) {
+	for {
+		resp, err := that.Stream.Recv()
+		if err != nil {
+			return "", err
+		}
+		if len(resp.Choices) > 0 {
+			return resp.Choices[0].Delta.Content, nil
+		}
 	}
-	return
 }
 
 func (that *GPT) Close() {
@@ -0,0 +1,46 @@
+package gpt
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestStreamSkipsChunksWithoutChoices(t *testing.T) {
+	chunks := []string{
+		`{"id": "", "object": "", "created": 0, "model": "", "choices": [], "prompt_filter_results": [{"prompt_index": 0}]}`,
+		`{"id": "1", "object": "chat.completion.chunk", "choices": [{"index": 0, "delta": {"role": "assistant", "content": "Hello"}}]}`,
+		`{"id": "1", "object": "chat.completion.chunk", "choices": []}`,
+		`{"id": "1", "object": "chat.completion.chunk", "choices": [{"index": 0, "delta": {"content": ", world"}}]}`,
+		`{"id": "1", "object": "chat.completion.chunk", "choices": [], "usage": {"prompt_tokens": 5, "completion_tokens": 3}}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", c)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.OpenAI.BaseUrl = srv.URL
+	cnf.OpenAI.ApiKey = "sk-test"
+	g := NewGPT(cnf)
+	answer, err := g.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}})
+	for err == nil {
+		var m string
+		m, err = g.RecvMsg()
+		answer += m
+	}
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	if answer != "Hello, world" {
+		t.Errorf("answer %q", answer)
+	}
+}
@@ -0,0 +1,110 @@
+package gpt
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+/*
+Models served by the configured base url, cached in the work dir.
+*/
+const (
+	ModelCacheFileName string = "gpt_models_cache.json"
+	ModelCacheTTL             = 24 * time.Hour
+	ModelListTimeout          = 15 * time.Second
+)
+
+type ModelCacheItem struct {
+	Models    []string  `json:"models"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ModelCache maps base urls to their models.
+type ModelCache map[string]*ModelCacheItem
+
+func modelCachePath(cnf *config.Config) string {
+	return filepath.Join(cnf.GetCacheDir(), ModelCacheFileName)
+}
+
+func loadModelCache(cnf *config.Config) ModelCache {
+	mc := ModelCache{}
+	if content, err := os.ReadFile(modelCachePath(cnf)); err == nil {
+		json.Unmarshal(content, &mc)
+	}
+	return mc
+}
+
+func (that ModelCache) save(cnf *config.Config) error {
+	content, err := json.MarshalIndent(that, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(modelCachePath(cnf), content, 0644)
+}
+
+// ListModels returns sorted model IDs from the models endpoint.
+func (that *GPT) ListModels(ctx context.Context) (models []string, err error) {
+	if that.err != nil {
+		return nil, that.err
+	}
+	list, err := that.OpenAIClient.ListModels(ctx)
+	if err != nil {
+		return nil, DecodeError(err)
+	}
+	for _, m := range list.Models {
+		models = append(models, m.ID)
+	}
+	sort.Strings(models)
+	return
+}
+
+// CachedModels returns cached models of the configured base url, even if expired.
+func CachedModels(cnf *config.Config) []string {
+	if item := loadModelCache(cnf)[BaseUrl(cnf)]; item != nil {
+		return item.Models
+	}
+	return nil
+}
+
+// DiscoverModels lists models of the configured base url, the cache is used until ModelCacheTTL
+// unless refresh is set. The expired cache is returned with the error if the request fails.
+func DiscoverModels(cnf *config.Config, refresh bool) ([]string, error) {
+	g := NewGPT(cnf)
+	mc := loadModelCache(cnf)
+	item := mc[g.Endpoint()]
+	if item != nil && !refresh && time.Since(item.UpdatedAt) < ModelCacheTTL {
+		return item.Models, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ModelListTimeout)
+	defer cancel()
+	models, err := g.ListModels(ctx)
+	if err != nil {
+		if item != nil {
+			return item.Models, err
+		}
+		return nil, err
+	}
+	mc[g.Endpoint()] = &ModelCacheItem{Models: models, UpdatedAt: time.Now()}
+	return models, mc.save(cnf)
+}
+
+// MergeModels returns discovered models first, then the static ones not discovered.
+func MergeModels(discovered, static []string) (models []string) {
+	seen := map[string]struct{}{}
+	for _, list := range [][]string{discovered, static} {
+		for _, m := range list {
+			if _, ok := seen[m]; ok || m == "" {
+				continue
+			}
+			seen[m] = struct{}{}
+			models = append(models, m)
+		}
+	}
+	return
+}
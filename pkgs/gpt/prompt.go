@@ -29,7 +29,7 @@ type GPTPrompt struct {
 }
 
 func NewGPTPrompt(cnf *config.Config) (gp *GPTPrompt) {
-	gp = &GPTPrompt{CNF: cnf, path: filepath.Join(cnf.GetWorkDir(), PromptFileName)}
+	gp = &GPTPrompt{CNF: cnf, path: filepath.Join(cnf.GetCacheDir(), PromptFileName)}
 	gp.PromptList = &([]PromptItem{})
 	gp.initiate()
 	return
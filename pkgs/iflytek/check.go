@@ -0,0 +1,78 @@
+package iflytek
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/sashabaranov/go-openai"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+/*
+Connection test.
+*/
+
+// Endpoint returns the websocket url of the api version.
+func (that *Spark) Endpoint() string {
+	return that.hostUrl
+}
+
+// SetHostUrl replaces the url of the api version, for gateways or local servers.
+func (that *Spark) SetHostUrl(hostUrl string) error {
+	that.hostUrl = hostUrl
+	return that.signAuthUrl()
+}
+
+// Ping does the websocket handshake and a one-token chat on a new connection.
+func (that *Spark) Ping(ctx context.Context) error {
+	if err := that.signAuthUrl(); err != nil {
+		return err
+	}
+	conn, resp, err := websocket.Dial(ctx, that.AuthUrl, nil)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("handshake failed, http %d: %s", resp.StatusCode, readBody(resp))
+		}
+		return fmt.Errorf("handshake failed: %w", err)
+	}
+	defer conn.CloseNow()
+
+	reqData := that.generateRequestData([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+	})
+	reqData["parameter"].(map[string]interface{})["chat"].(map[string]interface{})["max_tokens"] = 1
+	if err = wsjson.Write(ctx, conn, reqData); err != nil {
+		return err
+	}
+	for {
+		var msg map[string]interface{}
+		if err = wsjson.Read(ctx, conn, &msg); err != nil {
+			return err
+		}
+		r := NewSparkResponse(msg)
+		r.Parse()
+		if r.ErrCode != 0 {
+			if r.Error == nil {
+				// codes missing in SparkErrorMap.
+				return NewSparkError(r.ErrCode, gjson.New(msg).Get("header.message").String())
+			}
+			return r.Error
+		}
+		if r.Error == io.EOF {
+			conn.Close(websocket.StatusNormalClosure, "")
+			return nil
+		}
+	}
+}
+
+func readBody(resp *http.Response) string {
+	if resp.Body == nil {
+		return ""
+	}
+	b, _ := io.ReadAll(resp.Body)
+	return string(b)
+}
@@ -6,15 +6,12 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/avast/retry-go"
-	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+	"github.com/gvcgo/gogpt/pkgs/backend"
 	"github.com/gvcgo/gogpt/pkgs/config"
 	"github.com/sashabaranov/go-openai"
 	"nhooyr.io/websocket"
@@ -82,6 +79,7 @@ type Spark struct {
 	sparkDomain string
 	hostUrl     string
 	token       int64
+	err         error // unresolved secrets
 }
 
 func NewSpark(cnf *config.Config) (s *Spark) {
@@ -108,11 +106,24 @@ func (that *Spark) AssembleAuthUrl() {
 		that.hostUrl = config.SparkAPIV1Dot1
 		that.sparkDomain = config.SparkDomainV1
 	}
+	if err := that.signAuthUrl(); err != nil {
+		that.err = err
+	}
+}
 
+// signAuthUrl signs hostUrl with the current time.
+func (that *Spark) signAuthUrl() error {
 	ul, err := url.Parse(that.hostUrl)
 	if err != nil {
-		gprint.PrintError("parse spark url failed: %+v", err)
-		os.Exit(1)
+		return fmt.Errorf("parse spark url failed: %w", err)
+	}
+	appKey, err := that.CNF.Secret(that.CNF.Spark.APPKey)
+	if err != nil {
+		return err
+	}
+	appSecrete, err := that.CNF.Secret(that.CNF.Spark.APPSecrete)
+	if err != nil {
+		return err
 	}
 	//签名时间 "Tue, 28 May 2019 09:10:42 MST"
 	date := time.Now().UTC().Format(time.RFC1123)
@@ -124,12 +135,12 @@ func (that *Spark) AssembleAuthUrl() {
 	sgin := strings.Join(signString, "\n")
 
 	//签名结果
-	sha := that.HmacWithShaTobase64("hmac-sha256", sgin, that.CNF.Spark.APPSecrete)
+	sha := that.HmacWithShaTobase64("hmac-sha256", sgin, appSecrete)
 
 	//构建请求参数 此时不需要urlencoding
 	authUrl := fmt.Sprintf(
 		"hmac username=\"%s\", algorithm=\"%s\", headers=\"%s\", signature=\"%s\"",
-		that.CNF.Spark.APPKey,
+		appKey,
 		"hmac-sha256", "host date request-line",
 		sha,
 	)
@@ -144,17 +155,7 @@ func (that *Spark) AssembleAuthUrl() {
 
 	//将编码后的字符串url encode后添加到url后面
 	that.AuthUrl = that.hostUrl + "?" + v.Encode()
-}
-
-func (that *Spark) readResp(resp *http.Response) string {
-	if resp == nil {
-		return ""
-	}
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
-	}
-	return fmt.Sprintf("code=%d,body=%s", resp.StatusCode, string(b))
+	return nil
 }
 
 func (that *Spark) HmacWithShaTobase64(algorithm, data, key string) string {
@@ -164,7 +165,8 @@ func (that *Spark) HmacWithShaTobase64(algorithm, data, key string) string {
 	return base64.StdEncoding.EncodeToString(encodeData)
 }
 
-func (that *Spark) Connect() {
+// Connect dials a new connection, the handshake response is shown on failure.
+func (that *Spark) Connect() error {
 	if that.CNF.Spark.Timeout == 0 {
 		that.CNF.Spark.Timeout = 60
 	}
@@ -174,27 +176,22 @@ func (that *Spark) Connect() {
 		// Spark v1.1 一次回答之后会自动关闭会话，从而导致继续使用原有Conn读写会出错
 		// 所以这里先关闭本地Conn，然后重新连接。
 		that.Conn.CloseNow()
+		that.Conn = nil
 		time.Sleep(2 * time.Second)
 	}
-	var (
-		resp *http.Response
-		err  error
-	)
-
-	err = retry.Do(
-		func() error {
-			that.Conn, resp, err = websocket.Dial(ctx, that.AuthUrl, nil)
-			return err
-		},
-		retry.Attempts(3),
-		retry.LastErrorOnly(true),
-	)
-
-	if err != nil || resp == nil || resp.StatusCode != 101 {
-		fmt.Println(that.readResp(resp))
-		fmt.Printf("%+v", err)
-		os.Exit(1)
+	conn, resp, err := websocket.Dial(ctx, that.AuthUrl, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+			return &backend.HTTPError{
+				StatusCode: resp.StatusCode,
+				Message:    readBody(resp),
+				RetryAfter: backend.ParseRetryAfter(resp.Header.Get("Retry-After")),
+			}
+		}
+		return fmt.Errorf("connect spark: %w", err)
 	}
+	that.Conn = conn
+	return nil
 }
 
 func (that *Spark) generateRequestData(msgs []openai.ChatCompletionMessage) RequestData {
@@ -245,20 +242,16 @@ func (that *Spark) generateRequestData(msgs []openai.ChatCompletionMessage) Requ
 }
 
 func (that *Spark) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error) {
-	that.Connect()
-	reqData := that.generateRequestData(msgs)
-	if that.Conn == nil {
-		return
+	if that.err != nil {
+		return "", that.err
 	}
-	err = retry.Do(func() error {
-		ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
-		defer cancel()
-		return wsjson.Write(ctx, that.Conn, reqData)
-	},
-		retry.Attempts(3),
-		retry.LastErrorOnly(true),
-	)
-	return "", err
+	if err = that.Connect(); err != nil {
+		return "", err
+	}
+	reqData := that.generateRequestData(msgs)
+	ctx, cancel := context.WithTimeout(context.Background(), 180*time.Second)
+	defer cancel()
+	return "", wsjson.Write(ctx, that.Conn, reqData)
 }
 
 func (that *Spark) RecvMsg() (m string, err error) {
@@ -3,8 +3,10 @@ package iflytek
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gvcgo/gogpt/pkgs/backend"
 )
 
 /*
@@ -160,6 +162,15 @@ var SparkErrorMap map[int]error = map[int]error{
 	11203: ErrExceedConcurrencyLimit,
 }
 
+// Retryable tells if err is a busy server or a request limit worth another try.
+func Retryable(err error) (bool, time.Duration) {
+	switch err {
+	case ErrServerBusy, ErrExceedSecondReqLimit, ErrExceedConcurrencyLimit:
+		return true, 0
+	}
+	return backend.Retryable(err)
+}
+
 type ResponseMsg struct {
 	Content string `json:"content"`
 	Role    string `json:"role"`
@@ -0,0 +1,134 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+/*
+Local models.
+
+	GET  {base_url}/api/tags    {"models": [{"name": "llama3:latest", "size": 4661224676, ...}]}
+	POST {base_url}/api/pull    {"model": "llama3", "stream": true}
+
+Pulling streams json lines like {"status": "pulling 6a0746a1ec1a", "total": 4661211424, "completed": 1024},
+the last one is {"status": "success"}.
+*/
+type ModelDetails struct {
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+type Model struct {
+	Name       string       `json:"name"`
+	Size       int64        `json:"size"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Details    ModelDetails `json:"details"`
+}
+
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error"`
+}
+
+// Percent returns the completed part of the current layer, 0 if unknown.
+func (that PullProgress) Percent() float64 {
+	if that.Total <= 0 {
+		return 0
+	}
+	return float64(that.Completed) / float64(that.Total)
+}
+
+// ListModels returns models pulled to the server.
+func ListModels(ctx context.Context, cnf *config.Config) ([]Model, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, BaseUrl(cnf)+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, readError(resp)
+	}
+	defer resp.Body.Close()
+	r := struct {
+		Models []Model `json:"models"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("decode models: %w", err)
+	}
+	return r.Models, nil
+}
+
+// HasModel tells if name is in models, the tag "latest" is optional.
+func HasModel(models []Model, name string) bool {
+	if !strings.Contains(name, ":") {
+		name += ":latest"
+	}
+	for _, m := range models {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Pull downloads a model, progress is called for each status line.
+func Pull(ctx context.Context, cnf *config.Config, name string, progress func(PullProgress)) error {
+	body, _ := json.Marshal(map[string]interface{}{"model": name, "stream": true})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, BaseUrl(cnf)+"/api/pull", strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	defer resp.Body.Close()
+	lines := backend.NewNDJSONReader(resp.Body)
+	for {
+		p := PullProgress{}
+		if err = lines.Next(&p); err == io.EOF {
+			return io.ErrUnexpectedEOF
+		} else if err != nil {
+			return err
+		}
+		if p.Error != "" {
+			return fmt.Errorf("pull %s: %s", name, p.Error)
+		}
+		progress(p)
+		if p.Status == "success" {
+			return nil
+		}
+	}
+}
+
+// Ping checks the server and that the model is pulled.
+func (that *Ollama) Ping(ctx context.Context) error {
+	models, err := ListModels(ctx, that.CNF)
+	if err != nil {
+		return err
+	}
+	if !HasModel(models, that.model()) {
+		return fmt.Errorf("model %s is not pulled, pull it from the Models tab", that.model())
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Ollama chat API.
+
+	POST {base_url}/api/chat
+
+	{"model": "llama3", "stream": true, "keep_alive": "5m",
+	 "messages": [{"role": "system", "content": "..."}, {"role": "user", "content": "..."}],
+	 "options": {"num_ctx": 4096, "temperature": 0.8}}
+
+The answer is streamed as json lines:
+
+	{"message": {"role": "assistant", "content": "..."}, "done": false}
+	{"done": true, "done_reason": "stop", "prompt_eval_count": 26, "eval_count": 298}
+	{"error": "..."}
+*/
+const (
+	DefaultBaseUrl string = "http://localhost:11434"
+	DefaultModel   string = "llama3"
+	DefaultNumCtx  int    = 2048
+)
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type Request struct {
+	Model     string                 `json:"model"`
+	Messages  []Message              `json:"messages"`
+	Stream    bool                   `json:"stream"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+}
+
+type chatChunk struct {
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason"`
+	PromptEvalCount int64   `json:"prompt_eval_count"`
+	EvalCount       int64   `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+type Ollama struct {
+	CNF        *config.Config
+	HttpClient *http.Client
+	resp       *http.Response
+	lines      *backend.NDJSONReader
+	tokens     int64 // usage not reported by GetTokens yet
+}
+
+func NewOllama(cnf *config.Config) (o *Ollama) {
+	o = &Ollama{
+		CNF:        cnf,
+		HttpClient: &http.Client{},
+	}
+	return
+}
+
+// BaseUrl returns the url of the ollama server.
+func BaseUrl(cnf *config.Config) string {
+	base := cnf.Ollama.BaseUrl
+	if base == "" {
+		base = DefaultBaseUrl
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// Endpoint returns the url of the chat api.
+func (that *Ollama) Endpoint() string {
+	return BaseUrl(that.CNF) + "/api/chat"
+}
+
+func (that *Ollama) model() string {
+	if that.CNF.Ollama.Model == "" {
+		return DefaultModel
+	}
+	return that.CNF.Ollama.Model
+}
+
+// NewRequest converts chat messages, options of the config are passed through,
+// num_ctx and temperature are set by their own fields.
+func (that *Ollama) NewRequest(msgs []openai.ChatCompletionMessage) *Request {
+	req := &Request{
+		Model:     that.model(),
+		Stream:    true,
+		KeepAlive: that.CNF.Ollama.KeepAlive,
+		Options:   map[string]interface{}{},
+	}
+	for k, v := range that.CNF.Ollama.Options {
+		req.Options[k] = v
+	}
+	if that.CNF.Ollama.NumCtx > 0 {
+		req.Options["num_ctx"] = that.CNF.Ollama.NumCtx
+	}
+	if that.CNF.Ollama.Temperature > 0 {
+		req.Options["temperature"] = that.CNF.Ollama.Temperature
+	}
+	for _, m := range msgs {
+		if m.Role == openai.ChatMessageRoleSystem && m.Content == "" {
+			continue
+		}
+		req.Messages = append(req.Messages, Message{Role: m.Role, Content: m.Content})
+	}
+	return req
+}
+
+// readError decodes {"error": "..."}.
+func readError(resp *http.Response) error {
+	return backend.ReadError(resp, func(body []byte) string {
+		r := &chatChunk{}
+		if json.Unmarshal(body, r) == nil {
+			return r.Error
+		}
+		return ""
+	})
+}
+
+func (that *Ollama) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error) {
+	that.Close()
+	resp, err := backend.PostJSON(that.HttpClient, that.Endpoint(), nil, that.NewRequest(msgs))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", readError(resp)
+	}
+	that.resp = resp
+	that.lines = backend.NewNDJSONReader(resp.Body)
+	return "", nil
+}
+
+// RecvMsg returns the content of the next line, io.EOF after the done line.
+func (that *Ollama) RecvMsg() (m string, err error) {
+	if that.lines == nil {
+		return "", fmt.Errorf("no stream found")
+	}
+	for {
+		r := &chatChunk{}
+		if err = that.lines.Next(r); err != nil {
+			that.Close()
+			if err == io.EOF {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if r.Error != "" {
+			that.Close()
+			return "", fmt.Errorf("ollama: %s", r.Error)
+		}
+		if r.Done {
+			that.tokens += r.PromptEvalCount + r.EvalCount
+			that.Close()
+			return r.Message.Content, io.EOF
+		}
+		if r.Message.Content != "" {
+			return r.Message.Content, nil
+		}
+	}
+}
+
+func (that *Ollama) Close() {
+	if that.resp != nil {
+		that.resp.Body.Close()
+	}
+	that.resp = nil
+	that.lines = nil
+}
+
+// GetTokens returns the tokens reported by the server since the last call.
+func (that *Ollama) GetTokens() (tokens int64) {
+	tokens, that.tokens = that.tokens, 0
+	return
+}
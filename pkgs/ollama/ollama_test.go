@@ -0,0 +1,187 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// newTestConf returns a config of a fake ollama server, handlers are keyed by path.
+func newTestConf(t *testing.T, handlers map[string]http.HandlerFunc) *config.Config {
+	t.Helper()
+	mux := http.NewServeMux()
+	for path, h := range handlers {
+		mux.HandleFunc(path, h)
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.Ollama.BaseUrl = srv.URL + "/"
+	return cnf
+}
+
+func ndjson(lines ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, l := range lines {
+			fmt.Fprintln(w, l)
+		}
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	req := &Request{}
+	cnf := newTestConf(t, map[string]http.HandlerFunc{
+		"/api/chat": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(req)
+			ndjson(
+				`{"message": {"role": "assistant", "content": "Hello"}, "done": false}`,
+				`{"message": {"role": "assistant", "content": ""}, "done": false}`,
+				`{"message": {"role": "assistant", "content": ", world"}, "done": false}`,
+				`{"message": {"role": "assistant", "content": "!"}, "done": true, "done_reason": "stop", "prompt_eval_count": 26, "eval_count": 4}`,
+			)(w, r)
+		},
+	})
+	cnf.Ollama.Model = "llama3"
+	cnf.Ollama.NumCtx = 4096
+	cnf.Ollama.Options = map[string]interface{}{"top_k": 20}
+	o := NewOllama(cnf)
+	if _, err := o.SendMsg([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: ""},
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	answer := ""
+	for {
+		m, err := o.RecvMsg()
+		answer += m
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if answer != "Hello, world!" {
+		t.Errorf("answer %q", answer)
+	}
+	if tokens := o.GetTokens(); tokens != 30 {
+		t.Errorf("tokens %d, want 30", tokens)
+	}
+	if !req.Stream || req.Model != "llama3" || len(req.Messages) != 1 || req.Options["num_ctx"] != float64(4096) || req.Options["top_k"] != float64(20) {
+		t.Errorf("request %+v", req)
+	}
+}
+
+func TestChatErrors(t *testing.T) {
+	cnf := newTestConf(t, map[string]http.HandlerFunc{
+		"/api/chat": ndjson(
+			`{"message": {"role": "assistant", "content": "Hel"}, "done": false}`,
+			`{"error": "model runner has unexpectedly stopped"}`,
+		),
+	})
+	o := NewOllama(cnf)
+	if _, err := o.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := o.RecvMsg(); m != "Hel" || err != nil {
+		t.Fatalf("first line: %q, %v", m, err)
+	}
+	if _, err := o.RecvMsg(); err == nil || !strings.Contains(err.Error(), "unexpectedly stopped") {
+		t.Errorf("error line: %v", err)
+	}
+
+	cnf = newTestConf(t, map[string]http.HandlerFunc{
+		"/api/chat": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": "model \"llama9\" not found, try pulling it first"}`)
+		},
+	})
+	if _, err := NewOllama(cnf).SendMsg(nil); err == nil || !strings.Contains(err.Error(), "try pulling it first") {
+		t.Errorf("http error: %v", err)
+	}
+}
+
+func TestListModels(t *testing.T) {
+	cnf := newTestConf(t, map[string]http.HandlerFunc{
+		"/api/tags": ndjson(`{"models": [
+			{"name": "llama3:latest", "size": 4661224676, "modified_at": "2024-05-01T10:00:00Z", "details": {"family": "llama", "parameter_size": "8.0B", "quantization_level": "Q4_0"}},
+			{"name": "qwen2:7b", "size": 4431400262}
+		]}`),
+	})
+	models, err := ListModels(context.Background(), cnf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].Details.ParameterSize != "8.0B" || models[1].Size != 4431400262 {
+		t.Fatalf("models %+v", models)
+	}
+	for name, ok := range map[string]bool{"llama3": true, "llama3:latest": true, "qwen2:7b": true, "qwen2": false} {
+		if HasModel(models, name) != ok {
+			t.Errorf("HasModel(%q) is %v", name, !ok)
+		}
+	}
+	cnf.Ollama.Model = "qwen2"
+	if err = NewOllama(cnf).Ping(context.Background()); err == nil {
+		t.Error("ping with a model not pulled")
+	}
+}
+
+func TestPull(t *testing.T) {
+	var pulled string
+	cnf := newTestConf(t, map[string]http.HandlerFunc{
+		"/api/pull": func(w http.ResponseWriter, r *http.Request) {
+			body := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			pulled, _ = body["model"].(string)
+			ndjson(
+				`{"status": "pulling manifest"}`,
+				`{"status": "pulling 6a0746a1ec1a", "digest": "sha256:6a0746a1ec1a", "total": 4000, "completed": 1000}`,
+				`{"status": "pulling 6a0746a1ec1a", "digest": "sha256:6a0746a1ec1a", "total": 4000, "completed": 4000}`,
+				`{"status": "verifying sha256 digest"}`,
+				`{"status": "success"}`,
+			)(w, r)
+		},
+	})
+	percents := []float64{}
+	err := Pull(context.Background(), cnf, "llama3", func(p PullProgress) {
+		percents = append(percents, p.Percent())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pulled != "llama3" {
+		t.Errorf("pulled %q", pulled)
+	}
+	if fmt.Sprint(percents) != "[0 0.25 1 0 0]" {
+		t.Errorf("progress %v", percents)
+	}
+}
+
+func TestPullErrors(t *testing.T) {
+	cnf := newTestConf(t, map[string]http.HandlerFunc{
+		"/api/pull": ndjson(
+			`{"status": "pulling manifest"}`,
+			`{"error": "pull model manifest: file does not exist"}`,
+		),
+	})
+	err := Pull(context.Background(), cnf, "llama9", func(PullProgress) {})
+	if err == nil || !strings.Contains(err.Error(), "file does not exist") {
+		t.Errorf("error line: %v", err)
+	}
+
+	cnf = newTestConf(t, map[string]http.HandlerFunc{
+		"/api/pull": ndjson(`{"status": "pulling manifest"}`),
+	})
+	if err = Pull(context.Background(), cnf, "llama3", func(PullProgress) {}); err != io.ErrUnexpectedEOF {
+		t.Errorf("stream without success: %v", err)
+	}
+}
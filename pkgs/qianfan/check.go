@@ -0,0 +1,28 @@
+package qianfan
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Connection test.
+*/
+
+// Ping requests an access token and sends a short question without streaming.
+func (that *Ernie) Ping(ctx context.Context) error {
+	e := *that
+	e.HttpClient = &http.Client{}
+	if deadline, ok := ctx.Deadline(); ok {
+		e.HttpClient.Timeout = time.Until(deadline)
+	}
+	req := e.NewRequest([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+	})
+	req.Stream, req.MaxOutputTokens = false, 2
+	_, err := e.send(req)
+	return err
+}
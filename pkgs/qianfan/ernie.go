@@ -0,0 +1,290 @@
+package qianfan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Baidu Qianfan chat API of ERNIE.
+
+An access token is exchanged with the api key and the secret key first, it lasts 30 days:
+
+	POST {base_url}/oauth/2.0/token?grant_type=client_credentials&client_id={api_key}&client_secret={secret_key}
+
+	POST {base_url}/rpc/2.0/ai_custom/v1/wenxinworkshop/chat/{model}?access_token=...
+
+	{"system": "...", "stream": true,
+	 "messages": [{"role": "user", "content": "..."}, {"role": "assistant", "content": "..."}, {"role": "user", "content": "..."}]}
+
+The stream is server-sent events:
+
+	data: {"result": "...", "is_end": false, "usage": {"total_tokens": 20}}
+
+Errors come as json even for a stream, with status 200: {"error_code": 110, "error_msg": "..."}.
+*/
+const (
+	DefaultBaseUrl string = "https://aip.baidubce.com"
+	DefaultModel   string = "completions"
+	tokenMargin           = time.Hour // refresh tokens expiring soon
+)
+
+// Model endpoints for selection.
+var ModelList = []string{
+	"completions",      // ERNIE-3.5-8K
+	"completions_pro",  // ERNIE-4.0-8K
+	"ernie-speed-128k", // ERNIE-Speed-128K
+	"ernie-lite-8k",    // ERNIE-Lite-8K
+	"eb-instant",       // ERNIE-Bot-turbo
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type Request struct {
+	Messages        []Message `json:"messages"`
+	System          string    `json:"system,omitempty"`
+	Stream          bool      `json:"stream"`
+	Temperature     float64   `json:"temperature,omitempty"`
+	MaxOutputTokens int       `json:"max_output_tokens,omitempty"`
+}
+
+type Response struct {
+	Result           string `json:"result"`
+	IsEnd            bool   `json:"is_end"`
+	NeedClearHistory bool   `json:"need_clear_history"`
+	Usage            struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+		TotalTokens      int64 `json:"total_tokens"`
+	} `json:"usage"`
+	ErrorCode int    `json:"error_code"`
+	ErrorMsg  string `json:"error_msg"`
+}
+
+type accessToken struct {
+	Token   string
+	Expires time.Time
+}
+
+// access tokens by api key, shared by clients.
+var (
+	tokenLock sync.Mutex
+	tokens    = map[string]accessToken{}
+)
+
+type Ernie struct {
+	CNF        *config.Config
+	HttpClient *http.Client
+	resp       *http.Response
+	events     *backend.SSEReader
+	tokens     int64 // usage not reported by GetTokens yet
+}
+
+func NewErnie(cnf *config.Config) (e *Ernie) {
+	e = &Ernie{
+		CNF:        cnf,
+		HttpClient: &http.Client{},
+	}
+	return
+}
+
+func (that *Ernie) baseUrl() string {
+	base := that.CNF.Ernie.BaseUrl
+	if base == "" {
+		base = DefaultBaseUrl
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// Endpoint returns the url of the chat api, without the access token.
+func (that *Ernie) Endpoint() string {
+	model := that.CNF.Ernie.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	return that.baseUrl() + "/rpc/2.0/ai_custom/v1/wenxinworkshop/chat/" + model
+}
+
+// AccessToken returns the cached token, a new one is requested when refresh is true or it expires soon.
+func (that *Ernie) AccessToken(refresh bool) (string, error) {
+	apiKey, err := that.CNF.Secret(that.CNF.Ernie.ApiKey)
+	if err != nil {
+		return "", err
+	}
+	secretKey, err := that.CNF.Secret(that.CNF.Ernie.SecretKey)
+	if err != nil {
+		return "", err
+	}
+	tokenLock.Lock()
+	defer tokenLock.Unlock()
+	if t, ok := tokens[apiKey]; ok && !refresh && time.Now().Add(tokenMargin).Before(t.Expires) {
+		return t.Token, nil
+	}
+	query := url.Values{}
+	query.Set("grant_type", "client_credentials")
+	query.Set("client_id", apiKey)
+	query.Set("client_secret", secretKey)
+	resp, err := that.HttpClient.Post(that.baseUrl()+"/oauth/2.0/token?"+query.Encode(), "application/json", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	r := struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("decode access token, http %d: %w", resp.StatusCode, err)
+	}
+	if r.AccessToken == "" {
+		return "", fmt.Errorf("%w: %s, %s", ErrTokenRequestRefused, r.Error, r.ErrorDescription)
+	}
+	tokens[apiKey] = accessToken{Token: r.AccessToken, Expires: time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)}
+	return r.AccessToken, nil
+}
+
+// NewRequest converts chat messages, the system prompt goes to the top level
+// and consecutive messages of the same role are merged, as the api requires alternate roles.
+func (that *Ernie) NewRequest(msgs []openai.ChatCompletionMessage) *Request {
+	req := &Request{
+		Stream:          true,
+		Temperature:     that.CNF.Ernie.Temperature,
+		MaxOutputTokens: that.CNF.Ernie.MaxTokens,
+	}
+	system := []string{}
+	for _, m := range msgs {
+		switch m.Role {
+		case openai.ChatMessageRoleSystem:
+			if m.Content != "" {
+				system = append(system, m.Content)
+			}
+		case openai.ChatMessageRoleUser, openai.ChatMessageRoleAssistant:
+			if l := len(req.Messages); l > 0 && req.Messages[l-1].Role == m.Role {
+				req.Messages[l-1].Content += "\n\n" + m.Content
+			} else if l == 0 && m.Role == openai.ChatMessageRoleAssistant {
+				continue // the first message must be from the user.
+			} else {
+				req.Messages = append(req.Messages, Message{Role: m.Role, Content: m.Content})
+			}
+		}
+	}
+	req.System = strings.Join(system, "\n\n")
+	return req
+}
+
+// post sends req, an error in a json body is returned as an error.
+func (that *Ernie) post(req *Request, refresh bool) (*http.Response, error) {
+	token, err := that.AccessToken(refresh)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := backend.PostJSON(that.HttpClient, that.Endpoint()+"?access_token="+url.QueryEscape(token), nil, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, backend.ReadError(resp, nil)
+	}
+	if req.Stream && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	r := &Response{}
+	if err = json.NewDecoder(resp.Body).Decode(r); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	if r.ErrorCode != 0 {
+		return nil, lookupError(r.ErrorCode, r.ErrorMsg)
+	}
+	return nil, nil
+}
+
+// send posts req, the access token is requested again once if it is rejected.
+func (that *Ernie) send(req *Request) (resp *http.Response, err error) {
+	resp, err = that.post(req, false)
+	if err == ErrTokenInvalid || err == ErrTokenExpired {
+		resp, err = that.post(req, true)
+	}
+	return
+}
+
+func (that *Ernie) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error) {
+	that.Close()
+	resp, err := that.send(that.NewRequest(msgs))
+	if err != nil {
+		return "", err
+	}
+	if resp == nil {
+		return "", fmt.Errorf("no stream found")
+	}
+	that.resp = resp
+	that.events = backend.NewSSEReader(resp.Body)
+	return "", nil
+}
+
+// RecvMsg returns the text of the next event, io.EOF with the last one.
+func (that *Ernie) RecvMsg() (m string, err error) {
+	if that.events == nil {
+		return "", fmt.Errorf("no stream found")
+	}
+	for {
+		ev, err := that.events.Next()
+		if err != nil {
+			that.Close()
+			if err == io.EOF {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		r := &Response{}
+		if err = json.Unmarshal([]byte(ev.Data), r); err != nil {
+			that.Close()
+			return "", fmt.Errorf("decode event: %w", err)
+		}
+		if r.ErrorCode != 0 {
+			that.Close()
+			return "", lookupError(r.ErrorCode, r.ErrorMsg)
+		}
+		if r.NeedClearHistory {
+			that.Close()
+			return "", ErrNeedClearHistory
+		}
+		if r.IsEnd {
+			that.tokens += r.Usage.TotalTokens
+			that.Close()
+			return r.Result, io.EOF
+		}
+		if r.Result != "" {
+			return r.Result, nil
+		}
+	}
+}
+
+func (that *Ernie) Close() {
+	if that.resp != nil {
+		that.resp.Body.Close()
+	}
+	that.resp = nil
+	that.events = nil
+}
+
+// GetTokens returns the tokens reported by the api since the last call.
+func (that *Ernie) GetTokens() (tokens int64) {
+	tokens, that.tokens = that.tokens, 0
+	return
+}
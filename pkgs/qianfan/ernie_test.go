@@ -0,0 +1,194 @@
+package qianfan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// testServer stands in for Qianfan, it hands out numbered access tokens and accepts the last one.
+type testServer struct {
+	lock      sync.Mutex
+	issued    int   // access tokens handed out
+	expiresIn int64 // lifetime of new tokens in seconds
+	events    string
+	got       *Request
+}
+
+func (that *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	switch r.URL.Path {
+	case "/oauth/2.0/token":
+		q := r.URL.Query()
+		if q.Get("grant_type") != "client_credentials" || q.Get("client_secret") != "sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": "invalid_client", "error_description": "unknown client id"}`)
+			return
+		}
+		that.issued++
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": %d}`, that.issued, that.expiresIn)
+	case "/rpc/2.0/ai_custom/v1/wenxinworkshop/chat/completions":
+		if r.URL.Query().Get("access_token") != fmt.Sprintf("token-%d", that.issued) {
+			fmt.Fprint(w, `{"error_code": 110, "error_msg": "Access token invalid or no longer valid"}`)
+			return
+		}
+		if that.got != nil {
+			json.NewDecoder(r.Body).Decode(that.got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, that.events)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newTestErnie returns a client of srv, with an api key of its own as tokens are cached by api key.
+func newTestErnie(t *testing.T, srv *testServer) *Ernie {
+	t.Helper()
+	s := httptest.NewServer(srv)
+	t.Cleanup(s.Close)
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.Ernie.BaseUrl = s.URL + "/"
+	cnf.Ernie.ApiKey = "ak-" + t.Name()
+	cnf.Ernie.SecretKey = "sk-test"
+	return NewErnie(cnf)
+}
+
+func sse(data string) string {
+	return fmt.Sprintf("data: %s\n\n", data)
+}
+
+func recvAll(e *Ernie) (answer string, err error) {
+	for {
+		m, err := e.RecvMsg()
+		answer += m
+		if err != nil {
+			return answer, err
+		}
+	}
+}
+
+func TestErnieStream(t *testing.T) {
+	srv := &testServer{
+		expiresIn: 2592000,
+		events: sse(`{"result": "Hello", "is_end": false}`) +
+			sse(`{"result": "", "is_end": false}`) +
+			sse(`{"result": ", world", "is_end": true, "usage": {"prompt_tokens": 9, "total_tokens": 12}}`),
+		got: &Request{},
+	}
+	e := newTestErnie(t, srv)
+	msgs := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "Be brief."},
+		{Role: openai.ChatMessageRoleSystem, Content: "Answer in English."},
+		{Role: openai.ChatMessageRoleAssistant, Content: "dropped, the first message is from the user"},
+		{Role: openai.ChatMessageRoleUser, Content: "attached file"},
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "hello"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "how can I help?"},
+		{Role: openai.ChatMessageRoleUser, Content: "say hello"},
+	}
+	if _, err := e.SendMsg(msgs); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := recvAll(e)
+	if err != io.EOF {
+		t.Fatalf("stream ended with %v", err)
+	}
+	if answer != "Hello, world" {
+		t.Errorf("answer %q", answer)
+	}
+	if tokens := e.GetTokens(); tokens != 12 {
+		t.Errorf("tokens %d, want 12", tokens)
+	}
+
+	req := srv.got
+	if req.System != "Be brief.\n\nAnswer in English." || !req.Stream {
+		t.Errorf("request %+v", req)
+	}
+	want := []Message{
+		{Role: "user", Content: "attached file\n\nhi"},
+		{Role: "assistant", Content: "hello\n\nhow can I help?"},
+		{Role: "user", Content: "say hello"},
+	}
+	if len(req.Messages) != len(want) {
+		t.Fatalf("messages %+v", req.Messages)
+	}
+	for i := range want {
+		if req.Messages[i] != want[i] {
+			t.Errorf("message %d: %+v, want %+v", i, req.Messages[i], want[i])
+		}
+	}
+}
+
+func TestErnieAccessTokenRefresh(t *testing.T) {
+	srv := &testServer{expiresIn: 2592000, events: sse(`{"result": "hi", "is_end": true}`)}
+	e := newTestErnie(t, srv)
+	ask := func() {
+		t.Helper()
+		if _, err := e.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+			t.Fatal(err)
+		}
+		if answer, err := recvAll(e); answer != "hi" || err != io.EOF {
+			t.Fatalf("answer %q, err %v", answer, err)
+		}
+	}
+
+	// the token is cached, also for other clients with the same api key.
+	ask()
+	ask()
+	if _, err := NewErnie(e.CNF).AccessToken(false); err != nil || srv.issued != 1 {
+		t.Errorf("%d tokens requested, want 1: %v", srv.issued, err)
+	}
+
+	// a token rejected by the server is requested again once.
+	srv.issued++
+	ask()
+	if srv.issued != 3 {
+		t.Errorf("%d tokens requested after the token was rejected, want 3", srv.issued)
+	}
+
+	// a token expiring soon is requested again before it is used.
+	srv.expiresIn = 60
+	if _, err := e.AccessToken(true); err != nil {
+		t.Fatal(err)
+	}
+	ask()
+	if srv.issued != 5 {
+		t.Errorf("%d tokens requested after the token expired, want 5", srv.issued)
+	}
+
+	e.CNF.Ernie.SecretKey = "sk-wrong"
+	if _, err := e.AccessToken(true); err == nil {
+		t.Error("a refused token request succeeded")
+	}
+}
+
+func TestErnieStreamError(t *testing.T) {
+	srv := &testServer{
+		expiresIn: 2592000,
+		events:    sse(`{"result": "Hel", "is_end": false}`) + sse(`{"result": "", "need_clear_history": true, "is_end": false}`),
+	}
+	e := newTestErnie(t, srv)
+	if _, err := e.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if answer, err := recvAll(e); answer != "Hel" || err != ErrNeedClearHistory {
+		t.Errorf("answer %q, err %v", answer, err)
+	}
+
+	srv.events = sse(`{"result": "Hel", "is_end": false}`)
+	if _, err := e.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recvAll(e); err != io.ErrUnexpectedEOF {
+		t.Errorf("stream without is_end ended with %v", err)
+	}
+}
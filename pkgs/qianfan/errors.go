@@ -0,0 +1,123 @@
+package qianfan
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+)
+
+/*
+Error codes of Qianfan.
+
+1       服务内部错误
+2       服务暂不可用
+3       调用的api不存在
+4       集群超限额
+6       无权限访问该用户数据
+13      获取token失败
+14      IAM鉴权失败
+15      应用不存在或者创建失败
+17      每日请求量超限额
+18      QPS超限额
+19      请求总量超限额
+100     无效的access_token参数
+110     access token无效
+111     access token过期
+336000  服务内部错误
+336001  入参格式有误
+336002  入参不是json
+336003  参数校验不合法
+336006  messages数量必须为奇数
+336007  单条消息过长
+336100  服务繁忙, 请重试
+336501  rpm超限
+336502  tpm超限
+336503  用户rpm或tpm超限
+*/
+
+type ErnieAPIError struct {
+	Code int
+	Info string
+}
+
+func (that ErnieAPIError) Error() string {
+	return fmt.Sprintf("code: %d, info: %s", that.Code, that.Info)
+}
+
+func NewErnieError(code int, info string) (eae ErnieAPIError) {
+	return ErnieAPIError{Code: code, Info: info}
+}
+
+var (
+	ErrUnknown             = NewErnieError(1, "unknown error")
+	ErrServiceUnavailable  = NewErnieError(2, "service temporarily unavailable")
+	ErrUnsupportedMethod   = NewErnieError(3, "unsupported api method")
+	ErrClusterLimit        = NewErnieError(4, "cluster request limit reached")
+	ErrNoPermission        = NewErnieError(6, "no permission to access data")
+	ErrGetTokenFailed      = NewErnieError(13, "get service token failed")
+	ErrIAMFailed           = NewErnieError(14, "IAM certification failed")
+	ErrAppNotExist         = NewErnieError(15, "app not exists or create failed")
+	ErrDailyLimit          = NewErnieError(17, "daily request limit reached")
+	ErrQPSLimit            = NewErnieError(18, "QPS limit reached")
+	ErrTotalLimit          = NewErnieError(19, "total request limit reached")
+	ErrInvalidToken        = NewErnieError(100, "invalid access token parameter")
+	ErrTokenInvalid        = NewErnieError(110, "access token invalid")
+	ErrTokenExpired        = NewErnieError(111, "access token expired")
+	ErrInternal            = NewErnieError(336000, "internal error")
+	ErrInvalidArgument     = NewErnieError(336001, "invalid argument")
+	ErrInvalidJSON         = NewErnieError(336002, "invalid json")
+	ErrInvalidParams       = NewErnieError(336003, "invalid parameters")
+	ErrOddMessages         = NewErnieError(336006, "the number of messages must be odd")
+	ErrMessageTooLong      = NewErnieError(336007, "message too long")
+	ErrServerBusy          = NewErnieError(336100, "server is busy, try again")
+	ErrRPMLimit            = NewErnieError(336501, "RPM limit reached")
+	ErrTPMLimit            = NewErnieError(336502, "TPM limit reached")
+	ErrUserLimit           = NewErnieError(336503, "RPM or TPM limit of the user reached")
+	ErrNeedClearHistory    = fmt.Errorf("answer stopped for sensitive content, clear the context")
+	ErrTokenRequestRefused = fmt.Errorf("access token refused")
+)
+
+var ErnieErrorMap map[int]error = map[int]error{
+	1:      ErrUnknown,
+	2:      ErrServiceUnavailable,
+	3:      ErrUnsupportedMethod,
+	4:      ErrClusterLimit,
+	6:      ErrNoPermission,
+	13:     ErrGetTokenFailed,
+	14:     ErrIAMFailed,
+	15:     ErrAppNotExist,
+	17:     ErrDailyLimit,
+	18:     ErrQPSLimit,
+	19:     ErrTotalLimit,
+	100:    ErrInvalidToken,
+	110:    ErrTokenInvalid,
+	111:    ErrTokenExpired,
+	336000: ErrInternal,
+	336001: ErrInvalidArgument,
+	336002: ErrInvalidJSON,
+	336003: ErrInvalidParams,
+	336006: ErrOddMessages,
+	336007: ErrMessageTooLong,
+	336100: ErrServerBusy,
+	336501: ErrRPMLimit,
+	336502: ErrTPMLimit,
+	336503: ErrUserLimit,
+}
+
+// lookupError returns the error of code, with the message from the server for unknown codes.
+func lookupError(code int, info string) error {
+	if err, ok := ErnieErrorMap[code]; ok {
+		return err
+	}
+	return NewErnieError(code, info)
+}
+
+// Retryable tells if err is a rate limit or a busy server worth another try.
+func Retryable(err error) (bool, time.Duration) {
+	switch err {
+	case ErrServiceUnavailable, ErrClusterLimit, ErrQPSLimit, ErrServerBusy, ErrRPMLimit, ErrTPMLimit:
+		return true, 0
+	}
+	return backend.Retryable(err)
+}
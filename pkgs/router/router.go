@@ -0,0 +1,268 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Failover across backends.
+
+Every round tries the providers in order, until one of them answers.
+Rate limits and server errors are tried again in the next round, after a backoff doubled
+every round, or the Retry-After of the server when it is longer.
+Other errors, like a wrong api key, drop the provider until the next question.
+
+Providers of a route share the settings but not the api key, they take turns:
+each question starts from the next key.
+
+An answer failing in the middle of the stream is not sent again.
+*/
+const (
+	DefaultAttempts   int     = 3
+	DefaultBackoff    float64 = 1
+	DefaultMaxBackoff float64 = 30
+)
+
+type Bot interface {
+	SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error)
+	RecvMsg() (m string, err error)
+	Close()
+	GetTokens() int64
+}
+
+type Provider struct {
+	Name  string // like ChatGPT, Claude@work or Qwen#2.
+	Model string
+	Bot   Bot
+	// tells if err is worth another try, and the wait asked by the server.
+	Retry func(err error) (bool, time.Duration)
+}
+
+func (that *Provider) retryable(err error) (bool, time.Duration) {
+	if that.Retry == nil {
+		return false, 0
+	}
+	return that.Retry(err)
+}
+
+type Router struct {
+	Routes     [][]*Provider // providers of each route, taking turns.
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Sleep      func(d time.Duration)
+	Err        error // invalid routes, returned by SendMsg.
+	turns      []int // next provider of each route
+	lock       sync.Mutex
+	active     *Provider // read by the UI while SendMsg runs in the background
+}
+
+func NewRouter(cnf *config.RouterConf) (r *Router) {
+	r = &Router{
+		Attempts:   cnf.Attempts,
+		Backoff:    seconds(cnf.Backoff, DefaultBackoff),
+		MaxBackoff: seconds(cnf.MaxBackoff, DefaultMaxBackoff),
+		Sleep:      time.Sleep,
+	}
+	if r.Attempts <= 0 {
+		r.Attempts = DefaultAttempts
+	}
+	return
+}
+
+func seconds(s, def float64) time.Duration {
+	if s <= 0 {
+		s = def
+	}
+	return time.Duration(s * float64(time.Second))
+}
+
+// order returns the providers for the next question, the routes take the next turn.
+func (that *Router) order() (providers []*Provider) {
+	for len(that.turns) < len(that.Routes) {
+		that.turns = append(that.turns, 0)
+	}
+	for i, route := range that.Routes {
+		if len(route) == 0 {
+			continue
+		}
+		start := that.turns[i] % len(route)
+		that.turns[i]++
+		providers = append(providers, route[start:]...)
+		providers = append(providers, route[:start]...)
+	}
+	return
+}
+
+// backoff returns the wait before the round, doubled every round.
+func (that *Router) backoff(round int) time.Duration {
+	d := that.Backoff
+	for i := 1; i < round && d < that.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > that.MaxBackoff {
+		d = that.MaxBackoff
+	}
+	return d
+}
+
+func (that *Router) getActive() *Provider {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	return that.active
+}
+
+func (that *Router) setActive(p *Provider) {
+	that.lock.Lock()
+	that.active = p
+	that.lock.Unlock()
+}
+
+// send sends msgs to a provider, and reads the first message if empty,
+// as some backends, like Spark, report busy servers with it.
+func (that *Router) send(p *Provider, msgs []openai.ChatCompletionMessage) (m string, err error) {
+	if m, err = p.Bot.SendMsg(msgs); err == nil && m == "" {
+		m, err = p.Bot.RecvMsg()
+	}
+	if err != nil && err != io.EOF {
+		p.Bot.Close()
+	}
+	return
+}
+
+func (that *Router) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error) {
+	that.Close()
+	that.setActive(nil)
+	if that.Err != nil {
+		return "", that.Err
+	}
+	providers := that.order()
+	if len(providers) == 0 {
+		return "", fmt.Errorf("no route found, add router.routes to the config")
+	}
+	dropped := map[*Provider]bool{}
+	var wait time.Duration
+	for round := 0; round < that.Attempts; round++ {
+		if round > 0 {
+			if wait > that.MaxBackoff {
+				return "", fmt.Errorf("%w, retry after %s", err, wait.Round(time.Second))
+			}
+			if d := that.backoff(round); d > wait {
+				wait = d
+			}
+			that.Sleep(wait)
+		}
+		tried := false
+		wait = -1
+		for _, p := range providers {
+			if dropped[p] {
+				continue
+			}
+			tried = true
+			m, pErr := that.send(p, msgs)
+			if pErr == nil || pErr == io.EOF {
+				that.setActive(p)
+				return m, pErr
+			}
+			err = fmt.Errorf("%s: %w", p.Name, pErr)
+			retry, after := p.retryable(pErr)
+			if !retry {
+				dropped[p] = true
+			} else if wait < 0 || after < wait {
+				wait = after // the soonest provider to take requests again.
+			}
+		}
+		if !tried || wait < 0 {
+			break
+		}
+	}
+	return "", err
+}
+
+func (that *Router) RecvMsg() (m string, err error) {
+	active := that.getActive()
+	if active == nil {
+		return "", fmt.Errorf("no stream found")
+	}
+	return active.Bot.RecvMsg()
+}
+
+func (that *Router) Close() {
+	if active := that.getActive(); active != nil {
+		active.Bot.Close()
+	}
+}
+
+func (that *Router) GetTokens() int64 {
+	active := that.getActive()
+	if active == nil {
+		return 0
+	}
+	return active.Bot.GetTokens()
+}
+
+// Provider returns the name of the provider answering the last question.
+func (that *Router) Provider() string {
+	active := that.getActive()
+	if active == nil {
+		return ""
+	}
+	return active.Name
+}
+
+func (that *Router) providers() (providers []*Provider) {
+	for _, route := range that.Routes {
+		providers = append(providers, route...)
+	}
+	return
+}
+
+// Model returns the model of the provider answering the last question.
+func (that *Router) Model() string {
+	active := that.getActive()
+	if active == nil {
+		return ""
+	}
+	return active.Model
+}
+
+/*
+Connection test.
+*/
+
+// Endpoint returns the names of the providers.
+func (that *Router) Endpoint() string {
+	names := []string{}
+	for _, p := range that.providers() {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Ping tests every provider supporting the connection test.
+func (that *Router) Ping(ctx context.Context) error {
+	if that.Err != nil {
+		return that.Err
+	}
+	errList := []error{}
+	for _, p := range that.providers() {
+		if pinger, ok := p.Bot.(interface{ Ping(context.Context) error }); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				errList = append(errList, fmt.Errorf("%s: %w", p.Name, err))
+			}
+		}
+	}
+	if len(that.Routes) == 0 {
+		errList = append(errList, fmt.Errorf("no route found, add router.routes to the config"))
+	}
+	return errors.Join(errList...)
+}
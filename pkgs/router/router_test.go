@@ -0,0 +1,78 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+type fakeBot struct {
+	errs []error // returned by SendMsg in turn, nil answers
+}
+
+func (that *fakeBot) SendMsg(msgs []openai.ChatCompletionMessage) (string, error) {
+	if len(that.errs) > 0 {
+		err := that.errs[0]
+		that.errs = that.errs[1:]
+		if err != nil {
+			return "", err
+		}
+	}
+	return "answer", nil
+}
+
+func (that *fakeBot) RecvMsg() (string, error) { return "", io.EOF }
+func (that *fakeBot) Close()                   {}
+func (that *fakeBot) GetTokens() int64         { return 0 }
+
+var errBusy = fmt.Errorf("busy")
+
+func retryBusy(err error) (bool, time.Duration) {
+	return err == errBusy, 0
+}
+
+func newTestRouter(routes ...[]*Provider) *Router {
+	r := NewRouter(&config.RouterConf{})
+	r.Routes = routes
+	r.Sleep = func(time.Duration) {}
+	return r
+}
+
+func TestFailover(t *testing.T) {
+	a := &Provider{Name: "A", Bot: &fakeBot{errs: []error{errBusy}}, Retry: retryBusy}
+	b := &Provider{Name: "B", Model: "m", Bot: &fakeBot{errs: []error{fmt.Errorf("invalid api key")}}, Retry: retryBusy}
+	r := newTestRouter([]*Provider{a}, []*Provider{b})
+	m, err := r.SendMsg(nil)
+	if m != "answer" || err != nil {
+		t.Fatalf("answer %q, err %v", m, err)
+	}
+	if r.Provider() != "A" {
+		t.Errorf("answered by %s, want A in the second round", r.Provider())
+	}
+}
+
+func TestProviderWhileSending(t *testing.T) {
+	p := &Provider{Name: "A", Model: "m", Bot: &fakeBot{}}
+	r := newTestRouter([]*Provider{p})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			r.SendMsg(nil)
+		}
+	}()
+	// the footer reads the provider on every render.
+	for i := 0; i < 100; i++ {
+		_ = r.Provider() + r.Model()
+	}
+	wg.Wait()
+	if r.Provider() != "A" || r.Model() != "m" {
+		t.Errorf("provider %s, model %s", r.Provider(), r.Model())
+	}
+}
@@ -0,0 +1,203 @@
+package theme
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/muesli/termenv"
+)
+
+/*
+Color themes of the TUI.
+
+Custom themes are read from "themes/*.json" in the work dir, the file name is the theme name.
+Missing or invalid colors are taken from the "base" theme, dark by default.
+Colors are ANSI numbers from 0 to 255 or hex colors like "#FF0" and "#FFFF00":
+
+	{
+	    "base": "light",
+	    "tab_active": "#005F87",
+	    "glamour": "/path/to/glamour_style.json"
+	}
+*/
+const (
+	ThemeDirName string = "themes"
+	Auto         string = "auto" // dark or light, by terminal background
+	Dark         string = "dark"
+	Light        string = "light"
+	HighContrast string = "high-contrast"
+	Solarized    string = "solarized"
+)
+
+type Theme struct {
+	Name        string `json:"-"`
+	Base        string `json:"base,omitempty"`
+	TabActive   string `json:"tab_active"`
+	TabInactive string `json:"tab_inactive"`
+	Sender      string `json:"sender"`
+	Bot         string `json:"bot"`
+	Error       string `json:"error"`
+	Footer      string `json:"footer"`
+	Hint        string `json:"hint"`
+	Help        string `json:"help"`
+	Placeholder string `json:"placeholder"`
+	InputText   string `json:"input_text"`
+	Selected    string `json:"selected"`
+	Unselected  string `json:"unselected"`
+	DiffAdd     string `json:"diff_add"`
+	DiffDel     string `json:"diff_del"`
+	Glamour     string `json:"glamour"` // glamour style name or path to a glamour json style
+}
+
+var hexColorRegexp = regexp.MustCompile(`^#(?:[0-9A-Fa-f]{3}|[0-9A-Fa-f]{6})$`)
+
+// IsColor reports whether c is empty, an ANSI color number or a hex color.
+func IsColor(c string) bool {
+	if c == "" || hexColorRegexp.MatchString(c) {
+		return true
+	}
+	n, err := strconv.Atoi(c)
+	return err == nil && n >= 0 && n <= 255
+}
+
+func (that *Theme) colors() []*string {
+	return []*string{
+		&that.TabActive, &that.TabInactive, &that.Sender, &that.Bot, &that.Error, &that.Footer, &that.Hint,
+		&that.Help, &that.Placeholder, &that.InputText, &that.Selected, &that.Unselected, &that.DiffAdd, &that.DiffDel,
+	}
+}
+
+var Builtin map[string]Theme = map[string]Theme{
+	Dark: {
+		TabActive:   "#FFFF00",
+		TabInactive: "#D2691E",
+		Sender:      "5",
+		Bot:         "6",
+		Error:       "#FF0000",
+		Footer:      "#00FFFF",
+		Hint:        "#808080",
+		Help:        "#FFA500",
+		Placeholder: "240",
+		InputText:   "",
+		Selected:    "#FFFF00",
+		Unselected:  "#D2691E",
+		DiffAdd:     "#00FF00",
+		DiffDel:     "#FF0000",
+		Glamour:     "dark",
+	},
+	Light: {
+		TabActive:   "#005FAF",
+		TabInactive: "#8A8A8A",
+		Sender:      "#AF005F",
+		Bot:         "#008787",
+		Error:       "#D70000",
+		Footer:      "#005F87",
+		Hint:        "#6C6C6C",
+		Help:        "#AF5F00",
+		Placeholder: "#A8A8A8",
+		InputText:   "",
+		Selected:    "#005FAF",
+		Unselected:  "#8A8A8A",
+		DiffAdd:     "#008700",
+		DiffDel:     "#D70000",
+		Glamour:     "light",
+	},
+	HighContrast: {
+		TabActive:   "#FFFFFF",
+		TabInactive: "#FFFF00",
+		Sender:      "#FF00FF",
+		Bot:         "#00FFFF",
+		Error:       "#FF0000",
+		Footer:      "#FFFFFF",
+		Hint:        "#FFFF00",
+		Help:        "#FFFFFF",
+		Placeholder: "#C0C0C0",
+		InputText:   "#FFFFFF",
+		Selected:    "#FFFFFF",
+		Unselected:  "#FFFF00",
+		DiffAdd:     "#00FF00",
+		DiffDel:     "#FF0000",
+		Glamour:     "dark",
+	},
+	Solarized: {
+		TabActive:   "#B58900",
+		TabInactive: "#586E75",
+		Sender:      "#D33682",
+		Bot:         "#2AA198",
+		Error:       "#DC322F",
+		Footer:      "#268BD2",
+		Hint:        "#657B83",
+		Help:        "#CB4B16",
+		Placeholder: "#586E75",
+		InputText:   "#93A1A1",
+		Selected:    "#B58900",
+		Unselected:  "#586E75",
+		DiffAdd:     "#859900",
+		DiffDel:     "#DC322F",
+		Glamour:     "dark",
+	},
+}
+
+// Load returns builtin themes and custom themes in workDir.
+func Load(workDir string) map[string]Theme {
+	themes := map[string]Theme{}
+	for name, t := range Builtin {
+		t.Name = name
+		themes[name] = t
+	}
+	files, _ := filepath.Glob(filepath.Join(workDir, ThemeDirName, "*.json"))
+	for _, fPath := range files {
+		content, err := os.ReadFile(fPath)
+		if err != nil {
+			continue
+		}
+		base := struct {
+			Base string `json:"base"`
+		}{}
+		json.Unmarshal(content, &base)
+		b, ok := Builtin[base.Base]
+		if !ok {
+			b = Builtin[Dark]
+		}
+		// colors in the file override the base theme.
+		t := b
+		if err := json.Unmarshal(content, &t); err != nil {
+			continue
+		}
+		baseColors := b.colors()
+		for i, c := range t.colors() {
+			if !IsColor(*c) {
+				*c = *baseColors[i]
+			}
+		}
+		t.Name = strings.TrimSuffix(filepath.Base(fPath), ".json")
+		themes[t.Name] = t
+	}
+	return themes
+}
+
+// Names returns all theme names, "auto" first.
+func Names(workDir string) (names []string) {
+	for name := range Load(workDir) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{Auto}, names...)
+}
+
+// Get returns the theme by name, "auto" or an unknown name picks dark or light by terminal background.
+func Get(name, workDir string) Theme {
+	themes := Load(workDir)
+	if t, ok := themes[name]; ok {
+		return t
+	}
+	if termenv.HasDarkBackground() {
+		return themes[Dark]
+	}
+	return themes[Light]
+}
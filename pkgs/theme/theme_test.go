@@ -0,0 +1,106 @@
+package theme
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTheme(t *testing.T, workDir, name, content string) {
+	t.Helper()
+	dir := filepath.Join(workDir, ThemeDirName)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsColor(t *testing.T) {
+	for c, want := range map[string]bool{
+		"":        true,
+		"0":       true,
+		"255":     true,
+		"#FF0":    true,
+		"#005f87": true,
+		"256":     false,
+		"-1":      false,
+		"#GG0000": false,
+		"#12345":  false,
+		"red":     false,
+	} {
+		if got := IsColor(c); got != want {
+			t.Errorf("IsColor(%q) = %v, want %v", c, got, want)
+		}
+	}
+}
+
+func TestLoadCustomThemes(t *testing.T) {
+	workDir := t.TempDir()
+	writeTheme(t, workDir, "ocean", `{"base": "light", "tab_active": "#005F87", "sender": "33", "glamour": "/tmp/ocean.json"}`)
+	writeTheme(t, workDir, "plain", `{"bot": "#00FF00"}`)
+	writeTheme(t, workDir, "typo", `{"base": "solarized", "bot": "teal", "error": "#12345", "hint": "300", "footer": "#FFF"}`)
+	writeTheme(t, workDir, "broken", `{"tab_active": `)
+	writeTheme(t, workDir, Dark, `{"tab_active": "#123456"}`)
+
+	themes := Load(workDir)
+
+	ocean := themes["ocean"]
+	if ocean.Name != "ocean" || ocean.TabActive != "#005F87" || ocean.Sender != "33" || ocean.Glamour != "/tmp/ocean.json" {
+		t.Errorf("colors of the file: %+v", ocean)
+	}
+	if ocean.Bot != Builtin[Light].Bot || ocean.DiffAdd != Builtin[Light].DiffAdd {
+		t.Errorf("missing colors are not taken from the light base: %+v", ocean)
+	}
+
+	plain := themes["plain"]
+	if plain.Bot != "#00FF00" || plain.TabActive != Builtin[Dark].TabActive || plain.Glamour != Builtin[Dark].Glamour {
+		t.Errorf("missing colors are not taken from dark: %+v", plain)
+	}
+
+	typo := themes["typo"]
+	solarized := Builtin[Solarized]
+	if typo.Bot != solarized.Bot || typo.Error != solarized.Error || typo.Hint != solarized.Hint {
+		t.Errorf("invalid colors are not taken from the base: %+v", typo)
+	}
+	if typo.Footer != "#FFF" {
+		t.Errorf("valid color of a file with invalid ones: %q", typo.Footer)
+	}
+
+	if _, ok := themes["broken"]; ok {
+		t.Error("an invalid file is loaded")
+	}
+	if themes[Dark].TabActive != "#123456" {
+		t.Errorf("a file named like a builtin theme overrides it: %+v", themes[Dark])
+	}
+	if Builtin[Dark].TabActive == "#123456" {
+		t.Error("the builtin palette is changed by a file")
+	}
+	for _, name := range []string{Light, HighContrast, Solarized} {
+		if themes[name].Name != name || themes[name].TabActive != Builtin[name].TabActive {
+			t.Errorf("builtin theme %s: %+v", name, themes[name])
+		}
+	}
+}
+
+func TestGetFallback(t *testing.T) {
+	workDir := t.TempDir()
+	writeTheme(t, workDir, "ocean", `{"tab_active": "#005F87"}`)
+
+	if got := Get("ocean", workDir); got.Name != "ocean" {
+		t.Errorf("Get(ocean) = %+v", got)
+	}
+	if got := Get(Solarized, workDir); got.Name != Solarized {
+		t.Errorf("Get(solarized) = %+v", got)
+	}
+	for _, name := range []string{Auto, "missing", ""} {
+		if got := Get(name, workDir); got.Name != Dark && got.Name != Light {
+			t.Errorf("Get(%q) = %q, want dark or light", name, got.Name)
+		}
+	}
+	names := Names(workDir)
+	if len(names) != len(Builtin)+2 || names[0] != Auto {
+		t.Errorf("names %v", names)
+	}
+}
@@ -1,10 +1,13 @@
 package tui
 
 import (
+	"os"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
 	"github.com/gvcgo/gogpt/pkgs/config"
 	"github.com/gvcgo/gogpt/pkgs/gpt"
+	"github.com/gvcgo/gogpt/pkgs/theme"
 )
 
 var (
@@ -18,42 +21,95 @@ type ExtraModel interface {
 }
 
 type GPTUI struct {
-	Program *tea.Program
-	GVM     *GPTViewModel
-	CNF     *config.Config
-	Prompt  *gpt.GPTPrompt
+	Program  *tea.Program
+	GVM      *GPTViewModel
+	CNF      *config.Config
+	Prompt   *gpt.GPTPrompt
+	Keys     *KeyMap
+	Commands *CommandRegistry
 }
 
 func NewGPTUI(cnf *config.Config) (g *GPTUI) {
+	ApplyTheme(theme.Get(cnf.UI.Theme, cnf.GetWorkDir()))
+	keys, err := NewKeyMap(cnf)
+	if err != nil {
+		gprint.PrintError("%+v", err)
+		os.Exit(1)
+	}
 	g = &GPTUI{
-		GVM:    NewGPTViewModel(),
+		GVM:    NewGPTViewModel(cnf, keys),
 		CNF:    cnf,
 		Prompt: gpt.NewGPTPrompt(cnf),
+		Keys:   keys,
 	}
 	g.AddConversationUI()
 	g.AddConfUI()
+	g.AddModelsUI()
 	g.AddHelpInfo()
 	return
 }
 
 func (that *GPTUI) AddConversationUI() {
-	uconv := NewConversationModel(that.CNF)
+	uconv := NewConversationModel(that.CNF, that.Keys)
+	uconv.Prompt = that.Prompt
+	that.Commands = uconv.Commands
 	that.GVM.AddTab("Conversation", uconv)
 }
 
+/*
+Configuration tab, rebuilt when the config changes.
+*/
+type ConfTab struct {
+	ExtraModel
+	CNF    *config.Config
+	Prompt *gpt.GPTPrompt
+	build  func(cnf *config.Config) ExtraModel
+}
+
+// confSubmitted is sent by the submit command of the form, the config is saved in Update.
+type confSubmitted struct{}
+
+func (that *ConfTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case ConfigChanged:
+		if msg.CNF != nil {
+			that.CNF = msg.CNF
+		}
+		that.ExtraModel = that.build(that.CNF)
+		return that, that.ExtraModel.Init()
+	case confSubmitted:
+		if err := that.submit(); err != nil {
+			_, cmd := that.ExtraModel.Update(err)
+			return that, cmd
+		}
+		return that, func() tea.Msg { return returnFirst }
+	}
+	_, cmd := that.ExtraModel.Update(msg)
+	return that, cmd
+}
+
+// submit saves the values of the form.
+func (that *ConfTab) submit() error {
+	vals := that.ExtraModel.Values()
+	vals[gptPrompt] = that.Prompt.GetPromptByTile(vals[gptPrompt])
+	return SetConfig(that.CNF, vals)
+}
+
 func (that *GPTUI) AddConfUI() {
-	uconf := GetGoGPTConfigModel(that.Prompt, that.CNF)
-	uconf.SetSubmitCmd(func() tea.Msg {
-		vals := uconf.Values()
-		vals[gptPrompt] = that.Prompt.GetPromptByTile(vals[gptPrompt])
-		SetConfig(that.CNF, vals)
-		return returnFirst
-	})
-	that.GVM.AddTab("Configuration", uconf)
+	build := func(cnf *config.Config) ExtraModel {
+		uconf := GetGoGPTConfigModel(that.Prompt, cnf, that.Keys)
+		uconf.SetSubmitCmd(func() tea.Msg { return confSubmitted{} })
+		return uconf
+	}
+	that.GVM.AddTab("Configuration", &ConfTab{ExtraModel: build(that.CNF), CNF: that.CNF, Prompt: that.Prompt, build: build})
+}
+
+func (that *GPTUI) AddModelsUI() {
+	that.GVM.AddTab("Models", NewModelsModel(that.CNF))
 }
 
 func (that *GPTUI) AddHelpInfo() {
-	helpInfo := NewHelpModel()
+	helpInfo := NewHelpModel(that.Keys, that.Commands)
 	that.GVM.AddTab("HelpInfo", helpInfo)
 }
 
@@ -61,6 +117,11 @@ func (that *GPTUI) Run() {
 	if that.Program == nil {
 		that.Program = tea.NewProgram(that.GVM, tea.WithAltScreen())
 	}
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := that.CNF.Watch(func() { that.Program.Send(ConfigFileChanged{}) }, stop); err != nil {
+		gprint.PrintWarning("config changes need a restart, watch failed: %+v", err)
+	}
 	if _, err := that.Program.Run(); err != nil {
 		gprint.PrintError("Run bubbletea failed: %+v", err)
 	}
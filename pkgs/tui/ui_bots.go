@@ -0,0 +1,279 @@
+package tui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/anthropic"
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	cvsation "github.com/gvcgo/gogpt/pkgs/conversation"
+	"github.com/gvcgo/gogpt/pkgs/dashscope"
+	"github.com/gvcgo/gogpt/pkgs/gemini"
+	"github.com/gvcgo/gogpt/pkgs/gpt"
+	"github.com/gvcgo/gogpt/pkgs/iflytek"
+	"github.com/gvcgo/gogpt/pkgs/ollama"
+	"github.com/gvcgo/gogpt/pkgs/qianfan"
+	"github.com/gvcgo/gogpt/pkgs/router"
+	"github.com/gvcgo/gogpt/pkgs/zhipu"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type Bot interface {
+	SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error)
+	RecvMsg() (m string, err error)
+	Close()
+	GetTokens() int64
+}
+
+// Pinger is implemented by bots supporting the connection test.
+type Pinger interface {
+	Endpoint() string
+	Ping(ctx context.Context) error
+}
+
+// Proxied is implemented by bots with a proxy setting.
+type Proxied interface {
+	ProxyUrl() string
+}
+
+/*
+Backends of the conversation, in the order of switching.
+*/
+type BotBackend struct {
+	Name string
+	New  func(cnf *config.Config) Bot
+	// settings used by a client, the client is created again when they change.
+	// The value must be comparable.
+	Conf func(cnf *config.Config) interface{}
+	// temperature range and setter for /temp, nil SetTemp for none.
+	TempRange config.Range
+	SetTemp   func(cnf *config.Config, t float64)
+	// tells if an error is worth another try by the router.
+	Retry func(err error) (bool, time.Duration)
+	// sets the api key of a router route, nil if keys can not take turns.
+	SetKey func(cnf *config.Config, key string)
+	// sets the model of a compared bot, nil for none.
+	SetModel func(cnf *config.Config, model string)
+	// model of a client, recorded with its answers, nil if unknown.
+	Model func(cnf *config.Config) string
+	// secret settings of a client, nil for none.
+	Secrets func(cnf *config.Config) []*string
+}
+
+// ModelOf returns the model of the backend with cnf, empty if unknown.
+func (that *BotBackend) ModelOf(cnf *config.Config) string {
+	if that.Model == nil {
+		return ""
+	}
+	return that.Model(cnf)
+}
+
+// ResolveSecrets replaces secret references of the backend in cnf with their values,
+// clients answering in the background then never resolve them.
+func (that *BotBackend) ResolveSecrets(cnf *config.Config) error {
+	if that.Secrets == nil {
+		return nil
+	}
+	for _, p := range that.Secrets(cnf) {
+		v, err := cnf.Secret(*p)
+		if err != nil {
+			return err
+		}
+		*p = v
+	}
+	return nil
+}
+
+var BotBackends = []*BotBackend{
+	{
+		Name:      cvsation.BotGPT,
+		New:       func(cnf *config.Config) Bot { return gpt.NewGPT(cnf) },
+		Conf:      func(cnf *config.Config) interface{} { return *cnf.OpenAI },
+		TempRange: config.OpenAITemperatureRange,
+		SetTemp:   func(cnf *config.Config, t float64) { cnf.OpenAI.Temperature = float32(t) },
+		Retry:     backend.Retryable,
+		SetKey:    func(cnf *config.Config, key string) { cnf.OpenAI.ApiKey = key },
+		SetModel:  func(cnf *config.Config, model string) { cnf.OpenAI.Model = model },
+		Model:     func(cnf *config.Config) string { return cnf.OpenAI.Model },
+		Secrets:   func(cnf *config.Config) []*string { return []*string{&cnf.OpenAI.ApiKey, &cnf.OpenAI.ClientSecret} },
+	},
+	{
+		Name:      cvsation.BotSpark,
+		New:       func(cnf *config.Config) Bot { return iflytek.NewSpark(cnf) },
+		Conf:      func(cnf *config.Config) interface{} { return *cnf.Spark },
+		TempRange: config.SparkTemperatureRange,
+		SetTemp:   func(cnf *config.Config, t float64) { cnf.Spark.Temperature = t },
+		Retry:     iflytek.Retryable,
+		Model:     func(cnf *config.Config) string { return string(cnf.Spark.APIVersion) },
+		Secrets:   func(cnf *config.Config) []*string { return []*string{&cnf.Spark.APPKey, &cnf.Spark.APPSecrete} },
+	},
+	{
+		Name:      cvsation.BotClaude,
+		New:       func(cnf *config.Config) Bot { return anthropic.NewClaude(cnf) },
+		Conf:      func(cnf *config.Config) interface{} { return *cnf.Anthropic },
+		TempRange: config.ClaudeTemperatureRange,
+		SetTemp:   func(cnf *config.Config, t float64) { cnf.Anthropic.Temperature = t },
+		Retry:     anthropic.Retryable,
+		SetKey:    func(cnf *config.Config, key string) { cnf.Anthropic.ApiKey = key },
+		SetModel:  func(cnf *config.Config, model string) { cnf.Anthropic.Model = model },
+		Model:     func(cnf *config.Config) string { return cnf.Anthropic.Model },
+		Secrets:   func(cnf *config.Config) []*string { return []*string{&cnf.Anthropic.ApiKey} },
+	},
+	{
+		Name:      cvsation.BotGemini,
+		New:       func(cnf *config.Config) Bot { return gemini.NewGemini(cnf) },
+		Conf:      func(cnf *config.Config) interface{} { return *cnf.Gemini },
+		TempRange: config.GeminiTemperatureRange,
+		SetTemp:   func(cnf *config.Config, t float64) { cnf.Gemini.Temperature = t },
+		Retry:     backend.Retryable,
+		SetKey:    func(cnf *config.Config, key string) { cnf.Gemini.ApiKey = key },
+		SetModel:  func(cnf *config.Config, model string) { cnf.Gemini.Model = model },
+		Model:     func(cnf *config.Config) string { return cnf.Gemini.Model },
+		Secrets:   func(cnf *config.Config) []*string { return []*string{&cnf.Gemini.ApiKey} },
+	},
+	{
+		Name: cvsation.BotOllama,
+		New:  func(cnf *config.Config) Bot { return ollama.NewOllama(cnf) },
+		Conf: func(cnf *config.Config) interface{} {
+			// options is a map, compare the json instead.
+			content, _ := json.Marshal(cnf.Ollama)
+			return string(content)
+		},
+		TempRange: config.OllamaTemperatureRange,
+		SetTemp:   func(cnf *config.Config, t float64) { cnf.Ollama.Temperature = t },
+		Retry:     backend.Retryable,
+		SetModel:  func(cnf *config.Config, model string) { cnf.Ollama.Model = model },
+		Model:     func(cnf *config.Config) string { return cnf.Ollama.Model },
+	},
+	{
+		Name:      cvsation.BotQwen,
+		New:       func(cnf *config.Config) Bot { return dashscope.NewQwen(cnf) },
+		Conf:      func(cnf *config.Config) interface{} { return *cnf.Qwen },
+		TempRange: config.QwenTemperatureRange,
+		SetTemp:   func(cnf *config.Config, t float64) { cnf.Qwen.Temperature = t },
+		Retry:     dashscope.Retryable,
+		SetKey:    func(cnf *config.Config, key string) { cnf.Qwen.ApiKey = key },
+		SetModel:  func(cnf *config.Config, model string) { cnf.Qwen.Model = model },
+		Model:     func(cnf *config.Config) string { return cnf.Qwen.Model },
+		Secrets:   func(cnf *config.Config) []*string { return []*string{&cnf.Qwen.ApiKey} },
+	},
+	{
+		Name:      cvsation.BotErnie,
+		New:       func(cnf *config.Config) Bot { return qianfan.NewErnie(cnf) },
+		Conf:      func(cnf *config.Config) interface{} { return *cnf.Ernie },
+		TempRange: config.ErnieTemperatureRange,
+		SetTemp:   func(cnf *config.Config, t float64) { cnf.Ernie.Temperature = t },
+		Retry:     qianfan.Retryable,
+		SetModel:  func(cnf *config.Config, model string) { cnf.Ernie.Model = model },
+		Model:     func(cnf *config.Config) string { return cnf.Ernie.Model },
+		Secrets:   func(cnf *config.Config) []*string { return []*string{&cnf.Ernie.ApiKey, &cnf.Ernie.SecretKey} },
+	},
+	{
+		Name:      cvsation.BotGLM,
+		New:       func(cnf *config.Config) Bot { return zhipu.NewGLM(cnf) },
+		Conf:      func(cnf *config.Config) interface{} { return *cnf.GLM },
+		TempRange: config.GLMTemperatureRange,
+		SetTemp:   func(cnf *config.Config, t float64) { cnf.GLM.Temperature = t },
+		Retry:     zhipu.Retryable,
+		SetKey:    func(cnf *config.Config, key string) { cnf.GLM.ApiKey = key },
+		SetModel:  func(cnf *config.Config, model string) { cnf.GLM.Model = model },
+		Model:     func(cnf *config.Config) string { return cnf.GLM.Model },
+		Secrets:   func(cnf *config.Config) []*string { return []*string{&cnf.GLM.ApiKey} },
+	},
+}
+
+// the router is the last backend, it creates clients of the others.
+func init() {
+	BotBackends = append(BotBackends, &BotBackend{
+		Name: cvsation.BotRouter,
+		New:  NewRouter,
+		Conf: func(cnf *config.Config) interface{} {
+			// routes use the sections and profiles of any backend.
+			content, _ := json.Marshal(cnf)
+			return string(content)
+		},
+	})
+}
+
+// GetBotBackend finds a backend by name, case insensitively.
+func GetBotBackend(name string) *BotBackend {
+	for _, b := range BotBackends {
+		if strings.EqualFold(b.Name, name) {
+			return b
+		}
+	}
+	return nil
+}
+
+// BotNames returns names of all backends.
+func BotNames() (names []string) {
+	for _, b := range BotBackends {
+		names = append(names, b.Name)
+	}
+	return
+}
+
+// NewBot creates a client of the named backend, ChatGPT for unknown names.
+func NewBot(cnf *config.Config, name string) Bot {
+	if b := GetBotBackend(name); b != nil {
+		return b.New(cnf)
+	}
+	return BotBackends[0].New(cnf)
+}
+
+// botConfig returns a copy of cnf with the settings of a profile, the active ones if empty.
+func botConfig(cnf *config.Config, profile string) (*config.Config, error) {
+	c := cnf.Copy()
+	if profile != "" {
+		if err := c.UseProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Routed is implemented by bots answering with one of several providers.
+type Routed interface {
+	Provider() string
+	Model() string
+}
+
+// NewRouter creates clients of the router routes, each api key of a route has its own client.
+func NewRouter(cnf *config.Config) Bot {
+	r := router.NewRouter(cnf.Router)
+	for _, rc := range cnf.Router.Routes {
+		b := GetBotBackend(rc.Bot)
+		if b == nil || b.Name == cvsation.BotRouter {
+			r.Err = fmt.Errorf("router: unknown bot %q, available: %s", rc.Bot, strings.Join(BotNames(), ", "))
+			return r
+		}
+		c, err := botConfig(cnf, rc.Profile)
+		if err != nil {
+			r.Err = fmt.Errorf("router: %w", err)
+			return r
+		}
+		name := b.Name
+		if rc.Profile != "" {
+			name += "@" + rc.Profile
+		}
+		if len(rc.ApiKeys) > 0 && b.SetKey == nil {
+			r.Err = fmt.Errorf("router: api keys of %s can not take turns", b.Name)
+			return r
+		}
+		route := []*router.Provider{}
+		if len(rc.ApiKeys) == 0 {
+			route = append(route, &router.Provider{Name: name, Model: b.ModelOf(c), Bot: b.New(c), Retry: b.Retry})
+		}
+		for i, key := range rc.ApiKeys {
+			kc := c.Copy()
+			b.SetKey(kc, key)
+			route = append(route, &router.Provider{Name: fmt.Sprintf("%s#%d", name, i+1), Model: b.ModelOf(kc), Bot: b.New(kc), Retry: b.Retry})
+		}
+		r.Routes = append(r.Routes, route)
+	}
+	return r
+}
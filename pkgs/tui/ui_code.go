@@ -0,0 +1,153 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	cvsation "github.com/gvcgo/gogpt/pkgs/conversation"
+)
+
+/*
+Save code blocks from an answer to files.
+*/
+type CodeSaved string
+
+type CodeCanceled string
+
+type CodeSaveModel struct {
+	Blocks   []cvsation.CodeBlock
+	Cursor   int
+	Filename textinput.Model
+	Dir      string
+	Preview  []string // diff preview for an existing file
+	Error    error
+	Height   int
+	confirm  bool
+}
+
+func NewCodeSaveModel(blocks []cvsation.CodeBlock, height int) (csm *CodeSaveModel) {
+	dir, _ := os.Getwd()
+	csm = &CodeSaveModel{
+		Blocks: blocks,
+		Dir:    dir,
+		Height: height,
+	}
+	csm.Filename = textinput.New()
+	csm.Filename.Prompt = "save as: "
+	csm.Filename.Focus()
+	csm.selectBlock(0)
+	return
+}
+
+func (that *CodeSaveModel) selectBlock(idx int) {
+	if idx < 0 || idx >= len(that.Blocks) {
+		return
+	}
+	that.Cursor = idx
+	that.Filename.SetValue(that.Blocks[idx].Filename)
+	that.Filename.CursorEnd()
+	that.reset()
+}
+
+func (that *CodeSaveModel) reset() {
+	that.confirm = false
+	that.Preview = nil
+	that.Error = nil
+}
+
+func (that *CodeSaveModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (that *CodeSaveModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "ctrl+o":
+			return that, func() tea.Msg { return CodeCanceled("") }
+		case "up":
+			that.selectBlock(that.Cursor - 1)
+			return that, nil
+		case "down":
+			that.selectBlock(that.Cursor + 1)
+			return that, nil
+		case "enter":
+			return that, that.save()
+		}
+		that.reset()
+	}
+	that.Filename, cmd = that.Filename.Update(msg)
+	return that, cmd
+}
+
+func (that *CodeSaveModel) save() tea.Cmd {
+	block := that.Blocks[that.Cursor]
+	fPath, err := cvsation.ResolveCodePath(that.Dir, that.Filename.Value())
+	if err != nil {
+		that.Error = err
+		return nil
+	}
+	if content, err := os.ReadFile(fPath); err == nil && !that.confirm {
+		// show what will change, save on the next enter.
+		that.Preview = cvsation.DiffLines(string(content), block.Code)
+		that.confirm = true
+		return nil
+	}
+	if fPath, err = cvsation.SaveCodeBlock(that.Dir, that.Filename.Value(), block.Code); err != nil {
+		that.Error = err
+		return nil
+	}
+	return func() tea.Msg { return CodeSaved(fPath) }
+}
+
+func (that *CodeSaveModel) View() string {
+	rows := []string{}
+	for i, b := range that.Blocks {
+		lang := b.Lang
+		if lang == "" {
+			lang = "text"
+		}
+		row := fmt.Sprintf("%d. %-10s %-30s %d lines", i+1, lang, b.Filename, b.Lines())
+		if i == that.Cursor {
+			rows = append(rows, codeSelectedStyle.Render("> "+row))
+		} else {
+			rows = append(rows, codeNormalStyle.Render("  "+row))
+		}
+	}
+	rows = append(rows, "", that.Filename.View())
+	if that.Error != nil {
+		rows = append(rows, errorStyle.Render(fmt.Sprintf("error: %+v", that.Error)))
+	}
+
+	var preview []string
+	if that.confirm {
+		rows = append(rows, footerStyle.Render("file exists, press enter again to overwrite it:"))
+		for _, l := range that.Preview {
+			switch {
+			case strings.HasPrefix(l, "+ "):
+				preview = append(preview, diffAddStyle.Render(l))
+			case strings.HasPrefix(l, "- "):
+				preview = append(preview, diffDelStyle.Render(l))
+			default:
+				preview = append(preview, l)
+			}
+		}
+	} else if len(that.Blocks) > 0 {
+		rows = append(rows, footerStyle.Render("↑/↓ select, enter save, esc cancel"))
+		preview = strings.Split(that.Blocks[that.Cursor].Code, "\n")
+	}
+	rows = append(rows, "")
+	if left := that.Height - len(rows); left < len(preview) {
+		if left < 0 {
+			left = 0
+		}
+		preview = preview[:left]
+	}
+	rows = append(rows, preview...)
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
@@ -0,0 +1,457 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/gvcgo/gogpt/pkgs/gpt"
+)
+
+/*
+Slash commands in the conversation input.
+*/
+const (
+	CommandPrefix string = "/"
+)
+
+type SlashCommand struct {
+	Name     string
+	Args     string // usage of arguments
+	Help     string
+	Complete func(cvm *ConversationModel, arg string) []string
+	Run      func(cvm *ConversationModel, args []string) tea.Cmd
+	Rest     bool // the argument is everything after the name, it may contain spaces
+}
+
+func (that *SlashCommand) Usage() string {
+	if that.Args == "" {
+		return CommandPrefix + that.Name
+	}
+	return fmt.Sprintf("%s%s %s", CommandPrefix, that.Name, that.Args)
+}
+
+type CommandRegistry struct {
+	cmds map[string]*SlashCommand
+}
+
+func NewCommandRegistry() (cr *CommandRegistry) {
+	cr = &CommandRegistry{cmds: map[string]*SlashCommand{}}
+	return
+}
+
+func (that *CommandRegistry) Register(cmds ...*SlashCommand) {
+	for _, c := range cmds {
+		that.cmds[c.Name] = c
+	}
+}
+
+func (that *CommandRegistry) Get(name string) *SlashCommand {
+	return that.cmds[strings.TrimPrefix(name, CommandPrefix)]
+}
+
+// List returns commands sorted by name.
+func (that *CommandRegistry) List() (cmds []*SlashCommand) {
+	for _, c := range that.cmds {
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return
+}
+
+// IsCommand reports whether the input should be run as a slash command, "//" sends a literal "/".
+func IsCommand(input string) bool {
+	return strings.HasPrefix(input, CommandPrefix) && !strings.HasPrefix(input, CommandPrefix+CommandPrefix)
+}
+
+// Run dispatches the input to its command.
+func (that *CommandRegistry) Run(cvm *ConversationModel, input string) tea.Cmd {
+	args := strings.Fields(input)
+	if len(args) == 0 {
+		return nil
+	}
+	c := that.Get(args[0])
+	if c == nil {
+		cvm.Notice = fmt.Sprintf("unknown command %s, try /help", args[0])
+		return nil
+	}
+	return c.Run(cvm, args[1:])
+}
+
+// Complete completes the command name or its last argument, or all arguments for a Rest command, candidates are returned for the hint.
+func (that *CommandRegistry) Complete(cvm *ConversationModel, input string) (completed string, candidates []string) {
+	completed = input
+	args := strings.Fields(input)
+	if len(args) == 0 {
+		return
+	}
+	if len(args) == 1 && !strings.HasSuffix(input, " ") {
+		for _, c := range that.List() {
+			if strings.HasPrefix(CommandPrefix+c.Name, args[0]) {
+				candidates = append(candidates, CommandPrefix+c.Name)
+			}
+		}
+		if len(candidates) == 1 {
+			return candidates[0] + " ", candidates
+		}
+		if p := commonPrefix(candidates); len(p) > len(args[0]) {
+			completed = p
+		}
+		return
+	}
+	c := that.Get(args[0])
+	if c == nil || c.Complete == nil {
+		return
+	}
+	prefix := ""
+	if c.Rest {
+		prefix = strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(input, " "), args[0]), " ")
+	} else if !strings.HasSuffix(input, " ") {
+		prefix = args[len(args)-1]
+	}
+	for _, v := range c.Complete(cvm, prefix) {
+		if strings.HasPrefix(strings.ToLower(v), strings.ToLower(prefix)) {
+			candidates = append(candidates, v)
+		}
+	}
+	base := strings.TrimSuffix(input, prefix)
+	if len(candidates) == 1 {
+		return base + candidates[0], candidates
+	}
+	if p := commonPrefix(candidates); len(p) > len(prefix) {
+		completed = base + p
+	}
+	return
+}
+
+// commonPrefix returns the longest prefix of all strings, never ending inside a character.
+func commonPrefix(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	p := []rune(list[0])
+	for _, s := range list[1:] {
+		for !strings.HasPrefix(s, string(p)) {
+			p = p[:len(p)-1]
+		}
+	}
+	return string(p)
+}
+
+// Hint returns the inline help for the input.
+func (that *CommandRegistry) Hint(input string, candidates []string) string {
+	if !IsCommand(input) {
+		return ""
+	}
+	args := strings.Fields(input)
+	if c := that.Get(args[0]); c != nil && (len(args) > 1 || strings.HasSuffix(input, " ")) {
+		hint := fmt.Sprintf("%s  %s", c.Usage(), c.Help)
+		if len(candidates) > 1 {
+			hint += "  [" + strings.Join(candidates, " ") + "]"
+		}
+		return commandHintStyle.Render(hint)
+	}
+	names := []string{}
+	for _, c := range that.List() {
+		if len(args) == 0 || strings.HasPrefix(CommandPrefix+c.Name, args[0]) {
+			names = append(names, c.Usage())
+		}
+	}
+	return commandHintStyle.Render(strings.Join(names, "  "))
+}
+
+/*
+Default commands.
+*/
+// ModelsDiscovered is returned by "/model refresh".
+type ModelsDiscovered struct {
+	Models []string
+	Err    error
+}
+
+func RegisterDefaultCommands(cr *CommandRegistry) {
+	cr.Register(
+		&SlashCommand{
+			Name: "help",
+			Help: "Show slash commands.",
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				lines := []string{}
+				for _, c := range cvm.Commands.List() {
+					lines = append(lines, fmt.Sprintf("%-28s %s", c.Usage(), c.Help))
+				}
+				cvm.Viewport.SetContent(strings.Join(lines, "\n"))
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "model",
+			Args: "<model>|refresh",
+			Help: "Set the ChatGPT model, any name served by the base url is allowed.",
+			Complete: func(cvm *ConversationModel, arg string) []string {
+				return gpt.MergeModels(gpt.CachedModels(cvm.CNF), GPTModelList)
+			},
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				if len(args) == 0 {
+					cvm.Notice = fmt.Sprintf("model: %s", cvm.CNF.OpenAI.Model)
+					return nil
+				}
+				if args[0] == "refresh" {
+					cvm.Notice = "listing models..."
+					cnf := cvm.CNF.Copy()
+					return func() tea.Msg {
+						models, err := gpt.DiscoverModels(cnf, true)
+						return ModelsDiscovered{Models: models, Err: err}
+					}
+				}
+				cvm.CNF.OpenAI.Model = args[0]
+				cvm.Notice = fmt.Sprintf("model: %s", args[0])
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "prompt",
+			Args: "<title>",
+			Help: "Use a prompt by its title.",
+			Rest: true,
+			Complete: func(cvm *ConversationModel, arg string) (titles []string) {
+				if cvm.Prompt == nil {
+					return
+				}
+				for _, p := range *cvm.Prompt.PromptList {
+					titles = append(titles, p.Title)
+				}
+				sort.Strings(titles)
+				return
+			},
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				if len(args) == 0 || cvm.Prompt == nil {
+					cvm.Notice = "usage: /prompt <title>"
+					return nil
+				}
+				title := strings.Join(args, " ")
+				cvm.CNF.OpenAI.PromptStr = cvm.Prompt.GetPromptByTile(title)
+				cvm.Notice = fmt.Sprintf("prompt: %s", title)
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "temp",
+			Args: "<float>",
+			Help: "Set the temperature of the current bot.",
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				if len(args) == 0 {
+					cvm.Notice = "usage: /temp <float>"
+					return nil
+				}
+				b := GetBotBackend(cvm.Conversation.BotType)
+				if b == nil {
+					b = BotBackends[0]
+				}
+				if b.SetTemp == nil {
+					cvm.Notice = fmt.Sprintf("%s has no temperature, set it on its backends", b.Name)
+					return nil
+				}
+				if err := config.CheckFloat(args[0], b.TempRange); err != nil {
+					cvm.Notice = fmt.Sprintf("temperature %s", err)
+					return nil
+				}
+				b.SetTemp(cvm.CNF, gconv.Float64(args[0]))
+				cvm.Notice = fmt.Sprintf("temperature: %s", args[0])
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "save",
+			Args: "[name]",
+			Help: "Save the conversation, with an optional session name.",
+			Complete: func(cvm *ConversationModel, arg string) []string {
+				return cvm.Conversation.ListSessions()
+			},
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				name := strings.Join(args, "_")
+				if err := cvm.Conversation.SaveAs(name); err != nil {
+					cvm.Error = err
+					return nil
+				}
+				cvm.History.MoveTo(cvm.Conversation.SessionFile())
+				cvm.Notice = fmt.Sprintf("saved: %s", cvm.Conversation.SessionPath(name))
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "load",
+			Args: "[name]",
+			Help: "Load a conversation, with an optional session name.",
+			Complete: func(cvm *ConversationModel, arg string) []string {
+				return cvm.Conversation.ListSessions()
+			},
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				if cvm.Receiving {
+					cvm.Notice = "wait for the answer before loading a conversation"
+					return nil
+				}
+				name := strings.Join(args, "_")
+				if err := cvm.loadSession(name); err != nil {
+					cvm.Error = err
+					return nil
+				}
+				cvm.Notice = fmt.Sprintf("loaded: %s", cvm.Conversation.SessionPath(name))
+				if p := cvm.Conversation.Saver.Profile; p != "" && p != cvm.CNF.Profile() {
+					cvm.Notice += fmt.Sprintf(", saved with profile %s, /profile %s to use it", p, p)
+				}
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "clear",
+			Help: "Remove conversation context.",
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				if cvm.Receiving {
+					cvm.Notice = "wait for the answer before clearing the context"
+					return nil
+				}
+				cvm.Conversation.ClearContext()
+				cvm.Notice = "context cleared"
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "bot",
+			Args: "<chatgpt|spark|claude|gemini|ollama|qwen|ernie|glm|router>",
+			Help: "Switch bot.",
+			Complete: func(cvm *ConversationModel, arg string) (names []string) {
+				for _, name := range BotNames() {
+					names = append(names, strings.ToLower(name))
+				}
+				return
+			},
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				if len(args) == 0 {
+					cvm.Notice = fmt.Sprintf("bot: %s", cvm.Conversation.BotType)
+					return nil
+				}
+				b := GetBotBackend(args[0])
+				if b == nil {
+					cvm.Notice = fmt.Sprintf("unknown bot %q, available: %s", args[0], strings.Join(BotNames(), ", "))
+					return nil
+				}
+				if cvm.UseBot(b.Name) {
+					cvm.Notice = fmt.Sprintf("bot: %s", b.Name)
+				}
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "compare",
+			Args: "[bot[@profile][:model]...]",
+			Help: "Ask several bots side by side, without arguments to stop.",
+			Complete: func(cvm *ConversationModel, arg string) (names []string) {
+				for _, name := range BotNames() {
+					names = append(names, strings.ToLower(name))
+				}
+				return
+			},
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				cvm.UseCompare(args)
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "pick",
+			Args: "<n>",
+			Help: "Keep the answer of column n in the conversation.",
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				if len(args) == 0 {
+					cvm.Notice = "usage: /pick <n>"
+					return nil
+				}
+				cvm.PickAnswer(gconv.Int(args[0]))
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "profile",
+			Args: "[name]|save <name>",
+			Help: "Switch the config profile, or save the current settings as a profile.",
+			Complete: func(cvm *ConversationModel, arg string) []string {
+				return append(cvm.CNF.ProfileNames(), "save")
+			},
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				if len(args) == 0 {
+					cvm.Notice = fmt.Sprintf("profile: %s, available: %s", cvm.CNF.Profile(), strings.Join(cvm.CNF.ProfileNames(), ", "))
+					return nil
+				}
+				if args[0] == "save" {
+					if len(args) < 2 {
+						cvm.Notice = "usage: /profile save <name>"
+						return nil
+					}
+					if err := cvm.CNF.SaveProfile(args[1]); err != nil {
+						cvm.Error = err
+						return nil
+					}
+					cvm.Notice = fmt.Sprintf("saved profile: %s", args[1])
+					return nil
+				}
+				if cvm.Receiving {
+					cvm.Notice = "wait for the answer before switching profiles"
+					return nil
+				}
+				if err := cvm.CNF.UseProfile(args[0]); err != nil {
+					cvm.Error = err
+					return nil
+				}
+				if b := GetBotBackend(cvm.CNF.ProfileBot()); b != nil {
+					cvm.UseBot(b.Name)
+				}
+				notice := fmt.Sprintf("profile: %s", cvm.CNF.Profile())
+				return func() tea.Msg { return ConfigChanged{Notice: notice} }
+			},
+		},
+		&SlashCommand{
+			Name: "export",
+			Args: "<md|json> [file]",
+			Help: "Export the conversation to the exports dir in the data dir.",
+			Complete: func(cvm *ConversationModel, arg string) []string {
+				return []string{"md", "json"}
+			},
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				format, name := "md", ""
+				if len(args) > 0 {
+					format = args[0]
+				}
+				if len(args) > 1 {
+					name = args[1]
+				}
+				fPath, err := cvm.Conversation.Export(format, name)
+				if err != nil {
+					cvm.Error = err
+					return nil
+				}
+				cvm.Notice = fmt.Sprintf("exported: %s", fPath)
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "attach",
+			Args: "<file|dir|glob>...",
+			Help: "Attach files as context.",
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				cvm.AttachFiles(args)
+				return nil
+			},
+		},
+		&SlashCommand{
+			Name: "detach",
+			Help: "Remove attached files.",
+			Run: func(cvm *ConversationModel, args []string) tea.Cmd {
+				cvm.Conversation.Detach()
+				cvm.Notice = "attachments removed"
+				return nil
+			},
+		},
+	)
+}
@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+
+	cvsation "github.com/gvcgo/gogpt/pkgs/conversation"
+	"github.com/gvcgo/gogpt/pkgs/gpt"
+)
+
+func TestCommonPrefix(t *testing.T) {
+	cases := map[string][]string{
+		"":         nil,
+		"/mo":      {"/model", "/mouse"},
+		"翻译":       {"翻译英文", "翻译中文"},
+		"英语":       {"英语老师", "英语翻译"},
+		"中":        {"中英", "中老"}, // 英 and 老 share the first byte
+		"Act as a": {"Act as a poet", "Act as an editor"},
+	}
+	for want, list := range cases {
+		got := commonPrefix(list)
+		if got != want || !utf8.ValidString(got) {
+			t.Errorf("commonPrefix(%q) = %q, want %q", list, got, want)
+		}
+	}
+}
+
+func TestCompletePromptTitles(t *testing.T) {
+	cvm := newTestConversationModel(t)
+	cvm.Prompt = &gpt.GPTPrompt{PromptList: &[]gpt.PromptItem{
+		{Title: "Act as a Linux Terminal"},
+		{Title: "Act as a Poet"},
+		{Title: "英语翻译"},
+		{Title: "英语老师"},
+	}}
+	cases := map[string]string{
+		"/prompt act as a l": "/prompt Act as a Linux Terminal",
+		"/prompt Act":        "/prompt Act as a ",
+		"/prompt 英":          "/prompt 英语",
+	}
+	for input, want := range cases {
+		if got, _ := cvm.Commands.Complete(cvm, input); got != want {
+			t.Errorf("Complete(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestExportToDataDir(t *testing.T) {
+	cvm := newTestConversationModel(t)
+	cvm.Conversation.AddQuestion("q")
+	cvm.Conversation.AddAnswer("a", true)
+	wd, _ := os.Getwd()
+
+	cvm.Commands.Run(cvm, "/export json notes.json")
+	if cvm.Error != nil {
+		t.Fatal(cvm.Error)
+	}
+	fPath := filepath.Join(cvm.CNF.GetDataDir(), cvsation.ExportDirName, "notes.json")
+	info, err := os.Stat(fPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode of the export %v", info.Mode().Perm())
+	}
+	if _, err := os.Stat(filepath.Join(wd, "notes.json")); err == nil {
+		t.Error("exported to the current directory")
+	}
+
+	cvm.Commands.Run(cvm, "/export md ../escape.md")
+	if cvm.Error == nil {
+		t.Error("exported outside of the exports dir")
+	}
+}
@@ -0,0 +1,345 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/muesli/reflow/wrap"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+/*
+Compare mode.
+
+A question is sent to several bots at once, like "/compare claude chatgpt:gpt-4 gemini@work",
+answers stream in parallel columns. "/pick <n>" keeps one of them as the answer in the conversation.
+
+A target is bot[@profile][:model], the settings of the profile and the model only apply to the column.
+*/
+type CompareTarget struct {
+	Name    string // as entered, with the canonical bot name.
+	Backend *BotBackend
+	Profile string
+	Model   string
+}
+
+// ParseCompareTarget parses bot[@profile][:model].
+func ParseCompareTarget(s string) (t CompareTarget, err error) {
+	rest, model, _ := strings.Cut(s, ":")
+	bot, profile, _ := strings.Cut(rest, "@")
+	b := GetBotBackend(bot)
+	if b == nil {
+		return t, fmt.Errorf("unknown bot %q, available: %s", bot, strings.Join(BotNames(), ", "))
+	}
+	if model != "" && b.SetModel == nil {
+		return t, fmt.Errorf("the model of %s can not be set", b.Name)
+	}
+	t = CompareTarget{Backend: b, Profile: profile, Model: model}
+	t.Name = t.Bot()
+	if model != "" {
+		t.Name += ":" + model
+	}
+	return
+}
+
+// NewBot creates a client with the settings of the target.
+// Secrets are resolved here, as the clients of all targets answer in parallel.
+func (that CompareTarget) NewBot(cnf *config.Config) (Bot, error) {
+	c, err := botConfig(cnf, that.Profile)
+	if err != nil {
+		return nil, err
+	}
+	if that.Model != "" {
+		that.Backend.SetModel(c, that.Model)
+	}
+	if err = that.Backend.ResolveSecrets(c); err != nil {
+		return nil, err
+	}
+	return that.Backend.New(c), nil
+}
+
+// Bot returns the name of the backend with the profile.
+func (that CompareTarget) Bot() string {
+	if that.Profile == "" {
+		return that.Backend.Name
+	}
+	return that.Backend.Name + "@" + that.Profile
+}
+
+// ModelOf returns the model of the target, the one of its profile if not set.
+func (that CompareTarget) ModelOf(cnf *config.Config) string {
+	if that.Model != "" {
+		return that.Model
+	}
+	if c, err := botConfig(cnf, that.Profile); err == nil {
+		return that.Backend.ModelOf(c)
+	}
+	return ""
+}
+
+type CompareColumn struct {
+	Target  CompareTarget
+	Bot     Bot
+	Answer  string
+	Tokens  int64
+	Err     error
+	Done    bool
+	Start   time.Time
+	First   time.Duration // until the first message
+	Elapsed time.Duration
+}
+
+// Stats shows tokens and latency of the answer.
+func (that *CompareColumn) Stats() string {
+	if that.Err != nil {
+		return fmt.Sprintf("error: %v", that.Err)
+	}
+	stats := fmt.Sprintf("tokens %d", that.Tokens)
+	if that.First > 0 {
+		stats += fmt.Sprintf(" · first %.1fs", that.First.Seconds())
+	}
+	if that.Done {
+		stats += fmt.Sprintf(" · total %.1fs", that.Elapsed.Seconds())
+	} else {
+		stats += " · ..."
+	}
+	return stats
+}
+
+// CompareChunk is a message of a column, sent in the background.
+type CompareChunk struct {
+	Run    int
+	Index  int
+	Answer string
+	Err    error
+}
+
+type CompareModel struct {
+	Targets []CompareTarget
+	Columns []*CompareColumn // answers of the current question
+	run     int              // chunks of older questions are dropped
+}
+
+func NewCompareModel(targets []CompareTarget) *CompareModel {
+	return &CompareModel{Targets: targets}
+}
+
+// Names returns the names of the targets.
+func (that *CompareModel) Names() (names []string) {
+	for _, t := range that.Targets {
+		names = append(names, t.Name)
+	}
+	return
+}
+
+// Ask sends msgs to every target, failing targets show their errors.
+func (that *CompareModel) Ask(cnf *config.Config, msgs []openai.ChatCompletionMessage) (cmds []tea.Cmd) {
+	that.Close()
+	that.Columns = nil
+	for i, t := range that.Targets {
+		col := &CompareColumn{Target: t, Start: time.Now()}
+		that.Columns = append(that.Columns, col)
+		if col.Bot, col.Err = t.NewBot(cnf); col.Err != nil {
+			col.Done = true
+			continue
+		}
+		run, idx, bot := that.run, i, col.Bot
+		cmds = append(cmds, func() tea.Msg {
+			answer, err := bot.SendMsg(msgs)
+			return CompareChunk{Run: run, Index: idx, Answer: answer, Err: err}
+		})
+	}
+	return
+}
+
+// Update adds a chunk to its column, and reads the next one.
+func (that *CompareModel) Update(msg CompareChunk) tea.Cmd {
+	if msg.Run != that.run || msg.Index >= len(that.Columns) {
+		return nil
+	}
+	col := that.Columns[msg.Index]
+	if col.First == 0 && msg.Answer != "" {
+		col.First = time.Since(col.Start)
+	}
+	col.Answer += msg.Answer
+	col.Tokens += col.Bot.GetTokens()
+	if msg.Err != nil {
+		if msg.Err != io.EOF {
+			col.Err = msg.Err
+		}
+		col.Done = true
+		col.Elapsed = time.Since(col.Start)
+		col.Bot.Close()
+		return nil
+	}
+	run, idx, bot := msg.Run, msg.Index, col.Bot
+	return func() tea.Msg {
+		answer, err := bot.RecvMsg()
+		return CompareChunk{Run: run, Index: idx, Answer: answer, Err: err}
+	}
+}
+
+// Done tells if all the answers are finished.
+func (that *CompareModel) Done() bool {
+	for _, col := range that.Columns {
+		if !col.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// Close stops the answers, their remaining chunks are dropped.
+func (that *CompareModel) Close() {
+	that.run++
+	for _, col := range that.Columns {
+		if col.Bot != nil && !col.Done {
+			col.Bot.Close()
+		}
+	}
+}
+
+// Render shows the answers in columns of width in total.
+func (that *CompareModel) Render(cvm *ConversationModel, width int) string {
+	if len(that.Columns) == 0 || width <= 0 {
+		return ""
+	}
+	colWidth := width/len(that.Columns) - 1
+	if colWidth < 10 {
+		colWidth = 10
+	}
+	views := []string{}
+	for i, col := range that.Columns {
+		content := col.Answer
+		if cvm.ContainsCJK(content) {
+			content = wrap.String(content, colWidth)
+		} else {
+			content = wordwrap.String(content, colWidth)
+		}
+		content, _ = cvm.R.Render(content)
+		views = append(views, lipgloss.NewStyle().Width(colWidth).MarginRight(1).Render(lipgloss.JoinVertical(
+			lipgloss.Left,
+			botStyle.Render(fmt.Sprintf("[%d] %s", i+1, col.Target.Name)),
+			commandHintStyle.Render(col.Stats()),
+			strings.TrimSpace(content),
+		)))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, views...)
+}
+
+/*
+Compare mode of the conversation.
+*/
+
+// UseCompare starts the compare mode with targets, or stops it without.
+func (that *ConversationModel) UseCompare(args []string) {
+	if that.Receiving {
+		that.Notice = "wait for the answer before switching modes"
+		return
+	}
+	if len(args) == 0 {
+		if that.Compare != nil {
+			that.Compare.Close()
+			that.Compare = nil
+		}
+		that.Notice = "compare mode off"
+		return
+	}
+	if len(args) < 2 {
+		that.Notice = "usage: /compare <bot[@profile][:model]> <bot[@profile][:model]>..."
+		return
+	}
+	targets := []CompareTarget{}
+	for _, arg := range args {
+		t, err := ParseCompareTarget(arg)
+		if err != nil {
+			that.Notice = err.Error()
+			return
+		}
+		if _, err = botConfig(that.CNF, t.Profile); err != nil {
+			that.Error = err
+			return
+		}
+		targets = append(targets, t)
+	}
+	if that.Compare != nil {
+		that.Compare.Close()
+	}
+	that.Compare = NewCompareModel(targets)
+	that.Notice = fmt.Sprintf("comparing %s, /pick <n> keeps an answer", strings.Join(that.Compare.Names(), ", "))
+}
+
+// askCompare sends the current question to the targets of the compare mode.
+func (that *ConversationModel) askCompare() (cmds []tea.Cmd) {
+	cmds = append(cmds, func() tea.Msg {
+		return that.Spinner.Tick()
+	})
+	cmds = append(cmds, that.Compare.Ask(that.CNF, that.Conversation.GetMessages())...)
+	that.Receiving = !that.Compare.Done()
+	that.showCompare()
+	return
+}
+
+// compared adds a chunk to its column.
+func (that *ConversationModel) compared(msg CompareChunk) (cmds []tea.Cmd) {
+	if that.Compare == nil {
+		return
+	}
+	if cmd := that.Compare.Update(msg); cmd != nil {
+		cmds = append(cmds, cmd)
+	}
+	that.Receiving = !that.Compare.Done()
+	that.showCompare()
+	return
+}
+
+// showCompare shows the question and the answers in columns.
+func (that *ConversationModel) showCompare() {
+	var b strings.Builder
+	b.WriteString(senderStyle.Render("You: "))
+	content := that.Conversation.GetQAByCursor().Q
+	if that.ContainsCJK(content) {
+		content = wrap.String(content, that.WindowWidth-5)
+	} else {
+		content = wordwrap.String(content, that.WindowWidth-5)
+	}
+	content, _ = that.R.Render(content)
+	b.WriteString(that.EnsureTrailingNewline(content))
+	b.WriteString(that.Compare.Render(that, that.WindowWidth-5))
+	that.Viewport.SetContent(b.String())
+	that.Viewport.GotoBottom()
+}
+
+// PickAnswer keeps the answer of the nth column as the reply in the conversation.
+func (that *ConversationModel) PickAnswer(n int) {
+	if that.Compare == nil || len(that.Compare.Columns) == 0 || that.Conversation.Current == nil {
+		that.Notice = "no answers to pick, ask a question in compare mode first"
+		return
+	}
+	if that.Receiving {
+		that.Notice = "wait for the answers before picking one"
+		return
+	}
+	if n < 1 || n > len(that.Compare.Columns) {
+		that.Notice = fmt.Sprintf("pick an answer from 1 to %d", len(that.Compare.Columns))
+		return
+	}
+	col := that.Compare.Columns[n-1]
+	if col.Err != nil {
+		that.Notice = fmt.Sprintf("answer %d failed, pick another one", n)
+		return
+	}
+	that.Conversation.AddTokens(col.Tokens)
+	that.Conversation.TagAnswer(col.Target.Bot(), col.Target.ModelOf(that.CNF))
+	that.Conversation.AddAnswer(col.Answer, true)
+	that.Compare.Columns = nil
+	that.Viewport.SetContent(that.RenderQA(that.Conversation.GetQAByCursor()))
+	that.Viewport.GotoBottom()
+	that.Notice = fmt.Sprintf("kept the answer of %s", col.Target.Name)
+}
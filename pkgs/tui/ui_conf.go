@@ -1,19 +1,31 @@
 package tui
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/gogf/gf/v2/util/gconv"
 	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
-	"github.com/gvcgo/goutils/pkgs/gtea/input"
 	"github.com/gvcgo/goutils/pkgs/gutils"
+	"github.com/gvcgo/gogpt/pkgs/anthropic"
 	"github.com/gvcgo/gogpt/pkgs/config"
+	cvsation "github.com/gvcgo/gogpt/pkgs/conversation"
+	"github.com/gvcgo/gogpt/pkgs/dashscope"
+	"github.com/gvcgo/gogpt/pkgs/gemini"
 	"github.com/gvcgo/gogpt/pkgs/gpt"
+	"github.com/gvcgo/gogpt/pkgs/ollama"
+	"github.com/gvcgo/gogpt/pkgs/qianfan"
+	"github.com/gvcgo/gogpt/pkgs/theme"
+	"github.com/gvcgo/gogpt/pkgs/zhipu"
 	openai "github.com/sashabaranov/go-openai"
+	"golang.org/x/term"
 )
 
 type PromptString string
@@ -40,6 +52,10 @@ var (
 	apiVersion     string = "api_version"
 	orgID          string = "orgID"
 	engine         string = "engine"
+	tenantID       string = "azure_tenant_id"
+	clientID       string = "azure_client_id"
+	clientSecret   string = "azure_client_secret"
+	tokenUrl       string = "azure_token_url"
 	limit          string = "empty_limit"
 	maxTokens      string = "max_tokens"
 	ctxLen         string = "context_length"
@@ -64,35 +80,248 @@ var (
 	sparkTimeout     string = "spark_timeout"
 )
 
-func GetGoGPTConfigModel(prompt *gpt.GPTPrompt, conf *config.Config) ExtraModel {
-	mi := input.NewInputMultiModel()
-	mi.SetInputPromptFormat("%-20s")
-	// ChatGPT
-	placeHolderStyle := input.MWithPlaceholderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#BEBEBE")))
-
-	mi.AddOneInput(
-		apiKey,
-		input.MWithPlaceholder("ChatGPT auth token"),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.OpenAI.ApiKey),
-		placeHolderStyle,
-	)
+/*
+Anthropic Claude related
+*/
+var (
+	claudeBaseUrl     string = "claude_base_url"
+	claudeApiKey      string = "claude_api_key"
+	claudeApiVersion  string = "claude_api_version"
+	claudeProxy       string = "claude_proxy"
+	claudeModel       string = "select_claude_model"
+	claudeMaxTokens   string = "claude_max_tokens"
+	claudeTemperature string = "claude_temperature"
+)
 
-	mi.AddOneInput(
-		proxy,
-		input.MWithPlaceholder("ChatGPT local proxy"),
-		input.MWithWidth(150),
-		input.MWithDefaultValue(conf.OpenAI.Proxy),
-		placeHolderStyle,
-	)
+/*
+Google Gemini related
+*/
+var (
+	geminiBaseUrl     string = "gemini_base_url"
+	geminiApiKey      string = "gemini_api_key"
+	geminiApiVersion  string = "gemini_api_version"
+	geminiProxy       string = "gemini_proxy"
+	geminiModel       string = "select_gemini_model"
+	geminiMaxTokens   string = "gemini_max_tokens"
+	geminiTemperature string = "gemini_temperature"
+)
 
-	mi.AddOneInput(
-		ctxLen,
-		input.MWithPlaceholder("ChatGPT Conversation context length"),
-		input.MWithDefaultValue(gconv.String(conf.OpenAI.ContextLen)),
-		input.MWithWidth(100),
-		placeHolderStyle,
-	)
+/*
+Ollama related
+*/
+var (
+	ollamaBaseUrl     string = "ollama_base_url"
+	ollamaModel       string = "select_ollama_model"
+	ollamaNumCtx      string = "ollama_num_ctx"
+	ollamaTemperature string = "ollama_temperature"
+	ollamaKeepAlive   string = "ollama_keep_alive"
+)
+
+/*
+Qwen, ERNIE and GLM related
+*/
+var (
+	qwenBaseUrl      string = "qwen_base_url"
+	qwenApiKey       string = "qwen_api_key"
+	qwenModel        string = "select_qwen_model"
+	qwenMaxTokens    string = "qwen_max_tokens"
+	qwenTemperature  string = "qwen_temperature"
+	ernieBaseUrl     string = "ernie_base_url"
+	ernieApiKey      string = "ernie_api_key"
+	ernieSecretKey   string = "ernie_secret_key"
+	ernieModel       string = "select_ernie_model"
+	ernieMaxTokens   string = "ernie_max_tokens"
+	ernieTemperature string = "ernie_temperature"
+	glmBaseUrl       string = "glm_base_url"
+	glmApiKey        string = "glm_api_key"
+	glmModel         string = "select_glm_model"
+	glmMaxTokens     string = "glm_max_tokens"
+	glmTemperature   string = "glm_temperature"
+)
+
+/*
+Router related
+*/
+var (
+	routerRoutes     string = "router_routes"
+	routerAttempts   string = "router_attempts"
+	routerBackoff    string = "router_backoff"
+	routerMaxBackoff string = "router_max_backoff"
+)
+
+/*
+TUI related
+*/
+var (
+	uiSubmitKey      string = "submit_key"
+	uiInputMaxHeight string = "input_max_height"
+	uiTheme          string = "theme"
+)
+
+// ChatGPT models for selection.
+var GPTModelList = []string{
+	openai.GPT3Dot5Turbo0613,
+	openai.GPT3Dot5Turbo,
+	openai.GPT432K0613,
+	openai.GPT4,
+	openai.GPT432K0314,
+	openai.GPT432K,
+	openai.GPT40613,
+	openai.GPT40314,
+	openai.GPT4TurboPreview,
+	openai.GPT4VisionPreview,
+	openai.GPT3Dot5Turbo0301,
+	openai.GPT3Dot5Turbo16K,
+	openai.GPT3Dot5Turbo16K0613,
+	openai.GPT3Dot5TurboInstruct,
+	openai.GPT3Davinci,
+	openai.GPT3Davinci002,
+	openai.GPT3Curie,
+	openai.GPT3Curie002,
+	openai.GPT3Ada,
+	openai.GPT3Ada002,
+	openai.GPT3Babbage,
+	openai.GPT3Babbage002,
+}
+
+// numStr shows zero as empty, so the default is used.
+func numStr(v interface{}) string {
+	s := gconv.String(v)
+	if s == "0" {
+		return ""
+	}
+	return s
+}
+
+// validators of config form fields, also used by SetConfig.
+var configValidators = map[string]func(string) error{
+	baseUrl:         func(s string) error { return config.CheckURL(s, "http", "https") },
+	apiKey:          config.CheckSecretRef,
+	clientSecret:    config.CheckSecretRef,
+	tokenUrl:        func(s string) error { return config.CheckURL(s, "http", "https") },
+	sparkApiKey:     config.CheckSecretRef,
+	sparkApiSecrete: config.CheckSecretRef,
+	proxy:           config.CheckProxy,
+	ctxLen:          func(s string) error { return config.CheckInt(s, config.ContextLenRange) },
+	limit:           func(s string) error { return config.CheckInt(s, config.UintRange) },
+	maxTokens: func(s string) error {
+		return config.CheckInt(s, config.MaxTokensRange)
+	},
+	temperature: func(s string) error {
+		return config.CheckFloat(s, config.OpenAITemperatureRange)
+	},
+	sparkMaxTokens: func(s string) error {
+		return config.CheckInt(s, config.SparkMaxTokensRange)
+	},
+	sparkTemperature: func(s string) error {
+		return config.CheckFloat(s, config.SparkTemperatureRange)
+	},
+	sparkTopK:       func(s string) error { return config.CheckInt(s, config.SparkTopKRange) },
+	sparkTimeout:    func(s string) error { return config.CheckInt(s, config.TimeoutRange) },
+	claudeBaseUrl:   func(s string) error { return config.CheckURL(s, "http", "https") },
+	claudeApiKey:    config.CheckSecretRef,
+	claudeProxy:     config.CheckProxy,
+	claudeMaxTokens: func(s string) error { return config.CheckInt(s, config.ClaudeMaxTokensRange) },
+	claudeTemperature: func(s string) error {
+		return config.CheckFloat(s, config.ClaudeTemperatureRange)
+	},
+	geminiBaseUrl:   func(s string) error { return config.CheckURL(s, "http", "https") },
+	geminiApiKey:    config.CheckSecretRef,
+	geminiProxy:     config.CheckProxy,
+	geminiMaxTokens: func(s string) error { return config.CheckInt(s, config.GeminiMaxTokensRange) },
+	geminiTemperature: func(s string) error {
+		return config.CheckFloat(s, config.GeminiTemperatureRange)
+	},
+	ollamaBaseUrl: func(s string) error { return config.CheckURL(s, "http", "https") },
+	ollamaNumCtx:  func(s string) error { return config.CheckInt(s, config.OllamaNumCtxRange) },
+	ollamaTemperature: func(s string) error {
+		return config.CheckFloat(s, config.OllamaTemperatureRange)
+	},
+	qwenBaseUrl:      func(s string) error { return config.CheckURL(s, "http", "https") },
+	qwenApiKey:       config.CheckSecretRef,
+	qwenMaxTokens:    func(s string) error { return config.CheckInt(s, config.QwenMaxTokensRange) },
+	qwenTemperature:  func(s string) error { return config.CheckFloat(s, config.QwenTemperatureRange) },
+	ernieBaseUrl:     func(s string) error { return config.CheckURL(s, "http", "https") },
+	ernieApiKey:      config.CheckSecretRef,
+	ernieSecretKey:   config.CheckSecretRef,
+	ernieMaxTokens:   func(s string) error { return config.CheckInt(s, config.ErnieMaxTokensRange) },
+	ernieTemperature: func(s string) error { return config.CheckFloat(s, config.ErnieTemperatureRange) },
+	glmBaseUrl:       func(s string) error { return config.CheckURL(s, "http", "https") },
+	glmApiKey:        config.CheckSecretRef,
+	glmMaxTokens:     func(s string) error { return config.CheckInt(s, config.GLMMaxTokensRange) },
+	glmTemperature:   func(s string) error { return config.CheckFloat(s, config.GLMTemperatureRange) },
+	routerRoutes:     checkRoutes,
+	routerAttempts:   func(s string) error { return config.CheckInt(s, config.RouterAttemptsRange) },
+	routerBackoff:    func(s string) error { return config.CheckFloat(s, config.RouterBackoffRange) },
+	routerMaxBackoff: func(s string) error { return config.CheckFloat(s, config.RouterBackoffRange) },
+	uiInputMaxHeight: func(s string) error { return config.CheckInt(s, config.InputMaxHeightRange) },
+}
+
+// formatRoutes shows routes as "ChatGPT, Claude@work", api keys are edited in the config file.
+func formatRoutes(routes []config.RouteConf) string {
+	items := []string{}
+	for _, r := range routes {
+		if r.Profile != "" {
+			items = append(items, r.Bot+"@"+r.Profile)
+		} else {
+			items = append(items, r.Bot)
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// parseRoutes parses routes like "ChatGPT, Claude@work", api keys of the same routes in old are kept.
+func parseRoutes(value string, old []config.RouteConf) (routes []config.RouteConf) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		bot, profile, _ := strings.Cut(item, "@")
+		r := config.RouteConf{Bot: strings.TrimSpace(bot), Profile: strings.TrimSpace(profile)}
+		if b := GetBotBackend(r.Bot); b != nil {
+			r.Bot = b.Name
+		}
+		for _, o := range old {
+			if strings.EqualFold(o.Bot, r.Bot) && o.Profile == r.Profile {
+				r.ApiKeys = o.ApiKeys
+			}
+		}
+		routes = append(routes, r)
+	}
+	return
+}
+
+func checkRoutes(value string) error {
+	for _, r := range parseRoutes(value, nil) {
+		if b := GetBotBackend(r.Bot); b == nil || b.Name == cvsation.BotRouter {
+			return fmt.Errorf("unknown bot %q in routes", r.Bot)
+		}
+	}
+	return nil
+}
+
+// ValidateConfigValues checks values from the config form.
+func ValidateConfigValues(values map[string]string) error {
+	names := []string{}
+	for name := range configValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errList := []error{}
+	for _, name := range names {
+		if err := configValidators[name](values[name]); err != nil {
+			errList = append(errList, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errList...)
+}
+
+func GetGoGPTConfigModel(prompt *gpt.GPTPrompt, conf *config.Config, keys *KeyMap) ExtraModel {
+	mi := NewConfigFormModel(keys)
+	// ChatGPT
+	mi.AddSecret(apiKey, "ChatGPT auth token, or env:NAME, cmd:COMMAND, vault:NAME", conf.OpenAI.ApiKey, configValidators[apiKey])
+	mi.AddInput(proxy, "ChatGPT local proxy, http, https or socks5", conf.OpenAI.Proxy, configValidators[proxy])
+	mi.AddInput(ctxLen, "ChatGPT Conversation context length, default 6", numStr(conf.OpenAI.ContextLen), configValidators[ctxLen])
 
 	// Select ChatGPT API type
 	gptApiTypeList := []string{
@@ -100,48 +329,15 @@ func GetGoGPTConfigModel(prompt *gpt.GPTPrompt, conf *config.Config) ExtraModel
 		string(openai.APITypeAzure),
 		string(openai.APITypeAzureAD),
 	}
-	mi.AddOneOption(
-		apiType,
-		gptApiTypeList,
-		input.MWithPlaceholder("ChatGPT Api Type."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(gconv.String(conf.OpenAI.ApiType)),
-		placeHolderStyle,
-	)
+	mi.AddOption(apiType, "ChatGPT Api Type.", gptApiTypeList, string(conf.OpenAI.ApiType))
 
-	// Select ChatGPT Model
-	gptModelList := []string{
-		openai.GPT3Dot5Turbo0613,
-		openai.GPT3Dot5Turbo,
-		openai.GPT432K0613,
-		openai.GPT4,
-		openai.GPT432K0314,
-		openai.GPT432K,
-		openai.GPT40613,
-		openai.GPT40314,
-		openai.GPT4TurboPreview,
-		openai.GPT4VisionPreview,
-		openai.GPT3Dot5Turbo0301,
-		openai.GPT3Dot5Turbo16K,
-		openai.GPT3Dot5Turbo16K0613,
-		openai.GPT3Dot5TurboInstruct,
-		openai.GPT3Davinci,
-		openai.GPT3Davinci002,
-		openai.GPT3Curie,
-		openai.GPT3Curie002,
-		openai.GPT3Ada,
-		openai.GPT3Ada002,
-		openai.GPT3Babbage,
-		openai.GPT3Babbage002,
-	}
-	mi.AddOneOption(
-		gptModel,
-		gptModelList,
-		input.MWithPlaceholder("ChatGPT Model."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.OpenAI.Model),
-		placeHolderStyle,
-	)
+	// Select ChatGPT Model, or enter one served by the base url.
+	models := gpt.MergeModels(gpt.CachedModels(conf), GPTModelList)
+	mi.AddOption(gptModel, "ChatGPT Model.", models, conf.OpenAI.Model).FreeText = true
+	mi.AddInitCmd(func() tea.Msg {
+		discovered, _ := gpt.DiscoverModels(conf, false)
+		return FormOptions{Name: gptModel, Options: gpt.MergeModels(discovered, GPTModelList)}
+	})
 
 	// Select ChatGPT Prompt
 	gptPromptList := []gutils.IComparable{}
@@ -154,76 +350,31 @@ func GetGoGPTConfigModel(prompt *gpt.GPTPrompt, conf *config.Config) ExtraModel
 		pStr := p.(PromptString)
 		pList = append(pList, string(pStr))
 	}
-
-	mi.AddOneOption(
-		gptPrompt,
-		pList,
-		input.MWithPlaceholder("gpt_prompt"),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(prompt.GetTitleByPrompt(conf.OpenAI.PromptStr)),
-		placeHolderStyle,
-	)
+	mi.AddOption(gptPrompt, "gpt_prompt", pList, prompt.GetTitleByPrompt(conf.OpenAI.PromptStr))
 	// Enter you own ChatGPT Prompt
-	mi.AddOneInput(
-		gptPromptValue,
-		input.MWithPlaceholder("Enter your own chatGPT prompt info instead of a selection from above."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.OpenAI.PromptStr),
-		placeHolderStyle,
-	)
+	mi.AddInput(gptPromptValue, "Enter your own chatGPT prompt info instead of a selection from above.", conf.OpenAI.PromptStr, nil)
+
 	// Some configs
-	mi.AddOneInput(
-		limit,
-		input.MWithPlaceholder("ChatGPT max empty message limit. Int."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(gconv.String(conf.OpenAI.EmptyMessagesLimit)),
-		placeHolderStyle,
-	)
-	mi.AddOneInput(
-		maxTokens,
-		input.MWithPlaceholder("ChatGPT max tokens. Int."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(gconv.String(conf.OpenAI.MaxTokens)),
-		placeHolderStyle,
-	)
-	mi.AddOneInput(
+	mi.AddInput(limit, "ChatGPT max empty message limit. Int.", numStr(conf.OpenAI.EmptyMessagesLimit), configValidators[limit])
+	mi.AddInput(maxTokens, "ChatGPT max tokens. Int, default 1024.", numStr(conf.OpenAI.MaxTokens), configValidators[maxTokens])
+	mi.AddInput(
 		temperature,
-		input.MWithPlaceholder("ChatGPT temperautue. Float."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(gconv.String(conf.OpenAI.Temperature)),
-		placeHolderStyle,
+		fmt.Sprintf("ChatGPT temperature. Float in %s.", config.OpenAITemperatureRange),
+		numStr(conf.OpenAI.Temperature),
+		configValidators[temperature],
 	)
 
 	// Custom baseUrl
-	mi.AddOneInput(
-		baseUrl,
-		input.MWithPlaceholder("ChatGPT baseUrl, defaul:https://api.openai.com/v1"),
-		input.MWithWidth(150),
-		input.MWithDefaultValue(conf.OpenAI.BaseUrl),
-		placeHolderStyle,
-	)
+	mi.AddInput(baseUrl, "ChatGPT baseUrl, defaul:https://api.openai.com/v1", conf.OpenAI.BaseUrl, configValidators[baseUrl])
 	// For AzureGPT
-	mi.AddOneInput(
-		apiVersion,
-		input.MWithPlaceholder("ChatGPT API version."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.OpenAI.ApiVersion),
-		placeHolderStyle,
-	)
-	mi.AddOneInput(
-		orgID,
-		input.MWithPlaceholder("Organization ID."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.OpenAI.OrgID),
-		placeHolderStyle,
-	)
-	mi.AddOneInput(
-		engine,
-		input.MWithPlaceholder("GPT engine."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.OpenAI.Engine),
-		placeHolderStyle,
-	)
+	mi.AddInput(apiVersion, "ChatGPT API version.", conf.OpenAI.ApiVersion, nil)
+	mi.AddInput(orgID, "Organization ID.", conf.OpenAI.OrgID, nil)
+	mi.AddInput(engine, "Azure deployment name.", conf.OpenAI.Engine, nil)
+	// For Azure AD client credentials
+	mi.AddInput(tenantID, "Azure AD tenant ID.", conf.OpenAI.TenantID, nil)
+	mi.AddInput(clientID, "Azure AD client ID.", conf.OpenAI.ClientID, nil)
+	mi.AddSecret(clientSecret, "Azure AD client secret, or env:NAME, cmd:COMMAND, vault:NAME", conf.OpenAI.ClientSecret, configValidators[clientSecret])
+	mi.AddInput(tokenUrl, "Azure AD token url, default: "+gpt.AzureADTokenUrl, conf.OpenAI.TokenUrl, configValidators[tokenUrl])
 
 	// Spark
 	sparkApiVersionList := []string{
@@ -231,113 +382,186 @@ func GetGoGPTConfigModel(prompt *gpt.GPTPrompt, conf *config.Config) ExtraModel
 		string(config.SparkAPIV2),
 		string(config.SparkAPIV3),
 	}
-	mi.AddOneOption(
-		sparkApiVersion,
-		sparkApiVersionList,
-		input.MWithPlaceholder("spark api version"),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(string(conf.Spark.APIVersion)),
-		placeHolderStyle,
-	)
-
-	mi.AddOneInput(
-		sparkAppID,
-		input.MWithPlaceholder("spark app id."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.Spark.APPID),
-		placeHolderStyle,
+	mi.AddOption(sparkApiVersion, "spark api version", sparkApiVersionList, string(conf.Spark.APIVersion))
+	mi.AddInput(sparkAppID, "spark app id.", conf.Spark.APPID, nil)
+	mi.AddSecret(sparkApiKey, "spark api key, or env:NAME, cmd:COMMAND, vault:NAME", conf.Spark.APPKey, configValidators[sparkApiKey])
+	mi.AddSecret(sparkApiSecrete, "spark api secrete, or env:NAME, cmd:COMMAND, vault:NAME", conf.Spark.APPSecrete, configValidators[sparkApiSecrete])
+	mi.AddInput(sparkMaxTokens, "spark max tokens. Int, default 2048.", numStr(conf.Spark.MaxTokens), configValidators[sparkMaxTokens])
+	mi.AddInput(
+		sparkTemperature,
+		fmt.Sprintf("spark temperature. Float in %s, default 0.5.", config.SparkTemperatureRange),
+		numStr(conf.Spark.Temperature),
+		configValidators[sparkTemperature],
 	)
-	mi.AddOneInput(
-		sparkApiKey,
-		input.MWithPlaceholder("spark api key."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.Spark.APPKey),
-		placeHolderStyle,
+	mi.AddInput(
+		sparkTopK,
+		fmt.Sprintf("spark top_k. Int in %s, default 4.", config.SparkTopKRange),
+		numStr(conf.Spark.TopK),
+		configValidators[sparkTopK],
 	)
-	mi.AddOneInput(
-		sparkApiSecrete,
-		input.MWithPlaceholder("spark api secrete."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.Spark.APPSecrete),
-		placeHolderStyle,
+	mi.AddInput(sparkTimeout, "spark timeout. Seconds, default 60.", numStr(conf.Spark.Timeout), configValidators[sparkTimeout])
+	mi.AddInput(sparkUID, "spark user id.", conf.Spark.UID, nil)
+	mi.AddInput(sparkChatID, "spark chat id.", conf.Spark.ChatID, nil)
+
+	// Claude
+	mi.AddSecret(claudeApiKey, "Claude api key, or env:NAME, cmd:COMMAND, vault:NAME", conf.Anthropic.ApiKey, configValidators[claudeApiKey])
+	mi.AddOption(claudeModel, "Claude model.", anthropic.ModelList, conf.Anthropic.Model).FreeText = true
+	mi.AddInput(
+		claudeMaxTokens,
+		fmt.Sprintf("Claude max tokens. Int in %s, default %d.", config.ClaudeMaxTokensRange, anthropic.DefaultMaxTokens),
+		numStr(conf.Anthropic.MaxTokens),
+		configValidators[claudeMaxTokens],
 	)
-	mi.AddOneInput(
-		sparkMaxTokens,
-		input.MWithPlaceholder("spark max tokens. Int."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(gconv.String(conf.Spark.MaxTokens)),
-		placeHolderStyle,
+	mi.AddInput(
+		claudeTemperature,
+		fmt.Sprintf("Claude temperature. Float in %s.", config.ClaudeTemperatureRange),
+		numStr(conf.Anthropic.Temperature),
+		configValidators[claudeTemperature],
 	)
-	mi.AddOneInput(
-		sparkTemperature,
-		input.MWithPlaceholder("spark temperature. Float."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(gconv.String(conf.Spark.Temperature)),
-		placeHolderStyle,
+	mi.AddInput(claudeBaseUrl, fmt.Sprintf("Claude baseUrl, default:%s", anthropic.DefaultBaseUrl), conf.Anthropic.BaseUrl, configValidators[claudeBaseUrl])
+	mi.AddInput(claudeApiVersion, fmt.Sprintf("Claude anthropic-version header, default %s.", anthropic.DefaultApiVersion), conf.Anthropic.ApiVersion, nil)
+	mi.AddInput(claudeProxy, "Claude local proxy, http, https or socks5", conf.Anthropic.Proxy, configValidators[claudeProxy])
+
+	// Gemini
+	mi.AddSecret(geminiApiKey, "Gemini api key, or env:NAME, cmd:COMMAND, vault:NAME", conf.Gemini.ApiKey, configValidators[geminiApiKey])
+	mi.AddOption(geminiModel, "Gemini model.", gemini.ModelList, conf.Gemini.Model).FreeText = true
+	mi.AddInput(
+		geminiMaxTokens,
+		fmt.Sprintf("Gemini max output tokens. Int in %s, default of the model.", config.GeminiMaxTokensRange),
+		numStr(conf.Gemini.MaxTokens),
+		configValidators[geminiMaxTokens],
 	)
-	mi.AddOneInput(
-		sparkTopK,
-		input.MWithPlaceholder("spark top_k. Int."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(gconv.String(conf.Spark.TopK)),
-		placeHolderStyle,
+	mi.AddInput(
+		geminiTemperature,
+		fmt.Sprintf("Gemini temperature. Float in %s, default of the model.", config.GeminiTemperatureRange),
+		numStr(conf.Gemini.Temperature),
+		configValidators[geminiTemperature],
 	)
-	mi.AddOneInput(
-		sparkTimeout,
-		input.MWithPlaceholder("spark timeout. Seconds."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(gconv.String(conf.Spark.Timeout)),
-		placeHolderStyle,
+	mi.AddInput(geminiBaseUrl, fmt.Sprintf("Gemini baseUrl, default:%s", gemini.DefaultBaseUrl), conf.Gemini.BaseUrl, configValidators[geminiBaseUrl])
+	mi.AddInput(geminiApiVersion, fmt.Sprintf("Gemini api version, default %s.", gemini.DefaultApiVersion), conf.Gemini.ApiVersion, nil)
+	mi.AddInput(geminiProxy, "Gemini local proxy, http, https or socks5", conf.Gemini.Proxy, configValidators[geminiProxy])
+
+	// Ollama, select a pulled model, pull new ones from the Models tab.
+	mi.AddOption(ollamaModel, "Ollama model.", nil, conf.Ollama.Model).FreeText = true
+	mi.AddInitCmd(func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), ModelsListTimeout)
+		defer cancel()
+		models, _ := ollama.ListModels(ctx, conf)
+		names := []string{}
+		for _, m := range models {
+			names = append(names, m.Name)
+		}
+		return FormOptions{Name: ollamaModel, Options: names}
+	})
+	mi.AddInput(ollamaBaseUrl, fmt.Sprintf("Ollama baseUrl, default:%s", ollama.DefaultBaseUrl), conf.Ollama.BaseUrl, configValidators[ollamaBaseUrl])
+	mi.AddInput(
+		ollamaNumCtx,
+		fmt.Sprintf("Ollama num_ctx, context window. Int, default %d.", ollama.DefaultNumCtx),
+		numStr(conf.Ollama.NumCtx),
+		configValidators[ollamaNumCtx],
 	)
-	mi.AddOneInput(
-		sparkUID,
-		input.MWithPlaceholder("spark user id."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.Spark.UID),
-		placeHolderStyle,
+	mi.AddInput(
+		ollamaTemperature,
+		fmt.Sprintf("Ollama temperature. Float in %s, default of the model.", config.OllamaTemperatureRange),
+		numStr(conf.Ollama.Temperature),
+		configValidators[ollamaTemperature],
 	)
-	mi.AddOneInput(
-		sparkChatID,
-		input.MWithPlaceholder("spark chat id."),
-		input.MWithWidth(100),
-		input.MWithDefaultValue(conf.Spark.ChatID),
-		placeHolderStyle,
+	mi.AddInput(ollamaKeepAlive, "Ollama keep_alive, like 5m or -1 to keep the model loaded.", conf.Ollama.KeepAlive, nil)
+
+	// Qwen
+	mi.AddSecret(qwenApiKey, "DashScope api key, or env:NAME, cmd:COMMAND, vault:NAME", conf.Qwen.ApiKey, configValidators[qwenApiKey])
+	mi.AddOption(qwenModel, "Qwen model.", dashscope.ModelList, conf.Qwen.Model).FreeText = true
+	mi.AddInput(qwenMaxTokens, fmt.Sprintf("Qwen max tokens. Int in %s.", config.QwenMaxTokensRange), numStr(conf.Qwen.MaxTokens), configValidators[qwenMaxTokens])
+	mi.AddInput(qwenTemperature, fmt.Sprintf("Qwen temperature. Float in %s.", config.QwenTemperatureRange), numStr(conf.Qwen.Temperature), configValidators[qwenTemperature])
+	mi.AddInput(qwenBaseUrl, fmt.Sprintf("DashScope baseUrl, default:%s", dashscope.DefaultBaseUrl), conf.Qwen.BaseUrl, configValidators[qwenBaseUrl])
+
+	// ERNIE
+	mi.AddSecret(ernieApiKey, "Qianfan api key, or env:NAME, cmd:COMMAND, vault:NAME", conf.Ernie.ApiKey, configValidators[ernieApiKey])
+	mi.AddSecret(ernieSecretKey, "Qianfan secret key, or env:NAME, cmd:COMMAND, vault:NAME", conf.Ernie.SecretKey, configValidators[ernieSecretKey])
+	mi.AddOption(ernieModel, "ERNIE model endpoint.", qianfan.ModelList, conf.Ernie.Model).FreeText = true
+	mi.AddInput(ernieMaxTokens, fmt.Sprintf("ERNIE max output tokens. Int in %s.", config.ErnieMaxTokensRange), numStr(conf.Ernie.MaxTokens), configValidators[ernieMaxTokens])
+	mi.AddInput(ernieTemperature, fmt.Sprintf("ERNIE temperature. Float in %s.", config.ErnieTemperatureRange), numStr(conf.Ernie.Temperature), configValidators[ernieTemperature])
+	mi.AddInput(ernieBaseUrl, fmt.Sprintf("Qianfan baseUrl, default:%s", qianfan.DefaultBaseUrl), conf.Ernie.BaseUrl, configValidators[ernieBaseUrl])
+
+	// GLM
+	mi.AddSecret(glmApiKey, "Zhipu api key {id}.{secret}, or env:NAME, cmd:COMMAND, vault:NAME", conf.GLM.ApiKey, configValidators[glmApiKey])
+	mi.AddOption(glmModel, "GLM model.", zhipu.ModelList, conf.GLM.Model).FreeText = true
+	mi.AddInput(glmMaxTokens, fmt.Sprintf("GLM max tokens. Int in %s.", config.GLMMaxTokensRange), numStr(conf.GLM.MaxTokens), configValidators[glmMaxTokens])
+	mi.AddInput(glmTemperature, fmt.Sprintf("GLM temperature. Float in %s.", config.GLMTemperatureRange), numStr(conf.GLM.Temperature), configValidators[glmTemperature])
+	mi.AddInput(glmBaseUrl, fmt.Sprintf("Zhipu baseUrl, default:%s", zhipu.DefaultBaseUrl), conf.GLM.BaseUrl, configValidators[glmBaseUrl])
+
+	// Router
+	mi.AddInput(routerRoutes, "Router routes in order, like: ChatGPT, Claude@work", formatRoutes(conf.Router.Routes), configValidators[routerRoutes])
+	mi.AddInput(routerAttempts, fmt.Sprintf("Router rounds over the routes. Int in %s, default 3.", config.RouterAttemptsRange), numStr(conf.Router.Attempts), configValidators[routerAttempts])
+	mi.AddInput(routerBackoff, fmt.Sprintf("Router backoff in seconds, doubled each round. Float in %s, default 1.", config.RouterBackoffRange), numStr(conf.Router.Backoff), configValidators[routerBackoff])
+	mi.AddInput(routerMaxBackoff, fmt.Sprintf("Router longest backoff in seconds. Float in %s, default 30.", config.RouterBackoffRange), numStr(conf.Router.MaxBackoff), configValidators[routerMaxBackoff])
+
+	// TUI
+	submitKeyList := []string{
+		config.DefaultSubmitKey,
+		"alt+enter",
+		"ctrl+j",
+	}
+	mi.AddOption(uiSubmitKey, "key to send a message, enter by default.", submitKeyList, conf.UI.SubmitKey)
+	mi.AddInput(
+		uiInputMaxHeight,
+		fmt.Sprintf("max lines of the input area. Int, default %d.", config.DefaultInputMaxHeight),
+		numStr(conf.UI.InputMaxHeight),
+		configValidators[uiInputMaxHeight],
 	)
+	mi.AddOption(uiTheme, "theme, takes effect after restart.", theme.Names(conf.GetWorkDir()), conf.UI.Theme)
+
+	addConnCheckActions(mi, conf)
+	mi.AddAction("Refresh models", func(values map[string]string) tea.Cmd {
+		cnf := conf.Copy()
+		applyConfigValues(cnf, values)
+		return func() tea.Msg {
+			discovered, err := gpt.DiscoverModels(cnf, true)
+			r := FormOptions{Name: gptModel, Options: gpt.MergeModels(discovered, GPTModelList)}
+			r.Notice = fmt.Sprintf("%d models from %s", len(discovered), gpt.BaseUrl(cnf))
+			if err != nil {
+				r.Notice = errorStyle.Render(fmt.Sprintf("list models failed: %v", err))
+			}
+			return r
+		}
+	})
 	return mi
 }
 
-func SetConfig(cfg *config.Config, values map[string]string) {
+// SetConfig saves values from the config form, an empty text field unsets the value.
+func SetConfig(cfg *config.Config, values map[string]string) error {
 	if cfg == nil {
-		gprint.PrintError("conf object is nil!")
-		return
+		return fmt.Errorf("conf object is nil")
+	}
+	if err := ValidateConfigValues(values); err != nil {
+		return err
 	}
 	cfg.Reload()
+	applyConfigValues(cfg, values)
+	cfg.OpenAI.PromptMsgUrl = config.PromptUrl
+	cfg.Save()
+	return nil
+}
+
+func applyConfigValues(cfg *config.Config, values map[string]string) {
 	if len(values) > 0 {
 		// ChatGPT
-		if values[baseUrl] != "" {
-			cfg.OpenAI.BaseUrl = values[baseUrl]
-		}
-		if values[apiKey] != "" {
-			cfg.OpenAI.ApiKey = values[apiKey]
-		}
+		cfg.OpenAI.BaseUrl = values[baseUrl]
+		cfg.OpenAI.ApiKey = values[apiKey]
 		if values[gptModel] != "" {
 			cfg.OpenAI.Model = values[gptModel]
 		}
-		if values[proxy] != "" {
-			cfg.OpenAI.Proxy = values[proxy]
-		}
+		cfg.OpenAI.Proxy = values[proxy]
 		if values[apiType] != "" {
 			cfg.OpenAI.ApiType = openai.APIType(values[apiType])
 		}
-		if values[apiVersion] != "" {
-			cfg.OpenAI.ApiVersion = values[apiVersion]
-		}
-		if values[orgID] != "" {
-			cfg.OpenAI.OrgID = values[orgID]
-		}
-		if values[engine] != "" {
-			cfg.OpenAI.Engine = values[engine]
-		}
+		cfg.OpenAI.ApiVersion = values[apiVersion]
+		cfg.OpenAI.OrgID = values[orgID]
+		cfg.OpenAI.Engine = values[engine]
+		cfg.OpenAI.TenantID = values[tenantID]
+		cfg.OpenAI.ClientID = values[clientID]
+		cfg.OpenAI.ClientSecret = values[clientSecret]
+		cfg.OpenAI.TokenUrl = values[tokenUrl]
 		if values[gptPrompt] != "" {
 			cfg.OpenAI.PromptStr = values[gptPrompt]
 		}
@@ -359,56 +583,120 @@ func SetConfig(cfg *config.Config, values map[string]string) {
 		cfg.OpenAI.ContextLen = cLen
 		cfg.OpenAI.Temperature = gconv.Float32(values[temperature])
 
-		// Spark
+		// Spark, zero values use the defaults of Spark.
 		if values[sparkApiVersion] != "" {
 			cfg.Spark.APIVersion = config.SparkAPIVersion(values[sparkApiVersion])
 		}
-		if values[sparkAppID] != "" {
-			cfg.Spark.APPID = values[sparkAppID]
+		cfg.Spark.APPID = values[sparkAppID]
+		cfg.Spark.UID = values[sparkUID]
+		cfg.Spark.APPKey = values[sparkApiKey]
+		cfg.Spark.APPSecrete = values[sparkApiSecrete]
+		cfg.Spark.MaxTokens = gconv.Int64(values[sparkMaxTokens])
+		cfg.Spark.Temperature = gconv.Float64(values[sparkTemperature])
+		cfg.Spark.TopK = gconv.Int64(values[sparkTopK])
+		cfg.Spark.ChatID = values[sparkChatID]
+		cfg.Spark.Timeout = gconv.Int(values[sparkTimeout])
+
+		// Claude, zero values use the defaults of Claude.
+		cfg.Anthropic.BaseUrl = values[claudeBaseUrl]
+		cfg.Anthropic.ApiKey = values[claudeApiKey]
+		cfg.Anthropic.ApiVersion = values[claudeApiVersion]
+		cfg.Anthropic.Proxy = values[claudeProxy]
+		if values[claudeModel] != "" {
+			cfg.Anthropic.Model = values[claudeModel]
 		}
-		if values[sparkUID] != "" {
-			cfg.Spark.UID = values[sparkUID]
+		cfg.Anthropic.MaxTokens = gconv.Int(values[claudeMaxTokens])
+		cfg.Anthropic.Temperature = gconv.Float64(values[claudeTemperature])
+
+		// Gemini, zero values use the defaults of the model.
+		cfg.Gemini.BaseUrl = values[geminiBaseUrl]
+		cfg.Gemini.ApiKey = values[geminiApiKey]
+		cfg.Gemini.ApiVersion = values[geminiApiVersion]
+		cfg.Gemini.Proxy = values[geminiProxy]
+		if values[geminiModel] != "" {
+			cfg.Gemini.Model = values[geminiModel]
 		}
-		if values[sparkApiKey] != "" {
-			cfg.Spark.APPKey = values[sparkApiKey]
+		cfg.Gemini.MaxTokens = gconv.Int(values[geminiMaxTokens])
+		cfg.Gemini.Temperature = gconv.Float64(values[geminiTemperature])
+
+		// Ollama, zero values use the defaults of the model.
+		cfg.Ollama.BaseUrl = values[ollamaBaseUrl]
+		if values[ollamaModel] != "" {
+			cfg.Ollama.Model = values[ollamaModel]
 		}
-		if values[sparkApiSecrete] != "" {
-			cfg.Spark.APPSecrete = values[sparkApiSecrete]
+		cfg.Ollama.NumCtx = gconv.Int(values[ollamaNumCtx])
+		cfg.Ollama.Temperature = gconv.Float64(values[ollamaTemperature])
+		cfg.Ollama.KeepAlive = values[ollamaKeepAlive]
+
+		// Qwen, ERNIE and GLM, zero values use the defaults of the model.
+		cfg.Qwen.BaseUrl = values[qwenBaseUrl]
+		cfg.Qwen.ApiKey = values[qwenApiKey]
+		if values[qwenModel] != "" {
+			cfg.Qwen.Model = values[qwenModel]
 		}
-		if values[sparkMaxTokens] != "" {
-			cfg.Spark.MaxTokens = gconv.Int64(values[sparkMaxTokens])
+		cfg.Qwen.MaxTokens = gconv.Int(values[qwenMaxTokens])
+		cfg.Qwen.Temperature = gconv.Float64(values[qwenTemperature])
+		cfg.Ernie.BaseUrl = values[ernieBaseUrl]
+		cfg.Ernie.ApiKey = values[ernieApiKey]
+		cfg.Ernie.SecretKey = values[ernieSecretKey]
+		if values[ernieModel] != "" {
+			cfg.Ernie.Model = values[ernieModel]
 		}
-		if values[sparkTemperature] != "" {
-			cfg.Spark.Temperature = gconv.Float64(values[sparkTemperature])
+		cfg.Ernie.MaxTokens = gconv.Int(values[ernieMaxTokens])
+		cfg.Ernie.Temperature = gconv.Float64(values[ernieTemperature])
+		cfg.GLM.BaseUrl = values[glmBaseUrl]
+		cfg.GLM.ApiKey = values[glmApiKey]
+		if values[glmModel] != "" {
+			cfg.GLM.Model = values[glmModel]
 		}
-		if values[sparkTopK] != "" {
-			cfg.Spark.TopK = gconv.Int64(values[sparkTopK])
+		cfg.GLM.MaxTokens = gconv.Int(values[glmMaxTokens])
+		cfg.GLM.Temperature = gconv.Float64(values[glmTemperature])
+
+		// Router
+		cfg.Router.Routes = parseRoutes(values[routerRoutes], cfg.Router.Routes)
+		cfg.Router.Attempts = gconv.Int(values[routerAttempts])
+		cfg.Router.Backoff = gconv.Float64(values[routerBackoff])
+		cfg.Router.MaxBackoff = gconv.Float64(values[routerMaxBackoff])
+
+		// TUI
+		if values[uiSubmitKey] != "" {
+			cfg.UI.SubmitKey = values[uiSubmitKey]
 		}
-		if values[sparkChatID] != "" {
-			cfg.Spark.ChatID = values[sparkChatID]
+		cfg.UI.InputMaxHeight = gconv.Int(values[uiInputMaxHeight])
+		if cfg.UI.InputMaxHeight == 0 {
+			cfg.UI.InputMaxHeight = config.DefaultInputMaxHeight
 		}
-		if values[sparkTimeout] != "" {
-			cfg.Spark.Timeout = gconv.Int(values[sparkTimeout])
+		if values[uiTheme] != "" {
+			cfg.UI.Theme = values[uiTheme]
 		}
 	}
-	cfg.OpenAI.PromptMsgUrl = config.PromptUrl
-	cfg.Save()
 }
 
-func GetDefaultConfig() (conf *config.Config) {
-	homeDir, _ := os.UserHomeDir()
-	workDir := filepath.Join(homeDir, ".gogpt")
-	confPath := filepath.Join(workDir, config.ConfigFileName)
-	cfg := config.NewConf(workDir)
+// GetDefaultConfig loads the config with the work dir and settings from env and fs, fs may be nil.
+// The config form is shown on the first run, unless settings come from env or flags.
+func GetDefaultConfig(fs *flag.FlagSet) (conf *config.Config) {
+	dirs := GetDirs(fs)
+	confPath := filepath.Join(dirs.Config, config.ConfigFileName)
+	cfg := config.NewConf(dirs)
+	if fs != nil {
+		if err := cfg.ApplyFlags(fs); err != nil {
+			gprint.PrintError("%+v", err)
+			os.Exit(1)
+		}
+	}
 	prompt := gpt.NewGPTPrompt(cfg)
-	if ok, _ := gutils.PathIsExist(confPath); !ok {
-		m := GetGoGPTConfigModel(prompt, cfg)
+	interactive := term.IsTerminal(int(os.Stdin.Fd())) && !cfg.HasOverrides()
+	if ok, _ := gutils.PathIsExist(confPath); !ok && interactive {
+		keys, _ := NewKeyMap(cfg) // conflicts are reported when the TUI starts
+		m := GetGoGPTConfigModel(prompt, cfg, keys)
 		pgm := tea.NewProgram(m)
 		if _, err := pgm.Run(); err != nil {
 			gprint.PrintError("%+v", err)
 		}
 		cfg.OpenAI.Model = openai.GPT3Dot5Turbo
-		SetConfig(cfg, m.Values())
+		if err := SetConfig(cfg, m.Values()); err != nil {
+			gprint.PrintError("%+v", err)
+		}
 	}
 	return cfg
 }
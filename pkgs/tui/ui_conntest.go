@@ -0,0 +1,71 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+/*
+Connection test of backends, from the Configuration tab.
+*/
+const (
+	ConnCheckTimeout = 20 * time.Second
+)
+
+type ConnCheckResult struct {
+	Bot      string
+	Endpoint string
+	Proxy    string
+	Latency  time.Duration
+	Err      error
+}
+
+func (that ConnCheckResult) String() string {
+	endpoint := that.Endpoint
+	if that.Proxy != "" {
+		endpoint += " via " + that.Proxy
+	}
+	latency := that.Latency.Round(time.Millisecond)
+	if that.Err != nil {
+		return errorStyle.Render(fmt.Sprintf("%s failed in %s, %s: %v", that.Bot, latency, endpoint, that.Err))
+	}
+	return fmt.Sprintf("%s ok in %s, %s", that.Bot, latency, endpoint)
+}
+
+// CheckConnection sends a minimal request to the backend of bot.
+func CheckConnection(cnf *config.Config, bot string) (r ConnCheckResult) {
+	r.Bot = bot
+	ctx, cancel := context.WithTimeout(context.Background(), ConnCheckTimeout)
+	defer cancel()
+	p, ok := NewBot(cnf, bot).(Pinger)
+	if !ok {
+		r.Err = fmt.Errorf("connection test is not supported")
+		return
+	}
+	r.Endpoint = p.Endpoint()
+	if px, ok := p.(Proxied); ok {
+		r.Proxy = px.ProxyUrl()
+	}
+	start := time.Now()
+	r.Err = p.Ping(ctx)
+	r.Latency = time.Since(start)
+	return
+}
+
+// addConnCheckActions adds a test button for each backend, using unsaved values of the form.
+func addConnCheckActions(form *ConfigFormModel, conf *config.Config) {
+	for _, bot := range BotNames() {
+		bot := bot
+		form.AddAction("Test "+bot, func(values map[string]string) tea.Cmd {
+			cnf := conf.Copy()
+			applyConfigValues(cnf, values)
+			return func() tea.Msg {
+				return FormNotice(CheckConnection(cnf, bot).String())
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"unicode"
 
 	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
@@ -16,19 +17,16 @@ import (
 	"github.com/gvcgo/gogpt/pkgs/config"
 	cvsation "github.com/gvcgo/gogpt/pkgs/conversation"
 	"github.com/gvcgo/gogpt/pkgs/gpt"
-	"github.com/gvcgo/gogpt/pkgs/iflytek"
 	"github.com/muesli/reflow/wordwrap"
 	"github.com/muesli/reflow/wrap"
-	openai "github.com/sashabaranov/go-openai"
 )
 
 type AnswerContinue string
 
-type Bot interface {
-	SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error)
-	RecvMsg() (m string, err error)
-	Close()
-	GetTokens() int64
+// AnswerStarted is the first message of an answer, sent in the background.
+type AnswerStarted struct {
+	Answer string
+	Err    error
 }
 
 type ConversationModel struct {
@@ -39,68 +37,168 @@ type ConversationModel struct {
 	CNF          *config.Config
 	WindowHeight int
 	WindowWidth  int
-	GPT          *gpt.GPT
-	Spark        *iflytek.Spark
+	Bots         map[string]Bot // clients by backend name, created on demand.
 	Conversation *cvsation.Conversation
 	Receiving    bool
 	Error        error
+	Notice       string
+	CodeSaver    *CodeSaveModel
+	History      *cvsation.InputHistory
+	EditIndex    int // index of the QA being edited, -1 for none
+	Commands     *CommandRegistry
+	Completions  []string // candidates of slash command completion
+	Prompt       *gpt.GPTPrompt
+	Keys         *KeyMap
+	Compare      *CompareModel          // answers of several bots side by side, nil when off
+	botConfs     map[string]interface{} // config of the clients when created
 }
 
-func NewConversationModel(cnf *config.Config) (cvm *ConversationModel) {
+func NewConversationModel(cnf *config.Config, keys *KeyMap) (cvm *ConversationModel) {
 	cvm = &ConversationModel{
 		CNF:          cnf,
-		GPT:          gpt.NewGPT(cnf),
-		Spark:        iflytek.NewSpark(cnf),
 		Conversation: cvsation.NewConversation(cnf),
+		EditIndex:    -1,
+		Commands:     NewCommandRegistry(),
+		Keys:         keys,
+		Bots:         map[string]Bot{},
+		botConfs:     map[string]interface{}{},
 	}
+	cvm.History = cvsation.NewInputHistory(cvm.Conversation.SessionFile())
+	RegisterDefaultCommands(cvm.Commands)
 	cvm.Conversation.SetBotType(cvsation.BotGPT) // ChatGPT by default
+	if b := GetBotBackend(cnf.ProfileBot()); b != nil {
+		cvm.Conversation.SetBotType(b.Name)
+	}
 	cvm.Spinner = spinner.New(spinner.WithSpinner(spinner.Meter))
 	cvm.TextArea = textarea.New()
 	cvm.TextArea.Cursor.SetMode(cursor.CursorBlink)
-	cvm.TextArea.Placeholder = "enter you message"
+	cvm.TextArea.Placeholder = fmt.Sprintf("enter you message, %s to send, %s to open $EDITOR", keys.Submit.Help().Key, keys.Editor.Help().Key)
 	cvm.TextArea.CharLimit = -1
 	cvm.TextArea.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	cvm.TextArea.FocusedStyle.Text = inputTextStyle
+	cvm.TextArea.FocusedStyle.Placeholder = placeholderStyle
+	cvm.TextArea.BlurredStyle.Text = inputTextStyle
+	cvm.TextArea.BlurredStyle.Placeholder = placeholderStyle
 	cvm.TextArea.ShowLineNumbers = false
 	cvm.TextArea.Focus()
+	cvm.TextArea.SetValue(cvm.History.PopDraft()) // draft left by the last exit
 	cvm.TextArea.CursorEnd()
 	cvm.TextArea.SetHeight(2)
+	cvm.TextArea.KeyMap.InsertNewline = keys.Newline
 	cvm.Viewport = viewport.Model{}
 	cvm.R, _ = glamour.NewTermRenderer(
-		glamour.WithEnvironmentConfig(),
+		glamour.WithStylePath(glamourStyle),
 		glamour.WithWordWrap(0),
 	)
 	return
 }
 
+// backend returns the backend of the conversation, ChatGPT for unknown names.
+func (that *ConversationModel) backend() *BotBackend {
+	if b := GetBotBackend(that.Conversation.BotType); b != nil {
+		return b
+	}
+	return BotBackends[0]
+}
+
+// GetBot returns the client of the conversation, created with a copy of the config,
+// so it answers in the background while commands change the config.
 func (that *ConversationModel) GetBot() Bot {
-	switch that.Conversation.BotType {
-	case cvsation.BotSpark:
-		if that.Spark == nil {
-			that.Spark = iflytek.NewSpark(that.CNF)
-		}
-		return that.Spark
-	default:
-		if that.GPT == nil {
-			that.GPT = gpt.NewGPT(that.CNF)
+	b := that.backend()
+	if that.Bots[b.Name] == nil {
+		that.Bots[b.Name] = b.New(that.CNF.Copy())
+		that.botConfs[b.Name] = b.Conf(that.CNF)
+	}
+	return that.Bots[b.Name]
+}
+
+// UseConfig replaces the config read again from the file, clients follow with ReloadClients.
+func (that *ConversationModel) UseConfig(cnf *config.Config) {
+	that.CNF = cnf
+	that.Conversation.CNF = cnf
+	if that.Prompt != nil {
+		that.Prompt.CNF = cnf
+	}
+}
+
+// ReloadClients creates clients again when their config changed.
+// Clients are kept while an answer is streaming, and reloaded before the next question.
+func (that *ConversationModel) ReloadClients() {
+	if that.Receiving {
+		return
+	}
+	for name, bot := range that.Bots {
+		if b := GetBotBackend(name); b != nil && that.botConfs[name] != b.Conf(that.CNF) {
+			bot.Close()
+			delete(that.Bots, name)
 		}
-		return that.GPT
 	}
 }
 
+// SwitchBot switches to the next backend, the router is skipped without routes.
 func (that *ConversationModel) SwitchBot() {
-	if that.Conversation.BotType == cvsation.BotGPT {
-		that.Conversation.SetBotType(cvsation.BotSpark)
-		if that.GPT != nil {
-			that.GPT.Close()
-			that.GPT = nil
-		}
-	} else {
-		that.Conversation.SetBotType(cvsation.BotGPT)
-		if that.Spark != nil {
-			that.Spark.Close()
-			that.Spark = nil
+	next := BotBackends[0]
+	for i, b := range BotBackends {
+		if b.Name == that.Conversation.BotType {
+			next = BotBackends[(i+1)%len(BotBackends)]
 		}
 	}
+	if next.Name == cvsation.BotRouter && len(that.CNF.Router.Routes) == 0 {
+		next = BotBackends[0]
+	}
+	that.UseBot(next.Name)
+}
+
+// UseBot switches to the named backend, the conversation continues on it.
+// The client of the old one is closed.
+func (that *ConversationModel) UseBot(name string) bool {
+	if name == that.Conversation.BotType {
+		return true
+	}
+	if that.Receiving {
+		that.Notice = "wait for the answer before switching bots"
+		return false
+	}
+	if bot := that.Bots[that.Conversation.BotType]; bot != nil {
+		bot.Close()
+		delete(that.Bots, that.Conversation.BotType)
+	}
+	that.Conversation.SetBotType(name)
+	return true
+}
+
+// RenderHint returns the inline help of slash commands.
+func (that *ConversationModel) RenderHint() string {
+	hint := that.Commands.Hint(that.TextArea.Value(), that.Completions)
+	if hint == "" || that.WindowWidth == 0 {
+		return hint
+	}
+	return lipgloss.NewStyle().MaxWidth(that.WindowWidth).Render(hint)
+}
+
+func (that *ConversationModel) hintHeight() int {
+	if hint := that.RenderHint(); hint != "" {
+		return lipgloss.Height(hint)
+	}
+	return 0
+}
+
+// resize grows the input area with its content, and gives the rest to the viewport.
+func (that *ConversationModel) resize() {
+	maxHeight := that.CNF.UI.InputMaxHeight
+	if maxHeight < 2 {
+		maxHeight = config.DefaultInputMaxHeight
+	}
+	height := that.TextArea.LineCount()
+	if height < 2 {
+		height = 2
+	} else if height > maxHeight {
+		height = maxHeight
+	}
+	that.TextArea.SetHeight(height)
+	if that.WindowHeight > 0 {
+		that.Viewport.Height = that.WindowHeight - that.TextArea.Height() - lipgloss.Height(that.RenderFooter()) - that.hintHeight() - lipgloss.Height(lipgloss.NewStyle().Foreground(lipgloss.Color("229")).Render("title\n"))
+	}
 }
 
 func (that *ConversationModel) Init() tea.Cmd {
@@ -117,84 +215,166 @@ func (that *ConversationModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		that.TextArea.SetWidth(that.WindowWidth)
 		that.Viewport.Width = msg.Width - 5
 		that.Viewport.MouseWheelEnabled = true
-		that.Viewport.Height = msg.Height - that.TextArea.Height() - lipgloss.Height(that.RenderFooter()) - lipgloss.Height(lipgloss.NewStyle().Foreground(lipgloss.Color("229")).Render("title\n"))
+		that.resize()
 	case spinner.TickMsg:
 		if that.Receiving {
 			that.Spinner, cmd = that.Spinner.Update(msg)
 			cmds = append(cmds, cmd)
 		}
+	case CodeSaved:
+		that.CodeSaver = nil
+		that.Notice = fmt.Sprintf("saved to %s", string(msg))
+	case CodeCanceled:
+		that.CodeSaver = nil
+	case ConfigChanged:
+		if msg.CNF != nil {
+			that.UseConfig(msg.CNF)
+		}
+		that.ReloadClients()
+		that.Notice = msg.Notice
+	case ModelsDiscovered:
+		if msg.Err != nil {
+			that.Error = msg.Err
+		} else {
+			that.Notice = fmt.Sprintf("%d models, complete them with /model", len(msg.Models))
+		}
+	case EditorFinished:
+		if msg.Err != nil {
+			that.Error = msg.Err
+		} else {
+			that.TextArea.SetValue(msg.Content)
+			that.resize()
+		}
 	case tea.KeyMsg:
-		switch keyPress := msg.String(); keyPress {
-		case "enter":
+		that.Notice = ""
+		if that.CodeSaver != nil {
+			_, cmd = that.CodeSaver.Update(msg)
+			return that, cmd
+		}
+		switch {
+		case key.Matches(msg, that.Keys.Submit):
 			messageStr := that.TextArea.Value()
 			that.TextArea.Reset()
 			that.TextArea.Blur()
-			if messageStr != "" {
-				that.Conversation.AddQuestion(messageStr)
-				msgList := that.Conversation.GetMessages()
-				that.Receiving = true
-				cmds = append(
-					cmds, func() tea.Msg {
-						return that.Spinner.Tick()
-					},
-				)
-				bot := that.GetBot()
-				answerStr, err := bot.SendMsg(msgList)
-
-				if err == io.EOF {
-					that.Receiving = false
+			that.resize()
+			that.Completions = nil
+			if IsCommand(messageStr) {
+				that.History.Add(messageStr)
+				cmds = append(cmds, that.Commands.Run(that, messageStr))
+			} else if messageStr != "" {
+				that.History.Add(messageStr)
+				// "//" sends a literal "/".
+				messageStr = strings.TrimPrefix(messageStr, CommandPrefix)
+				if that.EditIndex >= 0 {
+					// edit and resend, the old turns are kept as a branch.
+					that.Conversation.Branch(that.EditIndex, messageStr)
+					that.EditIndex = -1
 				} else {
-					cmds = append(cmds, func() tea.Msg {
-						var msg AnswerContinue
-						return msg
-					})
-				}
-				that.Conversation.AddTokens(bot.GetTokens())
-
-				that.Conversation.AddAnswer(answerStr, !that.Receiving)
-				if err != nil && err != io.EOF {
-					that.Error = err
-					// clear errored answer, continue to Q&A
-					that.Conversation.ClearCurrentAnswer()
-					that.Receiving = false
+					that.Conversation.AddQuestion(messageStr)
 				}
-				that.Viewport.SetContent(that.RenderQA(*that.Conversation.Current))
-				that.Viewport.GotoBottom()
+				cmds = append(cmds, that.Ask()...)
+			}
+		case key.Matches(msg, that.Keys.Complete):
+			// complete slash commands
+			if IsCommand(that.TextArea.Value()) {
+				var completed string
+				completed, that.Completions = that.Commands.Complete(that, that.TextArea.Value())
+				that.TextArea.SetValue(completed)
+				that.resize()
+			}
+		case key.Matches(msg, that.Keys.Regenerate):
+			// regenerate the last answer
+			if !that.Receiving && that.Conversation.Regenerate() {
+				cmds = append(cmds, that.Ask()...)
+			}
+		case key.Matches(msg, that.Keys.EditQA):
+			// edit the question of the current QA and resend it
+			if that.Receiving {
+				break
+			}
+			if that.EditIndex >= 0 {
+				that.EditIndex = -1
+				that.TextArea.Reset()
+			} else if qa := that.Conversation.GetQAByCursor(); qa.Q != "" && that.Conversation.Cursor < len(that.Conversation.Path()) {
+				that.EditIndex = that.Conversation.Cursor
+				that.TextArea.SetValue(qa.Q)
+			}
+			that.resize()
+		case key.Matches(msg, that.Keys.SwitchBranch):
+			// switch to the next branch of the current QA
+			if !that.Receiving && that.Conversation.SwitchBranch(that.Conversation.Cursor, 1) {
+				that.Viewport.SetContent(that.RenderQA(that.Conversation.GetQAByCursor()))
 			}
-		case "up", "down":
+		case key.Matches(msg, that.Keys.ScrollUp, that.Keys.ScrollDown):
 			if !that.Receiving {
 				that.Viewport, cmd = that.Viewport.Update(msg)
 				cmds = append(cmds, cmd)
 			}
-		case "ctrl+p":
+		case key.Matches(msg, that.Keys.PrevQA):
 			if !that.Receiving {
 				qa := that.Conversation.GetPrevQA()
 				if qa.Q != "" {
 					that.Viewport.SetContent(that.RenderQA(qa))
 				}
 			}
-		case "ctrl+f":
+		case key.Matches(msg, that.Keys.NextQA):
 			if !that.Receiving {
 				qa := that.Conversation.GetNextQA()
 				if qa.Q != "" {
 					that.Viewport.SetContent(that.RenderQA(qa))
 				}
 			}
-		case "ctrl+s":
+		case key.Matches(msg, that.Keys.Save):
 			if !that.Receiving {
-				that.Conversation.Save()
+				if err := that.Conversation.Save(); err != nil {
+					that.Error = err
+				}
 			}
-		case "ctrl+l":
+		case key.Matches(msg, that.Keys.Load):
 			if !that.Receiving {
-				that.Conversation.Load()
+				if err := that.Conversation.Load(); err != nil {
+					that.Error = err
+				}
 			}
-		case "ctrl+d":
+		case key.Matches(msg, that.Keys.ClearContext):
 			// clear conversation context
 			if !that.Receiving {
 				that.Conversation.ClearContext()
 			}
-		case "ctrl+w":
+		case key.Matches(msg, that.Keys.SwitchBot):
 			that.SwitchBot() // switch bot
+		case key.Matches(msg, that.Keys.HistoryPrev):
+			if that.Receiving {
+				break
+			}
+			if ques, ok := that.History.Prev(that.TextArea.Value()); ok {
+				that.TextArea.SetValue(ques)
+				that.resize()
+			}
+		case key.Matches(msg, that.Keys.HistoryNext):
+			if that.Receiving {
+				break
+			}
+			if ques, ok := that.History.Next(); ok {
+				that.TextArea.SetValue(ques)
+				that.resize()
+			}
+		case key.Matches(msg, that.Keys.Editor):
+			// edit the draft in $EDITOR
+			if !that.Receiving {
+				cmds = append(cmds, that.OpenEditor())
+			}
+		case key.Matches(msg, that.Keys.SaveCode):
+			// save a code block from the current answer
+			if !that.Receiving {
+				blocks := cvsation.ExtractCodeBlocks(that.Conversation.GetQAByCursor().A)
+				if len(blocks) == 0 {
+					that.Notice = "no code block found"
+				} else {
+					that.CodeSaver = NewCodeSaveModel(blocks, that.Viewport.Height)
+					cmds = append(cmds, that.CodeSaver.Init())
+				}
+			}
 		default:
 			if !that.TextArea.Focused() && !that.Receiving {
 				cmd = that.TextArea.Focus()
@@ -202,7 +382,13 @@ func (that *ConversationModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			that.TextArea, cmd = that.TextArea.Update(msg)
 			cmds = append(cmds, cmd)
+			that.Completions = nil
+			that.resize()
 		}
+	case AnswerStarted:
+		cmds = append(cmds, that.started(msg)...)
+	case CompareChunk:
+		cmds = append(cmds, that.compared(msg)...)
 	case AnswerContinue:
 		bot := that.GetBot()
 		answerStr, err := bot.RecvMsg()
@@ -222,8 +408,7 @@ func (that *ConversationModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		if err != nil && err != io.EOF {
 			that.Error = err
-			// clear errored answer, continue to Q&A
-			that.Conversation.ClearCurrentAnswer()
+			that.dropQuestion()
 			that.Receiving = false
 		}
 		if that.Conversation.Current != nil {
@@ -234,6 +419,83 @@ func (that *ConversationModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return that, tea.Batch(cmds...)
 }
 
+// AttachFiles attaches files, dirs or globs as context.
+func (that *ConversationModel) AttachFiles(paths []string) {
+	if len(paths) == 0 {
+		that.Notice = "usage: /attach <file|dir|glob>..."
+		return
+	}
+	report, err := that.Conversation.Attach(paths...)
+	if err != nil {
+		that.Error = err
+		return
+	}
+	that.Notice = report.String()
+}
+
+// Ask sends the current question of the conversation to the bot.
+// Clients changed by commands like /model and /temp are created again first.
+func (that *ConversationModel) Ask() (cmds []tea.Cmd) {
+	that.ReloadClients()
+	if that.Compare != nil {
+		return that.askCompare()
+	}
+	msgList := that.Conversation.GetMessages()
+	that.Receiving = true
+	cmds = append(
+		cmds, func() tea.Msg {
+			return that.Spinner.Tick()
+		},
+	)
+	// sending may wait for retries of the router, keep the UI responsive.
+	bot := that.GetBot()
+	b := that.backend()
+	that.Conversation.TagAnswer(b.Name, b.ModelOf(that.CNF))
+	cmds = append(cmds, func() tea.Msg {
+		answerStr, err := bot.SendMsg(msgList)
+		return AnswerStarted{Answer: answerStr, Err: err}
+	})
+	that.Viewport.SetContent(that.RenderQA(that.Conversation.GetQAByCursor()))
+	that.Viewport.GotoBottom()
+	return
+}
+
+// started shows the first message of an answer.
+func (that *ConversationModel) started(msg AnswerStarted) (cmds []tea.Cmd) {
+	if msg.Err == io.EOF {
+		that.Receiving = false
+	} else {
+		cmds = append(cmds, func() tea.Msg {
+			var msg AnswerContinue
+			return msg
+		})
+	}
+	that.Conversation.AddTokens(that.GetBot().GetTokens())
+	if r, ok := that.GetBot().(Routed); ok && r.Provider() != "" {
+		that.Conversation.TagAnswer(r.Provider(), r.Model())
+	}
+
+	that.Conversation.AddAnswer(msg.Answer, !that.Receiving)
+	if msg.Err != nil && msg.Err != io.EOF {
+		that.Error = msg.Err
+		that.dropQuestion()
+		that.Receiving = false
+		cmds = nil
+	}
+	that.Viewport.SetContent(that.RenderQA(that.Conversation.GetQAByCursor()))
+	that.Viewport.GotoBottom()
+	return
+}
+
+// dropQuestion gives up the question of a failed answer, it goes back to the input area to be sent again.
+func (that *ConversationModel) dropQuestion() {
+	if cur := that.Conversation.Current; cur != nil && that.TextArea.Value() == "" {
+		that.TextArea.SetValue(cur.Q)
+		that.resize()
+	}
+	that.Conversation.DropCurrent()
+}
+
 func (that *ConversationModel) ContainsCJK(s string) bool {
 	for _, r := range s {
 		if unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) {
@@ -250,13 +512,6 @@ func (that *ConversationModel) EnsureTrailingNewline(s string) string {
 	return s
 }
 
-var (
-	senderStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("5"))
-	botStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("6"))
-	errorStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF0000"))
-	footerStyle = lipgloss.NewStyle().Height(1).Foreground(lipgloss.Color("#00FFFF")).Faint(true)
-)
-
 func (that *ConversationModel) RenderQA(qa cvsation.QuesAnsw) string {
 	var (
 		b       strings.Builder
@@ -273,7 +528,11 @@ func (that *ConversationModel) RenderQA(qa cvsation.QuesAnsw) string {
 	content, _ = that.R.Render(content)
 	b.WriteString(that.EnsureTrailingNewline(content))
 
-	b.WriteString(botStyle.Render("Bot: "))
+	if p := qa.Provenance(); p != "" {
+		b.WriteString(botStyle.Render(fmt.Sprintf("Bot (%s): ", p)))
+	} else {
+		b.WriteString(botStyle.Render("Bot: "))
+	}
 	content = qa.A
 	if that.ContainsCJK(content) {
 		content = wrap.String(content, that.WindowWidth-5)
@@ -298,11 +557,18 @@ func (that *ConversationModel) RenderFooter() string {
 		columns = append(columns, that.Spinner.Spinner.Frames[0])
 	}
 
-	// bot type: ChatGPT/Spark
-	if that.Conversation.BotType == cvsation.BotGPT {
-		columns = append(columns, cvsation.BotGPT)
+	// bot type: ChatGPT/Spark/Claude, and the provider answering for the router.
+	if that.Compare != nil {
+		columns = append(columns, "Compare "+strings.Join(that.Compare.Names(), " | "))
+	} else if r, ok := that.Bots[that.Conversation.BotType].(Routed); ok && r.Provider() != "" {
+		columns = append(columns, fmt.Sprintf("%s → %s", that.Conversation.BotType, r.Provider()))
 	} else {
-		columns = append(columns, cvsation.BotSpark)
+		columns = append(columns, that.Conversation.BotType)
+	}
+
+	// config profile
+	if p := that.CNF.Profile(); p != config.DefaultProfileName {
+		columns = append(columns, p)
 	}
 
 	// conversation indicator
@@ -311,17 +577,34 @@ func (that *ConversationModel) RenderFooter() string {
 		columns = append(columns, conversationIdx)
 	}
 
+	// branches of the current QA
+	if qa := that.Conversation.GetQAByCursor(); qa.BranchCount() > 1 {
+		columns = append(columns, fmt.Sprintf("Branch %d/%d", qa.Branch+1, qa.BranchCount()))
+	}
+	if that.EditIndex >= 0 {
+		columns = append(columns, fmt.Sprintf("Editing Q&A %d", that.EditIndex+1))
+	}
+
+	// attached files
+	if l := len(that.Conversation.Attachments); l > 0 {
+		columns = append(columns, fmt.Sprintf("Files %d", l))
+	}
+
 	// tokens
 	token := that.Conversation.GetTokens()
 	columns = append(columns, fmt.Sprintf("Tokens %d", token))
 
 	// switch tab
-	columns = append(columns, "Tab ←/→")
+	if that.Notice != "" {
+		columns = append(columns, that.Notice)
+	} else {
+		columns = append(columns, "Tab ←/→")
+	}
 
 	l := len(columns)
 	length := that.WindowWidth / l
 	for i := 0; i < l; i++ {
-		columns[i] = footerStyle.Render(columns[i] + strings.Repeat(" ", length-len(columns[i])))
+		columns[i] = footerStyle.Render(columns[i] + strings.Repeat(" ", max(length-len(columns[i]), 0)))
 	}
 	return lipgloss.JoinHorizontal(lipgloss.Left, columns...)
 }
@@ -331,19 +614,52 @@ func (that *ConversationModel) View() string {
 		return "Initializing..."
 	}
 
-	return lipgloss.JoinVertical(
-		lipgloss.Left,
-		that.Viewport.View(),
-		that.TextArea.View(),
-		that.RenderFooter(),
-	)
+	if that.CodeSaver != nil {
+		return lipgloss.JoinVertical(
+			lipgloss.Left,
+			lipgloss.NewStyle().Height(that.Viewport.Height).Render(that.CodeSaver.View()),
+			that.TextArea.View(),
+			that.RenderFooter(),
+		)
+	}
+
+	views := []string{that.Viewport.View()}
+	if hint := that.RenderHint(); hint != "" {
+		views = append(views, hint)
+	}
+	views = append(views, that.TextArea.View(), that.RenderFooter())
+	return lipgloss.JoinVertical(lipgloss.Left, views...)
+}
+
+// CapturingKeys tells the tab container to pass all keys to this tab.
+func (that *ConversationModel) CapturingKeys() bool {
+	return that.CodeSaver != nil
+}
+
+// saveDraft keeps the unsent input with the history of the session.
+func (that *ConversationModel) saveDraft() {
+	that.History.SaveDraft(that.TextArea.Value())
+}
+
+// loadSession loads a named session, the draft of the old one is saved and the one of the new one restored.
+func (that *ConversationModel) loadSession(name string) error {
+	that.saveDraft()
+	if err := that.Conversation.LoadFrom(name); err != nil {
+		return err
+	}
+	that.History = cvsation.NewInputHistory(that.Conversation.SessionFile())
+	that.TextArea.SetValue(that.History.PopDraft())
+	that.TextArea.CursorEnd()
+	that.Viewport.SetContent(that.RenderQA(that.Conversation.GetQAByCursor()))
+	return nil
 }
 
 func (that *ConversationModel) CloseConversation() {
-	if that.GPT != nil {
-		that.GPT.Close()
+	that.saveDraft()
+	if that.Compare != nil {
+		that.Compare.Close()
 	}
-	if that.Spark != nil {
-		that.Spark.Close()
+	for _, bot := range that.Bots {
+		bot.Close()
 	}
 }
@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	cvsation "github.com/gvcgo/gogpt/pkgs/conversation"
+)
+
+func newTestConversationModel(t *testing.T) *ConversationModel {
+	t.Helper()
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	keys, err := NewKeyMap(cnf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewConversationModel(cnf, keys)
+}
+
+func TestLoadKeepsDrafts(t *testing.T) {
+	cvm := newTestConversationModel(t)
+	cvm.Conversation.Save()
+	cvm.Commands.Run(cvm, "/save work")
+	cvm.TextArea.SetValue("draft of work")
+
+	cvm.Commands.Run(cvm, "/load")
+	if cvm.Error != nil {
+		t.Fatal(cvm.Error)
+	}
+	if d := cvsation.NewInputHistory(cvm.Conversation.SessionPath("work")).Draft; d != "draft of work" {
+		t.Errorf("draft of the left session %q", d)
+	}
+	cvm.TextArea.SetValue("draft of default")
+
+	cvm.Commands.Run(cvm, "/load work")
+	if v := cvm.TextArea.Value(); v != "draft of work" {
+		t.Errorf("draft of the loaded session %q", v)
+	}
+	cvm.Commands.Run(cvm, "/load")
+	if v := cvm.TextArea.Value(); v != "draft of default" {
+		t.Errorf("draft of the loaded session %q", v)
+	}
+}
+
+func TestAskSendsWithConfigSnapshot(t *testing.T) {
+	models := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			Model string `json:"model"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		models <- req.Model
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id": "1", "choices": [{"index": 0, "delta": {"content": "hi"}}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+	cvm := newTestConversationModel(t)
+	cvm.CNF.OpenAI.BaseUrl = srv.URL
+	cvm.CNF.OpenAI.ApiKey = "sk-test"
+	cvm.CNF.OpenAI.Model = "gpt-old"
+	cvm.Conversation.SetBotType(cvsation.BotGPT)
+	cvm.Conversation.AddQuestion("hi")
+
+	// commands change the config while the question is sent in the background.
+	cmds := cvm.Ask()
+	done := make(chan tea.Msg)
+	go func() { done <- cmds[len(cmds)-1]() }()
+	cvm.Commands.Run(cvm, "/model gpt-new")
+	cvm.Commands.Run(cvm, "/temp 0.5")
+	if msg, ok := (<-done).(AnswerStarted); !ok || msg.Err != nil {
+		t.Fatalf("answer %+v", msg)
+	}
+	if m := <-models; m != "gpt-old" {
+		t.Errorf("sent with model %q, want gpt-old", m)
+	}
+
+	// the next question uses the changed config.
+	cvm.Receiving = false
+	cvm.Conversation.AddQuestion("again")
+	cmds = cvm.Ask()
+	cmds[len(cmds)-1]()
+	if m := <-models; m != "gpt-new" {
+		t.Errorf("sent with model %q, want gpt-new", m)
+	}
+}
@@ -0,0 +1,150 @@
+package tui
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	cvsation "github.com/gvcgo/gogpt/pkgs/conversation"
+	"github.com/gvcgo/gogpt/pkgs/gpt"
+	"github.com/gvcgo/gogpt/pkgs/theme"
+	"github.com/gvcgo/goutils/pkgs/gtea/gprint"
+)
+
+// GetDirs resolves the dirs with the --workdir flag in fs, fs may be nil.
+// The legacy ~/.gogpt is used until gogptm moves it to the default dirs, see MigrateLegacyDir.
+func GetDirs(fs *flag.FlagSet) config.Dirs {
+	dirs := config.ResolveDirs(workDirFlag(fs))
+	if legacyPending(dirs) {
+		return config.NewDirs(config.LegacyDir())
+	}
+	return dirs
+}
+
+// GetWorkDir returns the dir of config files.
+func GetWorkDir() string {
+	return GetDirs(nil).Config
+}
+
+// MigrateLegacyDir moves files of ~/.gogpt to the default dirs once, the caller holds the single instance lock.
+// Files are never moved into the dir of --workdir or GOGPT_HOME.
+func MigrateLegacyDir(fs *flag.FlagSet) {
+	dirs := config.ResolveDirs(workDirFlag(fs))
+	if !legacyPending(dirs) {
+		return
+	}
+	if err := migrateLegacyDir(dirs); err != nil {
+		gprint.PrintWarning("migrate %s: %+v", config.LegacyDir(), err)
+	}
+}
+
+func workDirFlag(fs *flag.FlagSet) string {
+	if fs != nil {
+		if f := fs.Lookup(config.WorkDirFlag); f != nil {
+			return f.Value.String()
+		}
+	}
+	return ""
+}
+
+// legacyPending tells if the default dirs have no config file yet and ~/.gogpt has one.
+func legacyPending(dirs config.Dirs) bool {
+	legacy := config.LegacyDir()
+	if dirs != config.DefaultDirs() || dirs.Config == legacy {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(dirs.Config, config.ConfigFileName)); err == nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(legacy, config.ConfigFileName))
+	return err == nil
+}
+
+// migrateLegacyDir moves files of ~/.gogpt to dirs.
+func migrateLegacyDir(dirs config.Dirs) error {
+	legacy := config.LegacyDir()
+	if err := dirs.MkdirAll(); err != nil {
+		return err
+	}
+	moves := map[string]string{
+		config.ConfigFileName:         dirs.Config,
+		config.SecretsFileName:        dirs.Config,
+		theme.ThemeDirName:            dirs.Config,
+		cvsation.ConversationFileName: dirs.Data,
+		cvsation.ConversationDirName:  dirs.Data,
+		cvsation.InputHistoryFileName: dirs.Data,
+		gpt.PromptFileName:            dirs.Cache,
+		gpt.ModelCacheFileName:        dirs.Cache,
+	}
+	errList := []error{}
+	// the config file goes last, so an interrupted migration is resumed.
+	for name, dir := range moves {
+		if name != config.ConfigFileName {
+			errList = append(errList, movePath(filepath.Join(legacy, name), filepath.Join(dir, name)))
+		}
+	}
+	if err := errors.Join(errList...); err != nil {
+		return err
+	}
+	if err := movePath(filepath.Join(legacy, config.ConfigFileName), filepath.Join(dirs.Config, config.ConfigFileName)); err != nil {
+		return err
+	}
+	if os.Remove(legacy) != nil {
+		gprint.PrintWarning("%s is kept, it contains unknown files", legacy)
+	}
+	gprint.PrintInfo("moved %s to %s", legacy, dirs.Config)
+	return nil
+}
+
+// movePath renames src to dst, or copies it when they are on different devices.
+func movePath(src, dst string) error {
+	info, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err = os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(dst, 0700); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err = movePath(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return os.Remove(src)
+	}
+	if err = copyFile(src, dst, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("move %s: %w", src, err)
+	}
+	return os.Remove(src)
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+func TestMigrateLegacyDirOnlyToDefaultDirs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the legacy dir is the default dir")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "data"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, "cache"))
+	t.Setenv(config.WorkDirEnv, "")
+	legacy := config.LegacyDir()
+	if err := os.MkdirAll(legacy, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(legacy, config.ConfigFileName), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the legacy dir is read until it is migrated.
+	if dirs := GetDirs(nil); dirs != config.NewDirs(legacy) {
+		t.Errorf("GetDirs() = %+v, want the legacy dir", dirs)
+	}
+
+	// explicit work dirs are never migrated into.
+	workDir := t.TempDir()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.AddFlags(fs)
+	if err := fs.Parse([]string{"--" + config.WorkDirFlag, workDir}); err != nil {
+		t.Fatal(err)
+	}
+	MigrateLegacyDir(fs)
+	t.Setenv(config.WorkDirEnv, workDir)
+	MigrateLegacyDir(nil)
+	if dirs := GetDirs(nil); dirs != config.NewDirs(workDir) {
+		t.Errorf("GetDirs() with %s = %+v, want %s", config.WorkDirEnv, dirs, workDir)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, config.ConfigFileName)); err == nil {
+		t.Errorf("legacy config moved to %s", workDir)
+	}
+	if _, err := os.Stat(filepath.Join(legacy, config.ConfigFileName)); err != nil {
+		t.Fatalf("legacy config is gone: %v", err)
+	}
+
+	t.Setenv(config.WorkDirEnv, "")
+	MigrateLegacyDir(nil)
+	dirs := config.DefaultDirs()
+	if _, err := os.Stat(filepath.Join(dirs.Config, config.ConfigFileName)); err != nil {
+		t.Errorf("legacy config not moved to %s: %v", dirs.Config, err)
+	}
+	if got := GetDirs(nil); got != dirs {
+		t.Errorf("GetDirs() after migration = %+v, want %+v", got, dirs)
+	}
+}
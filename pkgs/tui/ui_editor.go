@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+/*
+Edit the message draft with an external editor.
+*/
+type EditorFinished struct {
+	Content string
+	Err     error
+}
+
+func GetEditor() []string {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		} else {
+			editor = "vi"
+		}
+	}
+	// allows editors with args, like "code --wait".
+	return strings.Fields(editor)
+}
+
+// OpenEditor writes the current draft to a temp file, opens it in $EDITOR, and loads the result back.
+func (that *ConversationModel) OpenEditor() tea.Cmd {
+	f, err := os.CreateTemp("", "gogpt_*.md")
+	if err != nil {
+		return func() tea.Msg { return EditorFinished{Err: err} }
+	}
+	fPath := f.Name()
+	_, err = f.WriteString(that.TextArea.Value())
+	f.Close()
+	if err != nil {
+		os.Remove(fPath)
+		return func() tea.Msg { return EditorFinished{Err: err} }
+	}
+
+	editor := GetEditor()
+	c := exec.Command(editor[0], append(editor[1:], fPath)...)
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		defer os.Remove(fPath)
+		if err != nil {
+			return EditorFinished{Err: err}
+		}
+		content, err := os.ReadFile(fPath)
+		return EditorFinished{Content: strings.TrimRight(string(content), "\r\n"), Err: err}
+	})
+}
@@ -0,0 +1,374 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+/*
+Form with inline validation, used by the Configuration tab.
+
+Secrets are masked until the reveal_secret key reveals the focused one.
+The clear_field key clears the focused field, an empty field unsets the value or restores the default.
+*/
+type FormField struct {
+	Name     string
+	Input    textinput.Model
+	Options  []string // the value is selected with up/down when not empty.
+	FreeText bool     // an option field that also takes typed values.
+	Secret   bool
+	Validate func(string) error
+	Err      error
+	optIdx   int
+}
+
+func (that *FormField) Value() string {
+	return strings.TrimSpace(that.Input.Value())
+}
+
+func (that *FormField) check() error {
+	that.Err = nil
+	if that.Validate != nil {
+		that.Err = that.Validate(that.Value())
+	}
+	return that.Err
+}
+
+func (that *FormField) cycle(step int) {
+	if len(that.Options) == 0 {
+		return
+	}
+	that.optIdx = (that.optIdx + step + len(that.Options)) % len(that.Options)
+	that.Input.SetValue(that.Options[that.optIdx])
+}
+
+// SetOptions replaces options and keeps the current value.
+func (that *FormField) SetOptions(options []string) {
+	value := that.Value()
+	that.Options = options
+	that.optIdx = 0
+	for i, o := range options {
+		if o == value {
+			that.optIdx = i
+			return
+		}
+	}
+	if value != "" {
+		that.Options = append([]string{value}, options...)
+	}
+}
+
+func (that *FormField) typable() bool {
+	return len(that.Options) == 0 || that.FreeText
+}
+
+func (that *FormField) reveal() {
+	if !that.Secret {
+		return
+	}
+	if that.Input.EchoMode == textinput.EchoPassword {
+		that.Input.EchoMode = textinput.EchoNormal
+	} else {
+		that.Input.EchoMode = textinput.EchoPassword
+	}
+}
+
+// FormAction is a button after Submit, Run gets the current values of the form.
+type FormAction struct {
+	Name string
+	Run  func(values map[string]string) tea.Cmd
+}
+
+// FormNotice is shown under the buttons, returned by actions.
+type FormNotice string
+
+// FormOptions replaces options of a field, Notice is shown if not empty.
+type FormOptions struct {
+	Name    string
+	Options []string
+	Notice  string
+}
+
+type ConfigFormModel struct {
+	Fields       []*FormField
+	Actions      []*FormAction
+	Error        error
+	Notice       string
+	focusIndex   int // len(Fields) is the submit button, actions follow.
+	submitCmd    tea.Cmd
+	initCmds     []tea.Cmd
+	promptFormat string
+	keys         *KeyMap
+}
+
+func NewConfigFormModel(keys *KeyMap) (cfm *ConfigFormModel) {
+	cfm = &ConfigFormModel{
+		Fields:       []*FormField{},
+		submitCmd:    tea.Quit,
+		promptFormat: "%-20s",
+		keys:         keys,
+	}
+	return
+}
+
+func (that *ConfigFormModel) add(name, placeholder, value string) *FormField {
+	f := &FormField{Name: name}
+	f.Input = textinput.New()
+	f.Input.Prompt = fmt.Sprintf(that.promptFormat, name) + ": "
+	f.Input.Placeholder = placeholder
+	f.Input.PlaceholderStyle = placeholderStyle
+	f.Input.TextStyle = inputTextStyle
+	f.Input.SetValue(value)
+	that.Fields = append(that.Fields, f)
+	if len(that.Fields) == 1 {
+		that.focus(0)
+	}
+	return f
+}
+
+// AddInput adds a text field, validate may be nil.
+func (that *ConfigFormModel) AddInput(name, placeholder, value string, validate func(string) error) *FormField {
+	f := that.add(name, placeholder, value)
+	f.Validate = validate
+	f.check()
+	return f
+}
+
+// AddSecret adds a masked text field.
+func (that *ConfigFormModel) AddSecret(name, placeholder, value string, validate func(string) error) *FormField {
+	f := that.AddInput(name, placeholder, value, validate)
+	f.Secret = true
+	f.Input.EchoMode = textinput.EchoPassword
+	f.Input.EchoCharacter = '*'
+	return f
+}
+
+// AddOption adds a field selected from options, a value not in options is kept as the first one.
+func (that *ConfigFormModel) AddOption(name, placeholder string, options []string, value string) *FormField {
+	opts := options
+	idx := -1
+	for i, o := range options {
+		if o == value {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 && value != "" {
+		opts = append([]string{value}, options...)
+		idx = 0
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	f := that.add(name, placeholder, "")
+	f.Options = opts
+	f.optIdx = idx
+	if len(opts) > 0 {
+		f.Input.SetValue(opts[idx])
+	}
+	return f
+}
+
+func (that *ConfigFormModel) AddAction(name string, run func(values map[string]string) tea.Cmd) {
+	that.Actions = append(that.Actions, &FormAction{Name: name, Run: run})
+}
+
+// AddInitCmd adds a command run when the form starts, like loading options.
+func (that *ConfigFormModel) AddInitCmd(cmd tea.Cmd) {
+	that.initCmds = append(that.initCmds, cmd)
+}
+
+func (that *ConfigFormModel) SetSubmitCmd(cmd tea.Cmd) {
+	that.submitCmd = cmd
+}
+
+func (that *ConfigFormModel) Values() map[string]string {
+	r := make(map[string]string)
+	for _, f := range that.Fields {
+		r[f.Name] = f.Value()
+	}
+	return r
+}
+
+// Validate checks all fields and focuses the first invalid one.
+func (that *ConfigFormModel) Validate() bool {
+	first := -1
+	for i, f := range that.Fields {
+		if f.check() != nil && first < 0 {
+			first = i
+		}
+	}
+	if first >= 0 {
+		that.focus(first)
+		return false
+	}
+	return true
+}
+
+func (that *ConfigFormModel) focused() *FormField {
+	if that.focusIndex >= 0 && that.focusIndex < len(that.Fields) {
+		return that.Fields[that.focusIndex]
+	}
+	return nil
+}
+
+func (that *ConfigFormModel) focus(idx int) tea.Cmd {
+	n := len(that.Fields) + 1 + len(that.Actions)
+	that.focusIndex = (idx + n) % n
+	var cmd tea.Cmd
+	for i, f := range that.Fields {
+		if i == that.focusIndex {
+			cmd = f.Input.Focus()
+			f.Input.PromptStyle = formFocusedStyle
+			continue
+		}
+		f.Input.Blur()
+		f.Input.PromptStyle = lipgloss.NewStyle()
+		if f.Secret {
+			// hide revealed secrets when leaving the field.
+			f.Input.EchoMode = textinput.EchoPassword
+		}
+	}
+	return cmd
+}
+
+func (that *ConfigFormModel) actionNames() (names []string) {
+	for _, a := range that.Actions {
+		names = append(names, a.Name)
+	}
+	return
+}
+
+func (that *ConfigFormModel) Init() tea.Cmd {
+	return tea.Batch(append([]tea.Cmd{textinput.Blink}, that.initCmds...)...)
+}
+
+func (that *ConfigFormModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case error:
+		// returned by the submit command.
+		that.Error = msg
+		return that, nil
+	case FormNotice:
+		that.Notice = string(msg)
+		return that, nil
+	case FormOptions:
+		for _, f := range that.Fields {
+			if f.Name == msg.Name {
+				f.SetOptions(msg.Options)
+			}
+		}
+		if msg.Notice != "" {
+			that.Notice = msg.Notice
+		}
+		return that, nil
+	case tea.KeyMsg:
+		f := that.focused()
+		switch {
+		case key.Matches(msg, that.keys.Quit):
+			return that, tea.Quit
+		case key.Matches(msg, that.keys.RevealSecret):
+			if f != nil {
+				f.reveal()
+			}
+			return that, nil
+		case key.Matches(msg, that.keys.ClearField):
+			if f != nil && f.typable() {
+				f.Input.SetValue("")
+				f.check()
+			}
+			return that, nil
+		case key.Matches(msg, that.keys.PrevField):
+			return that, that.focus(that.focusIndex - 1)
+		case key.Matches(msg, that.keys.NextField):
+			return that, that.focus(that.focusIndex + 1)
+		}
+		switch msg.String() {
+		case "up", "down":
+			if f != nil && len(f.Options) > 0 {
+				if msg.String() == "up" {
+					f.cycle(1)
+				} else {
+					f.cycle(-1)
+				}
+				return that, nil
+			}
+			if msg.String() == "up" {
+				return that, that.focus(that.focusIndex - 1)
+			}
+			return that, that.focus(that.focusIndex + 1)
+		case "enter":
+			if f != nil {
+				return that, that.focus(that.focusIndex + 1)
+			}
+			that.Error = nil
+			if !that.Validate() {
+				that.Error = fmt.Errorf("please fix the invalid fields")
+				return that, nil
+			}
+			if idx := that.focusIndex - len(that.Fields) - 1; idx >= 0 {
+				action := that.Actions[idx]
+				that.Notice = action.Name + "..."
+				return that, action.Run(that.Values())
+			}
+			return that, that.submitCmd
+		}
+		if f != nil && f.typable() {
+			var cmd tea.Cmd
+			f.Input, cmd = f.Input.Update(msg)
+			f.check()
+			return that, cmd
+		}
+		return that, nil
+	}
+	if f := that.focused(); f != nil {
+		var cmd tea.Cmd
+		f.Input, cmd = f.Input.Update(msg)
+		return that, cmd
+	}
+	return that, nil
+}
+
+func (that *ConfigFormModel) View() string {
+	rows := []string{}
+	indent := strings.Repeat(" ", len(fmt.Sprintf(that.promptFormat, ""))+2)
+	for _, f := range that.Fields {
+		row := f.Input.View()
+		if len(f.Options) > 1 && f == that.focused() {
+			hint := fmt.Sprintf("  (↑/↓ %d/%d)", f.optIdx+1, len(f.Options))
+			if f.FreeText {
+				hint = fmt.Sprintf("  (↑/↓ %d/%d or type a name)", f.optIdx+1, len(f.Options))
+			}
+			row += placeholderStyle.Render(hint)
+		}
+		rows = append(rows, row)
+		if f.Err != nil {
+			rows = append(rows, errorStyle.Render(indent+f.Err.Error()))
+		}
+	}
+	buttons := []string{}
+	for i, name := range append([]string{"Submit"}, that.actionNames()...) {
+		button := fmt.Sprintf("[ %s ]", name)
+		if that.focusIndex == len(that.Fields)+i {
+			button = formFocusedStyle.Render(button)
+		}
+		buttons = append(buttons, button)
+	}
+	rows = append(rows, "", strings.Join(buttons, "  "))
+	if that.Error != nil {
+		rows = append(rows, errorStyle.Render(that.Error.Error()))
+	}
+	if that.Notice != "" {
+		rows = append(rows, that.Notice)
+	}
+	rows = append(rows, footerStyle.Render(fmt.Sprintf(
+		"%s/↑/↓ move, ↑/↓ select options, %s reveal secret, %s clear field, enter on a button runs it",
+		that.keys.NextField.Help().Key, that.keys.RevealSecret.Help().Key, that.keys.ClearField.Help().Key,
+	)))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
@@ -9,12 +9,15 @@ import (
 
 type HelpModel struct {
 	helpStyle lipgloss.Style
+	Keys      *KeyMap
+	Commands  *CommandRegistry
 }
 
-func NewHelpModel() (h *HelpModel) {
+func NewHelpModel(keys *KeyMap, commands *CommandRegistry) (h *HelpModel) {
 	h = &HelpModel{
-		// "#FFA500" "#808080"
-		helpStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("#FFA500")),
+		helpStyle: helpStyle,
+		Keys:      keys,
+		Commands:  commands,
 	}
 	return
 }
@@ -29,21 +32,20 @@ func (that *HelpModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (that *HelpModel) View() string {
 	pattern := "%-12s  %s"
-	helpList := []string{
-		fmt.Sprintf(pattern, "enter", "Submit your message to gpt."),
-		fmt.Sprintf(pattern, "↑", "Scroll up."),
-		fmt.Sprintf(pattern, "↓", "Scroll down."),
-		fmt.Sprintf(pattern, "ctrl+p", "Show the previous QA."),
-		fmt.Sprintf(pattern, "ctrl+f", "Show the next QA."),
-		fmt.Sprintf(pattern, "ctrl+s", "Save conversation."),
-		fmt.Sprintf(pattern, "ctrl+l", "Load conversation."),
-		fmt.Sprintf(pattern, "ctrl+d", "Remove conversation context."),
-		fmt.Sprintf(pattern, "ctrl+w", "Switch bot type between ChatGPT and Spark."),
-		fmt.Sprintf(pattern, "ctrl+c/esc", "Exit."),
-		fmt.Sprintf(pattern, "→", "Switch to the next Tab."),
-		fmt.Sprintf(pattern, "←", "Switch to the previous Tab."),
-		fmt.Sprintf(pattern, "tab", "Goto next input."),
-		fmt.Sprintf(pattern, "shift+tab", "Goto previous input."),
+	helpList := []string{}
+	// generated from the active keymap.
+	for _, item := range that.Keys.Items() {
+		if !item.Binding.Enabled() {
+			continue
+		}
+		h := item.Binding.Help()
+		helpList = append(helpList, fmt.Sprintf(pattern, h.Key, h.Desc))
+	}
+	if that.Commands != nil {
+		helpList = append(helpList, "")
+		for _, c := range that.Commands.List() {
+			helpList = append(helpList, fmt.Sprintf("%-28s  %s", c.Usage(), c.Help))
+		}
 	}
 	r := []string{}
 	for _, str := range helpList {
@@ -0,0 +1,208 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+/*
+Keybindings, can be overridden by the "keybindings" section of the config file:
+
+	"keybindings": {
+	    "save": "ctrl+alt+s",
+	    "load": "ctrl+alt+l, f5",
+	    "switch_bot": ""
+	}
+
+An empty value disables the binding.
+A key may be bound once in each tab, global keys work in all tabs.
+*/
+type KeyScope string
+
+const (
+	KeyScopeGlobal       KeyScope = "global"
+	KeyScopeConversation KeyScope = "conversation"
+	KeyScopeConfig       KeyScope = "configuration"
+)
+
+type KeyMap struct {
+	Quit         key.Binding
+	NextTab      key.Binding
+	PrevTab      key.Binding
+	Submit       key.Binding
+	Newline      key.Binding
+	Editor       key.Binding
+	Complete     key.Binding
+	ScrollUp     key.Binding
+	ScrollDown   key.Binding
+	PrevQA       key.Binding
+	NextQA       key.Binding
+	HistoryPrev  key.Binding
+	HistoryNext  key.Binding
+	Regenerate   key.Binding
+	EditQA       key.Binding
+	SwitchBranch key.Binding
+	Save         key.Binding
+	Load         key.Binding
+	ClearContext key.Binding
+	SwitchBot    key.Binding
+	SaveCode     key.Binding
+	// Configuration tab
+	NextField    key.Binding
+	PrevField    key.Binding
+	RevealSecret key.Binding
+	ClearField   key.Binding
+}
+
+type KeyItem struct {
+	Name    string
+	Binding *key.Binding
+	Scope   KeyScope
+}
+
+func NewKeyMap(cnf *config.Config) (km *KeyMap, err error) {
+	submit := config.DefaultSubmitKey
+	if cnf.UI.SubmitKey != "" {
+		submit = cnf.UI.SubmitKey
+	}
+	newline := []string{"alt+enter", "ctrl+j"}
+	if submit != "enter" && submit != "ctrl+m" {
+		newline = []string{"enter", "ctrl+m"}
+	}
+	km = &KeyMap{
+		Quit:         key.NewBinding(key.WithKeys("ctrl+c", "esc"), key.WithHelp("ctrl+c/esc", "Exit.")),
+		NextTab:      key.NewBinding(key.WithKeys("right"), key.WithHelp("→", "Switch to the next Tab.")),
+		PrevTab:      key.NewBinding(key.WithKeys("left"), key.WithHelp("←", "Switch to the previous Tab.")),
+		Submit:       key.NewBinding(key.WithKeys(submit), key.WithHelp(submit, "Submit your message to gpt.")),
+		Newline:      key.NewBinding(key.WithKeys(newline...), key.WithHelp(newline[0], "Insert a newline.")),
+		Editor:       key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "Edit your message in $EDITOR.")),
+		Complete:     key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "Complete slash commands and their arguments.")),
+		ScrollUp:     key.NewBinding(key.WithKeys("up"), key.WithHelp("↑", "Scroll up.")),
+		ScrollDown:   key.NewBinding(key.WithKeys("down"), key.WithHelp("↓", "Scroll down.")),
+		PrevQA:       key.NewBinding(key.WithKeys("ctrl+p"), key.WithHelp("ctrl+p", "Show the previous QA.")),
+		NextQA:       key.NewBinding(key.WithKeys("ctrl+f"), key.WithHelp("ctrl+f", "Show the next QA.")),
+		HistoryPrev:  key.NewBinding(key.WithKeys("ctrl+up"), key.WithHelp("ctrl+↑", "Recall the previous question.")),
+		HistoryNext:  key.NewBinding(key.WithKeys("ctrl+down"), key.WithHelp("ctrl+↓", "Recall the next question.")),
+		Regenerate:   key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "Regenerate the last answer, the old one is kept as a branch.")),
+		EditQA:       key.NewBinding(key.WithKeys("ctrl+y"), key.WithHelp("ctrl+y", "Edit the question of the current QA and resend it as a new branch.")),
+		SwitchBranch: key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "Switch to the next branch of the current QA.")),
+		Save:         key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "Save conversation.")),
+		Load:         key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "Load conversation.")),
+		ClearContext: key.NewBinding(key.WithKeys("ctrl+d"), key.WithHelp("ctrl+d", "Remove conversation context.")),
+		SwitchBot:    key.NewBinding(key.WithKeys("ctrl+w"), key.WithHelp("ctrl+w", "Switch to the next bot, like ChatGPT, Spark or Claude.")),
+		SaveCode:     key.NewBinding(key.WithKeys("ctrl+o"), key.WithHelp("ctrl+o", "Save a code block from the current answer to a file.")),
+		NextField:    key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "Goto next input.")),
+		PrevField:    key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "Goto previous input.")),
+		RevealSecret: key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "Reveal or hide the focused secret.")),
+		ClearField:   key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "Clear the focused input, an empty input unsets the value.")),
+	}
+	err = km.Override(cnf.Keybindings)
+	if err == nil {
+		err = km.Conflicts()
+	}
+	return
+}
+
+// Items returns the bindings with their names in the config file.
+func (that *KeyMap) Items() []KeyItem {
+	return []KeyItem{
+		{"submit", &that.Submit, KeyScopeConversation},
+		{"newline", &that.Newline, KeyScopeConversation},
+		{"editor", &that.Editor, KeyScopeConversation},
+		{"complete", &that.Complete, KeyScopeConversation},
+		{"scroll_up", &that.ScrollUp, KeyScopeConversation},
+		{"scroll_down", &that.ScrollDown, KeyScopeConversation},
+		{"prev_qa", &that.PrevQA, KeyScopeConversation},
+		{"next_qa", &that.NextQA, KeyScopeConversation},
+		{"history_prev", &that.HistoryPrev, KeyScopeConversation},
+		{"history_next", &that.HistoryNext, KeyScopeConversation},
+		{"regenerate", &that.Regenerate, KeyScopeConversation},
+		{"edit_qa", &that.EditQA, KeyScopeConversation},
+		{"switch_branch", &that.SwitchBranch, KeyScopeConversation},
+		{"save", &that.Save, KeyScopeConversation},
+		{"load", &that.Load, KeyScopeConversation},
+		{"clear_context", &that.ClearContext, KeyScopeConversation},
+		{"switch_bot", &that.SwitchBot, KeyScopeConversation},
+		{"save_code", &that.SaveCode, KeyScopeConversation},
+		{"quit", &that.Quit, KeyScopeGlobal},
+		{"next_tab", &that.NextTab, KeyScopeGlobal},
+		{"prev_tab", &that.PrevTab, KeyScopeGlobal},
+		{"next_field", &that.NextField, KeyScopeConfig},
+		{"prev_field", &that.PrevField, KeyScopeConfig},
+		{"reveal_secret", &that.RevealSecret, KeyScopeConfig},
+		{"clear_field", &that.ClearField, KeyScopeConfig},
+	}
+}
+
+// Override applies bindings from the config file, keys are separated by commas.
+func (that *KeyMap) Override(bindings map[string]string) error {
+	items := map[string]*key.Binding{}
+	for _, item := range that.Items() {
+		items[item.Name] = item.Binding
+	}
+	unknown := []string{}
+	for name, keys := range bindings {
+		b, ok := items[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		keyList := []string{}
+		for _, k := range strings.Split(keys, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				keyList = append(keyList, k)
+			}
+		}
+		if len(keyList) == 0 {
+			b.SetEnabled(false)
+			continue
+		}
+		b.SetKeys(keyList...)
+		b.SetHelp(strings.Join(keyList, "/"), b.Help().Desc)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown keybindings: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Conflicts returns an error if a key is bound to more than one action of a tab.
+func (that *KeyMap) Conflicts() error {
+	owners := map[string]map[KeyScope][]string{}
+	for _, item := range that.Items() {
+		if !item.Binding.Enabled() {
+			continue
+		}
+		for _, k := range item.Binding.Keys() {
+			if owners[k] == nil {
+				owners[k] = map[KeyScope][]string{}
+			}
+			owners[k][item.Scope] = append(owners[k][item.Scope], item.Name)
+		}
+	}
+	conflicts := []string{}
+	for k, scopes := range owners {
+		global := scopes[KeyScopeGlobal]
+		if len(scopes) == 1 && len(global) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("%q is bound to %s", k, strings.Join(global, ", ")))
+		}
+		for scope, names := range scopes {
+			if scope == KeyScopeGlobal {
+				continue
+			}
+			if names = append(append([]string{}, global...), names...); len(names) > 1 {
+				conflicts = append(conflicts, fmt.Sprintf("%q is bound to %s", k, strings.Join(names, ", ")))
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("keybinding conflicts: %s", strings.Join(conflicts, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+func newTestKeyMap(t *testing.T, bindings map[string]string) (*KeyMap, error) {
+	t.Helper()
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.Keybindings = bindings
+	return NewKeyMap(cnf)
+}
+
+func TestKeyMapScopedConflicts(t *testing.T) {
+	// ctrl+r regenerates in the Conversation tab and reveals secrets in the Configuration tab.
+	if _, err := newTestKeyMap(t, nil); err != nil {
+		t.Fatalf("default keymap: %v", err)
+	}
+	if _, err := newTestKeyMap(t, map[string]string{"reveal_secret": "ctrl+x"}); err == nil || !strings.Contains(err.Error(), "reveal_secret, clear_field") {
+		t.Errorf("conflict in the Configuration tab: %v", err)
+	}
+	if _, err := newTestKeyMap(t, map[string]string{"clear_field": "esc"}); err == nil || !strings.Contains(err.Error(), "quit, clear_field") {
+		t.Errorf("conflict with a global key: %v", err)
+	}
+}
+
+func TestKeyMapOverrideFormKeys(t *testing.T) {
+	km, err := newTestKeyMap(t, map[string]string{"reveal_secret": "ctrl+e", "clear_field": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := km.RevealSecret.Keys(); len(keys) != 1 || keys[0] != "ctrl+e" {
+		t.Errorf("reveal_secret keys: %v", keys)
+	}
+	names := []string{}
+	for _, item := range km.Items() {
+		if item.Scope == KeyScopeConfig && item.Binding.Enabled() {
+			names = append(names, item.Name)
+		}
+	}
+	if got := strings.Join(names, ","); got != "next_field,prev_field,reveal_secret" {
+		t.Errorf("Configuration tab keys: %s", got)
+	}
+}
+
+func TestKeyMapSubmitKey(t *testing.T) {
+	cases := map[string][]string{
+		"":          {"enter", "alt+enter"},
+		"enter":     {"enter", "alt+enter"},
+		"alt+enter": {"alt+enter", "enter"},
+		"ctrl+j":    {"ctrl+j", "enter"},
+	}
+	for submit, want := range cases {
+		cnf := config.NewConf(config.NewDirs(t.TempDir()))
+		cnf.UI.SubmitKey = submit
+		km, err := NewKeyMap(cnf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := km.Submit.Keys()[0]; got != want[0] {
+			t.Errorf("submit key %q: sends with %q, want %q", submit, got, want[0])
+		}
+		if got := km.Newline.Keys()[0]; got != want[1] {
+			t.Errorf("submit key %q: newline with %q, want %q", submit, got, want[1])
+		}
+	}
+}
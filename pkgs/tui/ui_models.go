@@ -0,0 +1,219 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/gvcgo/gogpt/pkgs/ollama"
+)
+
+/*
+Models tab, local models of Ollama.
+*/
+const (
+	ModelsListTimeout = 10 * time.Second
+)
+
+type ModelsLoaded struct {
+	Models []ollama.Model
+	Err    error
+}
+
+// PullUpdate is a progress line of a pull, the next one is read from ch.
+type PullUpdate struct {
+	Progress ollama.PullProgress
+	ch       <-chan tea.Msg
+}
+
+type PullFinished struct {
+	Name string
+	Err  error
+}
+
+type ModelsModel struct {
+	CNF      *config.Config
+	Models   []ollama.Model
+	Cursor   int
+	Input    textinput.Model
+	Bar      progress.Model
+	Pulling  string // name of the model being pulled
+	Progress ollama.PullProgress
+	Error    error
+	Notice   string
+	loading  bool
+}
+
+func NewModelsModel(cnf *config.Config) (mm *ModelsModel) {
+	mm = &ModelsModel{
+		CNF: cnf,
+		Bar: progress.New(progress.WithDefaultGradient(), progress.WithWidth(40)),
+	}
+	mm.Input = textinput.New()
+	mm.Input.Prompt = "pull: "
+	mm.Input.Placeholder = "model name, like llama3 or qwen2:7b"
+	mm.Input.Focus()
+	return
+}
+
+func (that *ModelsModel) Init() tea.Cmd {
+	return tea.Batch(textinput.Blink, that.load())
+}
+
+func (that *ModelsModel) load() tea.Cmd {
+	that.loading = true
+	cnf := that.CNF.Copy()
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), ModelsListTimeout)
+		defer cancel()
+		models, err := ollama.ListModels(ctx, cnf)
+		return ModelsLoaded{Models: models, Err: err}
+	}
+}
+
+// pull starts pulling in the background, progress comes as PullUpdate.
+func (that *ModelsModel) pull(name string) tea.Cmd {
+	that.Pulling = name
+	that.Progress = ollama.PullProgress{Status: "starting"}
+	that.Error = nil
+	that.Notice = ""
+	cnf := that.CNF.Copy()
+	ch := make(chan tea.Msg)
+	go func() {
+		err := ollama.Pull(context.Background(), cnf, name, func(p ollama.PullProgress) {
+			ch <- PullUpdate{Progress: p, ch: ch}
+		})
+		ch <- PullFinished{Name: name, Err: err}
+		close(ch)
+	}()
+	return waitPull(ch)
+}
+
+func waitPull(ch <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-ch
+	}
+}
+
+// use sets the model of Ollama and saves the config.
+func (that *ModelsModel) use(name string) tea.Cmd {
+	that.CNF.Ollama.Model = name
+	that.CNF.Save()
+	notice := fmt.Sprintf("ollama model: %s", name)
+	return func() tea.Msg { return ConfigChanged{Notice: notice} }
+}
+
+func (that *ModelsModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		that.Bar.Width = msg.Width / 2
+	case ModelsLoaded:
+		that.loading = false
+		that.Models, that.Error = msg.Models, msg.Err
+		if that.Cursor >= len(that.Models) {
+			that.Cursor = 0
+		}
+		return that, nil
+	case PullUpdate:
+		that.Progress = msg.Progress
+		return that, waitPull(msg.ch)
+	case PullFinished:
+		that.Pulling = ""
+		if msg.Err != nil {
+			that.Error = msg.Err
+			return that, nil
+		}
+		that.Notice = fmt.Sprintf("pulled %s", msg.Name)
+		return that, that.load()
+	case ConfigChanged:
+		if msg.CNF != nil {
+			that.CNF = msg.CNF
+		}
+		that.Notice = msg.Notice
+		return that, that.load()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up":
+			if that.Cursor > 0 {
+				that.Cursor--
+			}
+			return that, nil
+		case "down":
+			if that.Cursor < len(that.Models)-1 {
+				that.Cursor++
+			}
+			return that, nil
+		case "ctrl+r":
+			return that, that.load()
+		case "enter":
+			name := strings.TrimSpace(that.Input.Value())
+			if name != "" {
+				if that.Pulling != "" {
+					that.Notice = fmt.Sprintf("wait for %s to be pulled", that.Pulling)
+					return that, nil
+				}
+				that.Input.Reset()
+				return that, that.pull(name)
+			}
+			if that.Cursor < len(that.Models) {
+				return that, that.use(that.Models[that.Cursor].Name)
+			}
+			return that, nil
+		}
+	}
+	that.Input, cmd = that.Input.Update(msg)
+	return that, cmd
+}
+
+func humanSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+func (that *ModelsModel) View() string {
+	rows := []string{fmt.Sprintf("Ollama models at %s", ollama.BaseUrl(that.CNF)), ""}
+	if that.loading && len(that.Models) == 0 {
+		rows = append(rows, "loading...")
+	} else if len(that.Models) == 0 && that.Error == nil {
+		rows = append(rows, "no model found, pull one below.")
+	}
+	for i, m := range that.Models {
+		row := fmt.Sprintf("%-30s %10s %8s %s", m.Name, humanSize(m.Size), m.Details.ParameterSize, m.Details.QuantizationLevel)
+		if m.Name == that.CNF.Ollama.Model || m.Name == that.CNF.Ollama.Model+":latest" {
+			row += " (in use)"
+		}
+		if i == that.Cursor {
+			rows = append(rows, codeSelectedStyle.Render("> "+row))
+		} else {
+			rows = append(rows, codeNormalStyle.Render("  "+row))
+		}
+	}
+	rows = append(rows, "", that.Input.View())
+	if that.Pulling != "" {
+		rows = append(rows, fmt.Sprintf("pulling %s: %s", that.Pulling, that.Progress.Status))
+		if that.Progress.Total > 0 {
+			rows = append(rows, fmt.Sprintf("%s %s/%s", that.Bar.ViewAs(that.Progress.Percent()),
+				humanSize(that.Progress.Completed), humanSize(that.Progress.Total)))
+		}
+	}
+	if that.Error != nil {
+		rows = append(rows, errorStyle.Render(fmt.Sprintf("error: %v", that.Error)))
+	} else if that.Notice != "" {
+		rows = append(rows, that.Notice)
+	}
+	rows = append(rows, "", helpStyle.Render("↑/↓ select, enter use the selected model or pull the entered one, ctrl+r refresh."))
+	return lipgloss.JoinVertical(lipgloss.Left, rows...)
+}
@@ -1,10 +1,13 @@
 package tui
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/gvcgo/gogpt/pkgs/config"
 )
 
 /*
@@ -20,17 +23,35 @@ Customed messgaes
 */
 type ReturnFirst string
 
+// ConfigChanged is sent to all tabs after the active config is replaced, like switching profiles.
+type ConfigChanged struct {
+	Notice string
+	CNF    *config.Config // the config read again from the file, nil if the config was changed in place.
+}
+
+// ConfigFileChanged is sent by the config file watcher, a new config is read and sent with ConfigChanged.
+type ConfigFileChanged struct{}
+
+// KeyCapturer is implemented by tabs that sometimes need the keys handled by GPTViewModel.
+type KeyCapturer interface {
+	CapturingKeys() bool
+}
+
 /*
 GPT UI Model
 */
 type GPTViewModel struct {
 	TabList   []*Tab
 	ActiveTab int
+	Keys      *KeyMap
+	CNF       *config.Config
 }
 
-func NewGPTViewModel() (gm *GPTViewModel) {
+func NewGPTViewModel(cnf *config.Config, keys *KeyMap) (gm *GPTViewModel) {
 	gm = &GPTViewModel{
 		TabList: []*Tab{},
+		Keys:    keys,
+		CNF:     cnf,
 	}
 	return
 }
@@ -61,17 +82,22 @@ func (that *GPTViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	currentModel := that.GetCurrentModel()
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch keypress := msg.String(); keypress {
-		case "ctrl+c", "esc":
+		if c, ok := currentModel.(KeyCapturer); ok && c.CapturingKeys() && msg.String() != "ctrl+c" {
+			m, cmd := currentModel.Update(msg)
+			that.UpdateCurrentModel(m)
+			return that, cmd
+		}
+		switch {
+		case key.Matches(msg, that.Keys.Quit):
 			that.Close()
 			return that, tea.Quit
-		case "right":
+		case key.Matches(msg, that.Keys.NextTab):
 			if that.ActiveTab < len(that.TabList)-1 {
 				that.ActiveTab++
 			} else {
 				that.ActiveTab = 0
 			}
-		case "left":
+		case key.Matches(msg, that.Keys.PrevTab):
 			if that.ActiveTab > 0 {
 				that.ActiveTab--
 			} else {
@@ -85,6 +111,44 @@ func (that *GPTViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case ReturnFirst:
 		that.ActiveTab = 0
 		return that, nil
+	case ConfigFileChanged:
+		// background work keeps the config it started with, tabs switch to the new one.
+		cnf, kept, err := that.CNF.Reloaded()
+		if err != nil {
+			return that, func() tea.Msg { return ConfigChanged{Notice: fmt.Sprintf("config reload failed: %v", err)} }
+		}
+		that.CNF = cnf
+		notice := "config reloaded"
+		if len(kept) > 0 {
+			notice += ", unsaved changes kept: " + strings.Join(kept, ", ")
+		}
+		return that, func() tea.Msg { return ConfigChanged{Notice: notice, CNF: cnf} }
+	case ModelsLoaded, PullUpdate, PullFinished:
+		// background results of the Models tab, also delivered when it is not active.
+		for _, tab := range that.TabList {
+			if m, ok := tab.Model.(*ModelsModel); ok {
+				_, cmd := m.Update(msg)
+				return that, cmd
+			}
+		}
+		return that, nil
+	case AnswerStarted, AnswerContinue, CompareChunk:
+		// answers keep streaming when another tab is active.
+		for _, tab := range that.TabList {
+			if m, ok := tab.Model.(*ConversationModel); ok {
+				_, cmd := m.Update(msg)
+				return that, cmd
+			}
+		}
+		return that, nil
+	case ConfigChanged:
+		cmds := []tea.Cmd{}
+		for _, tab := range that.TabList {
+			m, cmd := tab.Model.Update(msg)
+			tab.Model = m
+			cmds = append(cmds, cmd)
+		}
+		return that, tea.Batch(cmds...)
 	default:
 		m, cmd := currentModel.Update(msg)
 		that.UpdateCurrentModel(m)
@@ -99,9 +163,9 @@ func (that *GPTViewModel) View() string {
 	var style lipgloss.Style
 	for i, t := range that.TabList {
 		if i == that.ActiveTab {
-			style = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFF00"))
+			style = tabActiveStyle
 		} else {
-			style = lipgloss.NewStyle().Foreground(lipgloss.Color("#D2691E"))
+			style = tabInactiveStyle
 		}
 		newTabs = append(newTabs, style.Render(t.Title))
 	}
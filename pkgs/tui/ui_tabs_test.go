@@ -0,0 +1,53 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+)
+
+func TestConfigFileChangedSwapsConfig(t *testing.T) {
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.Save()
+	ui := NewGPTUI(cnf)
+	cnf.OpenAI.Model = "gpt-runtime"
+
+	content := `{"version": 2, "openai": {"context_length": 8}}`
+	if err := os.WriteFile(filepath.Join(cnf.GetWorkDir(), config.ConfigFileName), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, cmd := ui.GVM.Update(ConfigFileChanged{})
+	msg, ok := cmd().(ConfigChanged)
+	if !ok || msg.CNF == nil {
+		t.Fatalf("reload sent %+v", msg)
+	}
+	ui.GVM.Update(msg)
+	if cnf.OpenAI.ContextLen == 8 {
+		t.Error("the live config is changed in place")
+	}
+	for _, tab := range ui.GVM.TabList {
+		var c *config.Config
+		switch m := tab.Model.(type) {
+		case *ConversationModel:
+			c = m.CNF
+			if m.Conversation.CNF != c || !strings.Contains(m.Notice, "openai.model") {
+				t.Errorf("conversation config %p, notice %q", m.Conversation.CNF, m.Notice)
+			}
+		case *ModelsModel:
+			c = m.CNF
+		case *ConfTab:
+			c = m.CNF
+		default:
+			continue
+		}
+		if c != msg.CNF {
+			t.Errorf("%s tab keeps the old config", tab.Title)
+		}
+	}
+	if c := msg.CNF; c.OpenAI.ContextLen != 8 || c.OpenAI.Model != "gpt-runtime" {
+		t.Errorf("reloaded config %+v", c.OpenAI)
+	}
+}
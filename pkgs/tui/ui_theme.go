@@ -0,0 +1,62 @@
+package tui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/gvcgo/gogpt/pkgs/theme"
+)
+
+/*
+Styles of the TUI, set by ApplyTheme.
+*/
+var (
+	senderStyle       lipgloss.Style
+	botStyle          lipgloss.Style
+	errorStyle        lipgloss.Style
+	footerStyle       lipgloss.Style
+	tabActiveStyle    lipgloss.Style
+	tabInactiveStyle  lipgloss.Style
+	helpStyle         lipgloss.Style
+	commandHintStyle  lipgloss.Style
+	inputTextStyle    lipgloss.Style
+	placeholderStyle  lipgloss.Style
+	codeSelectedStyle lipgloss.Style
+	codeNormalStyle   lipgloss.Style
+	diffAddStyle      lipgloss.Style
+	diffDelStyle      lipgloss.Style
+	formFocusedStyle  lipgloss.Style
+	glamourStyle      string
+)
+
+func init() {
+	ApplyTheme(theme.Builtin[theme.Dark])
+}
+
+func color(c string) lipgloss.TerminalColor {
+	if c == "" {
+		return lipgloss.NoColor{}
+	}
+	return lipgloss.Color(c)
+}
+
+// ApplyTheme sets colors of tabs, footer, input and glamour together, call it before models are created.
+func ApplyTheme(t theme.Theme) {
+	senderStyle = lipgloss.NewStyle().Bold(true).Foreground(color(t.Sender))
+	botStyle = lipgloss.NewStyle().Bold(true).Foreground(color(t.Bot))
+	errorStyle = lipgloss.NewStyle().Bold(true).Foreground(color(t.Error))
+	footerStyle = lipgloss.NewStyle().Height(1).Foreground(color(t.Footer)).Faint(true)
+	tabActiveStyle = lipgloss.NewStyle().Foreground(color(t.TabActive))
+	tabInactiveStyle = lipgloss.NewStyle().Foreground(color(t.TabInactive))
+	helpStyle = lipgloss.NewStyle().Foreground(color(t.Help))
+	commandHintStyle = lipgloss.NewStyle().Foreground(color(t.Hint))
+	inputTextStyle = lipgloss.NewStyle().Foreground(color(t.InputText))
+	placeholderStyle = lipgloss.NewStyle().Foreground(color(t.Placeholder))
+	codeSelectedStyle = lipgloss.NewStyle().Foreground(color(t.Selected))
+	codeNormalStyle = lipgloss.NewStyle().Foreground(color(t.Unselected))
+	diffAddStyle = lipgloss.NewStyle().Foreground(color(t.DiffAdd))
+	diffDelStyle = lipgloss.NewStyle().Foreground(color(t.DiffDel))
+	formFocusedStyle = lipgloss.NewStyle().Foreground(color(t.Selected))
+	glamourStyle = t.Glamour
+	if glamourStyle == "" {
+		glamourStyle = "auto"
+	}
+}
@@ -0,0 +1,44 @@
+package zhipu
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Connection test.
+*/
+
+// Ping sends a one-token question without streaming.
+func (that *GLM) Ping(ctx context.Context) error {
+	headers, err := that.headers()
+	if err != nil {
+		return err
+	}
+	req := that.NewRequest([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+	})
+	req.Stream, req.MaxTokens = false, 1
+	body, _ := json.Marshal(req)
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, that.Endpoint(), strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	resp, err := that.HttpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return readError(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,139 @@
+package zhipu
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+)
+
+/*
+Error codes of Zhipu.
+
+1000    身份验证失败
+1001    Header中未收到Authentication参数
+1002    Authentication Token非法
+1003    Authentication Token已过期
+1004    提供的Authentication Token验证失败
+1100    账户读写
+1110    账户非活动状态
+1111    账户不存在
+1112    账户已被锁定
+1113    账户已欠费
+1120    无法成功访问账户
+1200    API调用错误
+1210    API调用参数有误
+1211    模型不存在
+1212    当前模型不支持该调用方式
+1213    未正常接收到prompt参数
+1214    参数非法
+1220    无权访问该API
+1221    API已下线
+1222    API不存在
+1230    API调用流程出错
+1231    已有请求
+1234    网络错误
+1261    Prompt超长
+1300    API调用被阻止
+1301    输入或生成内容可能包含不安全或敏感内容
+1302    并发数过高
+1303    频率过高
+1304    当日调用次数超限
+1305    请求过多
+*/
+
+type GLMAPIError struct {
+	Code string
+	Info string
+}
+
+func (that GLMAPIError) Error() string {
+	return fmt.Sprintf("code: %s, info: %s", that.Code, that.Info)
+}
+
+func NewGLMError(code, info string) (gae GLMAPIError) {
+	return GLMAPIError{Code: code, Info: info}
+}
+
+var (
+	ErrAuthFailed         = NewGLMError("1000", "authentication failed")
+	ErrNoAuthHeader       = NewGLMError("1001", "authentication header missing")
+	ErrInvalidToken       = NewGLMError("1002", "invalid authentication token")
+	ErrTokenExpired       = NewGLMError("1003", "authentication token expired")
+	ErrTokenRejected      = NewGLMError("1004", "authentication token rejected")
+	ErrAccount            = NewGLMError("1100", "account error")
+	ErrAccountInactive    = NewGLMError("1110", "account inactive")
+	ErrAccountNotExist    = NewGLMError("1111", "account not exists")
+	ErrAccountLocked      = NewGLMError("1112", "account locked")
+	ErrArrears            = NewGLMError("1113", "account in arrears")
+	ErrAccountUnreachable = NewGLMError("1120", "account not accessible")
+	ErrAPICall            = NewGLMError("1200", "api call error")
+	ErrInvalidArgument    = NewGLMError("1210", "invalid api arguments")
+	ErrModelNotExist      = NewGLMError("1211", "model not exists")
+	ErrMethodUnsupported  = NewGLMError("1212", "method not supported by the model")
+	ErrNoPrompt           = NewGLMError("1213", "prompt missing")
+	ErrInvalidParams      = NewGLMError("1214", "invalid parameters")
+	ErrNoPermission       = NewGLMError("1220", "no permission to the api")
+	ErrAPIOffline         = NewGLMError("1221", "api offline")
+	ErrAPINotExist        = NewGLMError("1222", "api not exists")
+	ErrAPIProcess         = NewGLMError("1230", "api process error")
+	ErrDuplicateRequest   = NewGLMError("1231", "request already exists")
+	ErrNetwork            = NewGLMError("1234", "network error")
+	ErrPromptTooLong      = NewGLMError("1261", "prompt too long")
+	ErrBlocked            = NewGLMError("1300", "api call blocked")
+	ErrSensitive          = NewGLMError("1301", "unsafe or sensitive content")
+	ErrConcurrency        = NewGLMError("1302", "reach concurrency limit")
+	ErrRateLimit          = NewGLMError("1303", "reach rate limit")
+	ErrDailyLimit         = NewGLMError("1304", "reach daily request limit")
+	ErrTooManyRequests    = NewGLMError("1305", "too many requests")
+)
+
+var GLMErrorMap map[string]error = map[string]error{
+	"1000": ErrAuthFailed,
+	"1001": ErrNoAuthHeader,
+	"1002": ErrInvalidToken,
+	"1003": ErrTokenExpired,
+	"1004": ErrTokenRejected,
+	"1100": ErrAccount,
+	"1110": ErrAccountInactive,
+	"1111": ErrAccountNotExist,
+	"1112": ErrAccountLocked,
+	"1113": ErrArrears,
+	"1120": ErrAccountUnreachable,
+	"1200": ErrAPICall,
+	"1210": ErrInvalidArgument,
+	"1211": ErrModelNotExist,
+	"1212": ErrMethodUnsupported,
+	"1213": ErrNoPrompt,
+	"1214": ErrInvalidParams,
+	"1220": ErrNoPermission,
+	"1221": ErrAPIOffline,
+	"1222": ErrAPINotExist,
+	"1230": ErrAPIProcess,
+	"1231": ErrDuplicateRequest,
+	"1234": ErrNetwork,
+	"1261": ErrPromptTooLong,
+	"1300": ErrBlocked,
+	"1301": ErrSensitive,
+	"1302": ErrConcurrency,
+	"1303": ErrRateLimit,
+	"1304": ErrDailyLimit,
+	"1305": ErrTooManyRequests,
+}
+
+// lookupError returns the error of code, with the message from the server for unknown codes.
+func lookupError(code, info string) error {
+	if err, ok := GLMErrorMap[code]; ok {
+		return err
+	}
+	return NewGLMError(code, info)
+}
+
+// Retryable tells if err is a rate limit or a network error worth another try.
+func Retryable(err error) (bool, time.Duration) {
+	switch err {
+	case ErrNetwork, ErrConcurrency, ErrRateLimit, ErrTooManyRequests:
+		return true, 0
+	}
+	return backend.Retryable(err)
+}
@@ -0,0 +1,245 @@
+package zhipu
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/backend"
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+/*
+Zhipu chat completions API of GLM.
+
+The api key is {id}.{secret}, requests are authorized by a JWT signed with the secret:
+
+	header  {"alg": "HS256", "sign_type": "SIGN"}
+	payload {"api_key": "{id}", "exp": ..., "timestamp": ...}  // milliseconds
+
+	POST {base_url}/api/paas/v4/chat/completions
+	Authorization: Bearer {jwt}
+
+The body and the server-sent events follow the OpenAI format, the last chunk has usage:
+
+	data: {"choices": [{"delta": {"content": "..."}, "finish_reason": "stop"}], "usage": {"total_tokens": 20}}
+	data: [DONE]
+
+Errors are {"error": {"code": "1261", "message": "..."}}.
+*/
+const (
+	DefaultBaseUrl string = "https://open.bigmodel.cn"
+	DefaultModel   string = "glm-4"
+	TokenTTL              = 30 * time.Minute
+)
+
+// Models for selection.
+var ModelList = []string{
+	"glm-4",
+	"glm-4-air",
+	"glm-4-flash",
+	"glm-3-turbo",
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type Request struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Stream      bool      `json:"stream"`
+	Temperature float64   `json:"temperature,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+}
+
+type Response struct {
+	Choices []struct {
+		Delta        Message `json:"delta"`
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+		TotalTokens      int64 `json:"total_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type GLM struct {
+	CNF        *config.Config
+	HttpClient *http.Client
+	resp       *http.Response
+	events     *backend.SSEReader
+	tokens     int64 // usage not reported by GetTokens yet
+}
+
+func NewGLM(cnf *config.Config) (g *GLM) {
+	g = &GLM{
+		CNF:        cnf,
+		HttpClient: &http.Client{},
+	}
+	return
+}
+
+// Endpoint returns the url of the chat completions api.
+func (that *GLM) Endpoint() string {
+	base := that.CNF.GLM.BaseUrl
+	if base == "" {
+		base = DefaultBaseUrl
+	}
+	return strings.TrimSuffix(base, "/") + "/api/paas/v4/chat/completions"
+}
+
+// SignToken returns a JWT for the api key {id}.{secret}, valid for ttl.
+func SignToken(apiKey string, ttl time.Duration, now time.Time) (string, error) {
+	id, secret, ok := strings.Cut(apiKey, ".")
+	if !ok || id == "" || secret == "" {
+		return "", fmt.Errorf("invalid glm api key, want {id}.{secret}")
+	}
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "sign_type": "SIGN"})
+	payload, _ := json.Marshal(map[string]interface{}{
+		"api_key":   id,
+		"exp":       now.Add(ttl).UnixMilli(),
+		"timestamp": now.UnixMilli(),
+	})
+	signing := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signing))
+	return signing + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (that *GLM) headers() (map[string]string, error) {
+	apiKey, err := that.CNF.Secret(that.CNF.GLM.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+	token, err := SignToken(apiKey, TokenTTL, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"Authorization": "Bearer " + token}, nil
+}
+
+// NewRequest converts chat messages, an empty system prompt is dropped.
+func (that *GLM) NewRequest(msgs []openai.ChatCompletionMessage) *Request {
+	req := &Request{
+		Model:       that.CNF.GLM.Model,
+		Stream:      true,
+		Temperature: that.CNF.GLM.Temperature,
+		MaxTokens:   that.CNF.GLM.MaxTokens,
+	}
+	if req.Model == "" {
+		req.Model = DefaultModel
+	}
+	for _, m := range msgs {
+		if m.Role == openai.ChatMessageRoleSystem && m.Content == "" {
+			continue
+		}
+		req.Messages = append(req.Messages, Message{Role: m.Role, Content: m.Content})
+	}
+	return req
+}
+
+// readError decodes {"error": {"code": "...", "message": "..."}}.
+func readError(resp *http.Response) error {
+	var apiErr error
+	err := backend.ReadError(resp, func(body []byte) string {
+		r := &Response{}
+		if json.Unmarshal(body, r) == nil && r.Error != nil {
+			apiErr = lookupError(r.Error.Code, r.Error.Message)
+		}
+		return ""
+	})
+	if apiErr != nil {
+		return apiErr
+	}
+	return err
+}
+
+func (that *GLM) SendMsg(msgs []openai.ChatCompletionMessage) (m string, err error) {
+	that.Close()
+	headers, err := that.headers()
+	if err != nil {
+		return "", err
+	}
+	resp, err := backend.PostJSON(that.HttpClient, that.Endpoint(), headers, that.NewRequest(msgs))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", readError(resp)
+	}
+	that.resp = resp
+	that.events = backend.NewSSEReader(resp.Body)
+	return "", nil
+}
+
+// RecvMsg returns the text of the next chunk, io.EOF after [DONE].
+func (that *GLM) RecvMsg() (m string, err error) {
+	if that.events == nil {
+		return "", fmt.Errorf("no stream found")
+	}
+	for {
+		ev, err := that.events.Next()
+		if err != nil {
+			that.Close()
+			if err == io.EOF {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if ev.Data == "[DONE]" {
+			that.Close()
+			return "", io.EOF
+		}
+		r := &Response{}
+		if err = json.Unmarshal([]byte(ev.Data), r); err != nil {
+			that.Close()
+			return "", fmt.Errorf("decode chunk: %w", err)
+		}
+		if r.Error != nil {
+			that.Close()
+			return "", lookupError(r.Error.Code, r.Error.Message)
+		}
+		that.tokens += r.Usage.TotalTokens
+		if len(r.Choices) == 0 {
+			continue
+		}
+		c := r.Choices[0]
+		if c.FinishReason == "sensitive" {
+			that.Close()
+			return "", ErrSensitive
+		}
+		if c.Delta.Content != "" {
+			return c.Delta.Content, nil
+		}
+	}
+}
+
+func (that *GLM) Close() {
+	if that.resp != nil {
+		that.resp.Body.Close()
+	}
+	that.resp = nil
+	that.events = nil
+}
+
+// GetTokens returns the tokens reported by the api since the last call.
+func (that *GLM) GetTokens() (tokens int64) {
+	tokens, that.tokens = that.tokens, 0
+	return
+}
@@ -0,0 +1,169 @@
+package zhipu
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gvcgo/gogpt/pkgs/config"
+	"github.com/sashabaranov/go-openai"
+)
+
+// verifyToken checks the signature of a JWT with secret and returns its header and payload.
+func verifyToken(token, secret string) (header, payload map[string]interface{}, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("%d parts in %q", len(parts), token)
+	}
+	enc := base64.RawURLEncoding
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if sig, _ := enc.DecodeString(parts[2]); !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, nil, fmt.Errorf("invalid signature")
+	}
+	for i, v := range []*map[string]interface{}{&header, &payload} {
+		b, err := enc.DecodeString(parts[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = json.Unmarshal(b, v); err != nil {
+			return nil, nil, err
+		}
+	}
+	return
+}
+
+func TestSignToken(t *testing.T) {
+	now := time.UnixMilli(1700000000123)
+	token, err := SignToken("my-id.my-secret", TokenTTL, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, payload, err := verifyToken(token, "my-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header["alg"] != "HS256" || header["sign_type"] != "SIGN" {
+		t.Errorf("header %v", header)
+	}
+	// numbers are decoded as float64, milliseconds fit exactly.
+	if payload["api_key"] != "my-id" || payload["timestamp"] != float64(1700000000123) || payload["exp"] != float64(1700000000123+TokenTTL.Milliseconds()) {
+		t.Errorf("payload %v", payload)
+	}
+	if _, _, err := verifyToken(token, "other-secret"); err == nil {
+		t.Error("token verified with another secret")
+	}
+
+	for _, key := range []string{"", "no-secret", "my-id.", ".my-secret"} {
+		if _, err := SignToken(key, TokenTTL, now); err == nil {
+			t.Errorf("signed with the invalid api key %q", key)
+		}
+	}
+}
+
+// newTestGLM returns a client of a local stand-in server, sending events for every request with a valid token.
+func newTestGLM(t *testing.T, events string, got *Request) *GLM {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, payload, err := verifyToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), "test-secret")
+		if err != nil || payload["api_key"] != "test-id" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error": {"code": "1002", "message": "Authorization Token非法"}}`)
+			return
+		}
+		if r.URL.Path != "/api/paas/v4/chat/completions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if got != nil {
+			json.NewDecoder(r.Body).Decode(got)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, events)
+	}))
+	t.Cleanup(srv.Close)
+	cnf := config.NewConf(config.NewDirs(t.TempDir()))
+	cnf.GLM.BaseUrl = srv.URL + "/"
+	cnf.GLM.ApiKey = "test-id.test-secret"
+	return NewGLM(cnf)
+}
+
+func sse(data string) string {
+	return fmt.Sprintf("data: %s\n\n", data)
+}
+
+func recvAll(g *GLM) (answer string, err error) {
+	for {
+		m, err := g.RecvMsg()
+		answer += m
+		if err != nil {
+			return answer, err
+		}
+	}
+}
+
+func TestGLMStream(t *testing.T) {
+	events := sse(`{"choices": [{"index": 0, "delta": {"role": "assistant", "content": "Hello"}}]}`) +
+		sse(`{"choices": [{"index": 0, "delta": {"role": "assistant", "content": ", world"}}]}`) +
+		sse(`{"choices": [{"index": 0, "delta": {"role": "assistant", "content": ""}, "finish_reason": "stop"}], "usage": {"prompt_tokens": 9, "total_tokens": 12}}`) +
+		sse("[DONE]")
+	req := &Request{}
+	g := newTestGLM(t, events, req)
+	msgs := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: ""},
+		{Role: openai.ChatMessageRoleUser, Content: "hi"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "hello"},
+		{Role: openai.ChatMessageRoleUser, Content: "say hello"},
+	}
+	if _, err := g.SendMsg(msgs); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := recvAll(g)
+	if err != io.EOF {
+		t.Fatalf("stream ended with %v", err)
+	}
+	if answer != "Hello, world" {
+		t.Errorf("answer %q", answer)
+	}
+	if tokens := g.GetTokens(); tokens != 12 {
+		t.Errorf("tokens %d, want 12", tokens)
+	}
+	if req.Model != DefaultModel || !req.Stream || len(req.Messages) != 3 || req.Messages[0].Role != "user" {
+		t.Errorf("request %+v, the empty system prompt is dropped", req)
+	}
+}
+
+func TestGLMStreamErrors(t *testing.T) {
+	for name, c := range map[string]struct {
+		events string
+		want   error
+	}{
+		"sensitive": {sse(`{"choices": [{"delta": {"content": ""}, "finish_reason": "sensitive"}]}`), ErrSensitive},
+		"error":     {sse(`{"error": {"code": "1261", "message": "Prompt 超长"}}`), ErrPromptTooLong},
+		"cut":       {sse(`{"choices": [{"delta": {"content": "Hel"}}]}`), io.ErrUnexpectedEOF},
+	} {
+		g := newTestGLM(t, c.events, nil)
+		if _, err := g.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := recvAll(g); err != c.want {
+			t.Errorf("%s: stream ended with %v, want %v", name, err, c.want)
+		}
+	}
+}
+
+func TestGLMHTTPError(t *testing.T) {
+	g := newTestGLM(t, "", nil)
+	g.CNF.GLM.ApiKey = "test-id.wrong-secret"
+	if _, err := g.SendMsg([]openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}}); err != ErrInvalidToken {
+		t.Errorf("error %v", err)
+	}
+}